@@ -99,7 +99,9 @@ npm run build
 log "Building Skywatch..."
 cd "$INSTALL_DIR"
 export PATH=$PATH:/usr/local/go/bin
-go build -o adsb-tracker .
+VERSION=$(git -c safe.directory="$INSTALL_DIR" describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git -c safe.directory="$INSTALL_DIR" rev-parse --short HEAD 2>/dev/null || true)
+go build -tags embedweb -ldflags "-X main.version=$VERSION -X main.commit=$COMMIT -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" -o adsb-tracker .
 
 if ! id -u skywatch &>/dev/null; then
     log "Creating skywatch user..."
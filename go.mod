@@ -4,4 +4,6 @@ go 1.25.4
 
 require github.com/gorilla/websocket v1.5.3
 
-require github.com/lib/pq v1.10.9 // indirect
+require github.com/lib/pq v1.10.9
+
+require gopkg.in/yaml.v3 v3.0.1
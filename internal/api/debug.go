@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// DebugHandler serves the net/http/pprof endpoints under /debug/pprof/. It is
+// meant for a separate, private listener, since profiles expose internals
+// and can be expensive to collect.
+func DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+type goroutinesResponse struct {
+	Goroutines int `json:"goroutines"`
+}
+
+func (s *Server) handleDebugGoroutines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count := runtime.NumGoroutine()
+	if s.healthMonitor != nil {
+		count = s.healthMonitor.GetStats().GoRoutines
+	}
+	writeJSON(w, http.StatusOK, goroutinesResponse{Goroutines: count})
+}
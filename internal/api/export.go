@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"adsb-tracker/internal/database"
+	"adsb-tracker/pkg/models"
+)
+
+// csvFlushEvery bounds how many rows are buffered before being written out.
+const csvFlushEvery = 100
+
+func wantsCSV(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "csv"
+}
+
+func startCSV(w http.ResponseWriter, name string) *csv.Writer {
+	filename := name + "-" + time.Now().UTC().Format("20060102-150405") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	return csv.NewWriter(w)
+}
+
+// flushCSV pushes buffered rows to the client every csvFlushEvery rows.
+func flushCSV(w http.ResponseWriter, cw *csv.Writer, rows int) error {
+	if rows%csvFlushEvery != 0 {
+		return nil
+	}
+	cw.Flush()
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return cw.Error()
+}
+
+func writeAircraftCSV(w http.ResponseWriter, aircraft []models.Aircraft) {
+	cw := startCSV(w, "aircraft")
+	cw.Write([]string{"icao", "callsign", "registration", "aircraft_type", "lat", "lon",
+		"alt_ft", "speed_kt", "heading", "distance_nm", "last_seen"})
+
+	for i := range aircraft {
+		ac := &aircraft[i]
+		cw.Write([]string{
+			ac.ICAO,
+			ac.Callsign,
+			ac.Registration,
+			ac.AircraftType,
+			csvFloat(ac.Lat, 5),
+			csvFloat(ac.Lon, 5),
+			csvInt(ac.AltitudeFt),
+			csvFloat(ac.SpeedKt, 1),
+			csvFloat(ac.Heading, 1),
+			csvFloat(ac.DistanceNM, 1),
+			ac.LastSeen.UTC().Format(time.RFC3339),
+		})
+		if err := flushCSV(w, cw, i+1); err != nil {
+			logger.Warn("aircraft CSV export aborted", "error", err)
+			return
+		}
+	}
+	cw.Flush()
+}
+
+func (s *Server) writeFlightsCSV(w http.ResponseWriter, limit int) {
+	cw := startCSV(w, "flights")
+	cw.Write([]string{"id", "icao", "callsign", "registration", "aircraft_type",
+		"first_seen", "last_seen", "duration_s", "max_alt_ft", "total_dist_nm", "avg_speed_kt"})
+
+	rows := 0
+	err := s.flightTracker.EachRecentFlight(limit, func(f *database.FlightRecord) error {
+		cw.Write([]string{
+			strconv.FormatInt(f.ID, 10),
+			f.ICAO,
+			f.Callsign,
+			f.Registration,
+			f.AircraftType,
+			f.FirstSeen.UTC().Format(time.RFC3339),
+			f.LastSeen.UTC().Format(time.RFC3339),
+			strconv.FormatInt(f.DurationSeconds, 10),
+			csvInt(f.MaxAltFt),
+			strconv.FormatFloat(f.TotalDistNM, 'f', 1, 64),
+			csvFloat(f.AvgSpeedKt, 1),
+		})
+		rows++
+		return flushCSV(w, cw, rows)
+	})
+	if err != nil {
+		logger.Warn("flight CSV export aborted", "rows", rows, "error", err)
+		return
+	}
+	cw.Flush()
+}
+
+func csvFloat(v *float64, prec int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', prec, 64)
+}
+
+func csvInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"adsb-tracker/internal/lookup"
+	"adsb-tracker/pkg/models"
+)
+
+const (
+	// maxImportBytes caps the body of a registry import. The full FAA
+	// registry as CSV is well under this.
+	maxImportBytes = 128 << 20
+
+	faaImportBatchSize = 5000
+
+	// maxImportErrors is how many rejected records are described in the
+	// response; the rest are only counted.
+	maxImportErrors = 20
+)
+
+type faaImportRecord struct {
+	ICAO string `json:"icao"`
+	models.FAAInfo
+}
+
+type faaImportResponse struct {
+	Inserted int      `json:"inserted"`
+	Updated  int      `json:"updated"`
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors,omitempty"`
+	// Error is set when a batch fails to save. The counts then cover the
+	// batches committed before it.
+	Error string `json:"error,omitempty"`
+}
+
+// faaFieldLimits are the faa_registry column widths, in characters.
+var faaFieldLimits = []struct {
+	name  string
+	limit int
+	value func(*models.FAAInfo) string
+}{
+	{"registration", 10, func(i *models.FAAInfo) string { return i.Registration }},
+	{"aircraft_type", 10, func(i *models.FAAInfo) string { return i.AircraftType }},
+	{"manufacturer", 100, func(i *models.FAAInfo) string { return i.Manufacturer }},
+	{"model", 100, func(i *models.FAAInfo) string { return i.Model }},
+	{"operator", 100, func(i *models.FAAInfo) string { return i.Operator }},
+	{"owner", 100, func(i *models.FAAInfo) string { return i.Owner }},
+}
+
+// requireAPIKey checks the request carries the configured API key, as an
+// X-API-Key header or a bearer token, and writes an error response if not.
+func (s *Server) requireAPIKey(w http.ResponseWriter, r *http.Request) bool {
+	if s.apiKey == "" {
+		http.Error(w, "Set api_key to enable this endpoint", http.StatusForbidden)
+		return false
+	}
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKey)) != 1 {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleFAAImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireAPIKey(w, r) {
+		return
+	}
+	if s.repo == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	records, err := readImportRecords(r)
+	if err != nil {
+		http.Error(w, "Invalid import: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := faaImportResponse{}
+	valid := make(map[string]*models.FAAInfo, len(records))
+	for icao, info := range records {
+		reason := ""
+		if !validICAO(icao) {
+			reason = fmt.Sprintf("invalid icao %q", icao)
+		} else if field := oversizedField(info); field != "" {
+			reason = fmt.Sprintf("%s: %s is too long", icao, field)
+		}
+		if reason != "" {
+			resp.Rejected++
+			if len(resp.Errors) < maxImportErrors {
+				resp.Errors = append(resp.Errors, reason)
+			}
+			continue
+		}
+		valid[icao] = info
+	}
+
+	batch := make(map[string]*models.FAAInfo, faaImportBatchSize)
+	flush := func() error {
+		inserted, updated, err := s.repo.ImportFAAInfo(batch, lookup.SourceImport)
+		if err != nil {
+			return err
+		}
+		resp.Inserted += inserted
+		resp.Updated += updated
+		if s.faaLookup != nil {
+			s.faaLookup.Store(batch)
+		}
+		batch = make(map[string]*models.FAAInfo, faaImportBatchSize)
+		return nil
+	}
+	for icao, info := range valid {
+		batch[icao] = info
+		if len(batch) < faaImportBatchSize {
+			continue
+		}
+		if err := flush(); err != nil {
+			importFailed(w, resp, err)
+			return
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			importFailed(w, resp, err)
+			return
+		}
+	}
+
+	logger.Info("FAA import complete", "inserted", resp.Inserted, "updated", resp.Updated, "rejected", resp.Rejected)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// importFailed reports a batch that failed to save, along with the counts
+// from the batches already committed.
+func importFailed(w http.ResponseWriter, resp faaImportResponse, err error) {
+	logger.Error("FAA import failed", "error", err, "inserted", resp.Inserted, "updated", resp.Updated)
+	resp.Error = "Import failed; inserted and updated count the records saved before the failure"
+	writeJSON(w, http.StatusInternalServerError, resp)
+}
+
+// oversizedField returns the name of the first field too long for its
+// faa_registry column, or an empty string.
+func oversizedField(info *models.FAAInfo) string {
+	for _, f := range faaFieldLimits {
+		if utf8.RuneCountInString(f.value(info)) > f.limit {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// readImportRecords parses a CSV body, in the format read by
+// lookup.ReadRegistryCSV, or a JSON array of records, keyed by upper-case
+// ICAO address.
+func readImportRecords(r *http.Request) (map[string]*models.FAAInfo, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return lookup.ReadRegistryCSV(r.Body)
+	}
+
+	var list []faaImportRecord
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	records := make(map[string]*models.FAAInfo, len(list))
+	for i := range list {
+		icao := strings.ToUpper(strings.TrimSpace(list[i].ICAO))
+		records[icao] = &list[i].FAAInfo
+	}
+	return records, nil
+}
+
+func validICAO(icao string) bool {
+	if len(icao) != 6 {
+		return false
+	}
+	for _, c := range icao {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
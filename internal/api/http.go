@@ -2,7 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -11,11 +15,16 @@ import (
 	"adsb-tracker/internal/feed"
 	"adsb-tracker/internal/flight"
 	"adsb-tracker/internal/health"
+	"adsb-tracker/internal/logging"
+	"adsb-tracker/internal/lookup"
 	rangetracker "adsb-tracker/internal/range"
 	"adsb-tracker/internal/tracker"
 	"adsb-tracker/internal/webhook"
+	"adsb-tracker/pkg/models"
 )
 
+var logger = logging.Component("api")
+
 type Server struct {
 	tracker       *tracker.Tracker
 	repo          *database.Repository
@@ -25,9 +34,14 @@ type Server struct {
 	feedClient    *feed.Client
 	webhooks      *webhook.Dispatcher
 	nodeName      string
+	units         models.DistanceUnit
 	rangeTracker  *rangetracker.Tracker
 	flightTracker *flight.Tracker
 	readiness     *health.Readiness
+	dbCheck       *health.DatabaseCheck
+	faaLookup     *lookup.FAALookup
+	apiKey        string
+	buildInfo     BuildInfo
 }
 
 func NewServer(t *tracker.Tracker, repo *database.Repository) *Server {
@@ -56,6 +70,10 @@ func (s *Server) SetNodeName(name string) {
 	s.nodeName = name
 }
 
+func (s *Server) SetUnits(u models.DistanceUnit) {
+	s.units = u
+}
+
 func (s *Server) SetRangeTracker(rt *rangetracker.Tracker) {
 	s.rangeTracker = rt
 }
@@ -64,17 +82,36 @@ func (s *Server) SetFlightTracker(ft *flight.Tracker) {
 	s.flightTracker = ft
 }
 
+// SetAPIKey sets the key required by endpoints that change stored data.
+// With no key those endpoints are disabled.
+func (s *Server) SetAPIKey(key string) {
+	s.apiKey = key
+}
+
+func (s *Server) SetFAALookup(f *lookup.FAALookup) {
+	s.faaLookup = f
+}
+
 func (s *Server) SetReadiness(r *health.Readiness) {
 	s.readiness = r
 }
 
+func (s *Server) SetDatabaseCheck(c *health.DatabaseCheck) {
+	s.dbCheck = c
+}
+
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/api/v1/aircraft", s.handleAircraft)
 	mux.HandleFunc("/api/v1/aircraft/search", s.handleAircraftSearch)
+	mux.HandleFunc("/api/v1/aircraft/count", s.handleAircraftCount)
 	mux.HandleFunc("/api/v1/aircraft/", s.handleAircraftRoutes)
 	mux.HandleFunc("/api/v1/health", s.handleHealth)
+	mux.HandleFunc("/api/v1/version", s.handleVersion)
+	mux.HandleFunc("/api/v1/health/history", s.handleHealthHistory)
+	mux.HandleFunc("/api/v1/health/system", s.handleReceiverHealth)
+	mux.HandleFunc("/api/v1/debug/goroutines", s.handleDebugGoroutines)
 	mux.HandleFunc("/api/v1/stats", s.handleStats)
 	mux.HandleFunc("/api/v1/stats/hourly", s.handleStatsHourly)
 	mux.HandleFunc("/api/v1/stats/daily", s.handleStatsDaily)
@@ -84,16 +121,21 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/api/v1/stats/altitude", s.handleStatsAltitude)
 	mux.HandleFunc("/api/v1/stats/recent", s.handleStatsRecent)
 	mux.HandleFunc("/api/v1/stats/range", s.handleStatsRange)
+	mux.HandleFunc("/api/v1/range", s.handleRange)
+	mux.HandleFunc("/api/v1/range/reset", s.handleRangeReset)
 	mux.HandleFunc("/api/v1/stats/peak", s.handleStatsPeak)
 	mux.HandleFunc("/api/v1/flights", s.handleFlights)
+	mux.HandleFunc("/api/v1/flights/active", s.handleActiveFlights)
 	mux.HandleFunc("/api/v1/flights/", s.handleFlightByID)
 	mux.HandleFunc("/api/v1/receiver", s.handleReceiver)
 	mux.HandleFunc("/api/v1/receiver/health", s.handleReceiverHealth)
 	mux.HandleFunc("/api/v1/receiver/feed", s.handleReceiverFeed)
 	mux.HandleFunc("/api/v1/webhooks/test", s.handleWebhookTest)
+	mux.HandleFunc("/api/v1/events", s.handleEvents)
+	mux.HandleFunc("/api/v1/faa/import", s.handleFAAImport)
 
 	mux.HandleFunc("/ws", s.wsHub.HandleWebSocket)
-	mux.Handle("/", http.FileServer(http.Dir("web/dist")))
+	mux.Handle("/", webHandler())
 	return mux
 }
 
@@ -107,10 +149,42 @@ func (s *Server) handleAircraft(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	aircraft := s.tracker.GetAll()
+	// Trails are large and have their own endpoint, so they are only
+	// included on request.
+	var aircraft []models.Aircraft
+	if r.URL.Query().Get("trails") == "true" {
+		aircraft = s.tracker.GetAll()
+	} else {
+		aircraft = s.tracker.GetAllSummary()
+	}
+	if err := sortAircraft(aircraft, r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if wantsCSV(r) {
+		writeAircraftCSV(w, aircraft)
+		return
+	}
+	setAges(aircraft, time.Now())
 	writeJSON(w, http.StatusOK, aircraft)
 }
 
+// setAges stamps each aircraft copy with how long ago it was last heard, so
+// clients don't have to compare LastSeen against their own clock.
+func setAges(aircraft []models.Aircraft, now time.Time) {
+	for i := range aircraft {
+		aircraft[i].AgeSeconds = ageSeconds(aircraft[i].LastSeen, now)
+	}
+}
+
+func ageSeconds(lastSeen, now time.Time) *float64 {
+	age := math.Round(now.Sub(lastSeen).Seconds()*10) / 10
+	if age < 0 {
+		age = 0
+	}
+	return &age
+}
+
 func (s *Server) handleAircraftRoutes(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -139,6 +213,8 @@ func (s *Server) handleAircraftRoutes(w http.ResponseWriter, r *http.Request) {
 		s.handleFAA(w, r, icao)
 	case "history":
 		s.handleHistory(w, r, icao)
+	case "profile":
+		s.handleProfile(w, r, icao)
 	default:
 		http.Error(w, "Not found", http.StatusNotFound)
 	}
@@ -151,6 +227,7 @@ func (s *Server) handleAircraftByICAO(w http.ResponseWriter, r *http.Request, ic
 		return
 	}
 
+	ac.AgeSeconds = ageSeconds(ac.LastSeen, time.Now())
 	writeJSON(w, http.StatusOK, ac)
 }
 
@@ -229,17 +306,122 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, icao stri
 	writeJSON(w, http.StatusOK, positions)
 }
 
+const (
+	defaultProfileWindow = 24 * time.Hour
+	defaultProfilePoints = 500
+	maxProfilePoints     = 5000
+)
+
+// handleProfile returns an aircraft's positions between from and to, oldest
+// first and averaged down to at most points, for charting altitude and
+// speed over time. points=0 returns every stored position.
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request, icao string) {
+	if s.repo == nil {
+		http.Error(w, "History not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if t := query.Get("to"); t != "" {
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			http.Error(w, "Invalid to, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultProfileWindow)
+	if f := query.Get("from"); f != "" {
+		parsed, err := time.Parse(time.RFC3339, f)
+		if err != nil {
+			http.Error(w, "Invalid from, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	points := defaultProfilePoints
+	if p := query.Get("points"); p != "" {
+		parsed, err := strconv.Atoi(p)
+		if err != nil || parsed < 0 || parsed > maxProfilePoints {
+			http.Error(w, "Invalid points, expected 0 to "+strconv.Itoa(maxProfilePoints), http.StatusBadRequest)
+			return
+		}
+		points = parsed
+	}
+
+	positions, err := s.repo.GetPositionProfile(icao, from, to, points)
+	if err != nil {
+		http.Error(w, "Failed to get profile", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, positions)
+}
+
 func (s *Server) handleAircraftSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	query := r.URL.Query()
+	filters := parseSearchFilters(r.URL.Query())
+	aircraft := s.tracker.Search(filters)
+	if filters.Registration != "" && s.repo != nil {
+		aircraft = s.appendRegisteredAircraft(aircraft, filters)
+	}
+	setAges(aircraft, time.Now())
+	writeJSON(w, http.StatusOK, aircraft)
+}
+
+// appendRegisteredAircraft adds previously seen aircraft whose registry
+// record matches the registration exactly, so aircraft that are not being
+// tracked right now can still be found.
+func (s *Server) appendRegisteredAircraft(aircraft []models.Aircraft, filters tracker.SearchFilters) []models.Aircraft {
+	known, err := s.repo.GetAircraftByRegistration(filters.Registration)
+	if err != nil {
+		logger.Error("registration lookup failed", "registration", filters.Registration, "error", err)
+		return aircraft
+	}
+
+	// The registration already matched; the other filters still apply.
+	rest := filters
+	rest.Registration = ""
+
+	seen := make(map[string]bool, len(aircraft))
+	for _, ac := range aircraft {
+		seen[ac.ICAO] = true
+	}
+	for _, ac := range known {
+		if seen[ac.ICAO] {
+			continue
+		}
+		if live, ok := s.tracker.Get(ac.ICAO); ok {
+			// Tracked, but without the registration filled in yet.
+			live.Registration = ac.Registration
+			ac = live
+		}
+		if rest.Matches(&ac) {
+			aircraft = append(aircraft, ac)
+			seen[ac.ICAO] = true
+		}
+	}
+	return aircraft
+}
+
+// parseSearchFilters reads the search query params shared by the search and
+// count endpoints.
+func parseSearchFilters(query url.Values) tracker.SearchFilters {
 	filters := tracker.SearchFilters{
 		Callsign:     query.Get("callsign"),
 		AircraftType: query.Get("type"),
 		Registration: query.Get("registration"),
+		Category:     query.Get("category"),
 	}
 
 	if bounds := query.Get("bounds"); bounds != "" {
@@ -262,12 +444,22 @@ func (s *Server) handleAircraftSearch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	aircraft := s.tracker.Search(filters)
-	writeJSON(w, http.StatusOK, aircraft)
+	return filters
+}
+
+func (s *Server) handleAircraftCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count := s.tracker.CountMatching(parseSearchFilters(r.URL.Query()))
+	writeJSON(w, http.StatusOK, map[string]int{"count": count})
 }
 
 type receiverResponse struct {
-	NodeName string `json:"node_name"`
+	NodeName     string `json:"node_name"`
+	DistanceUnit string `json:"distance_unit"`
 }
 
 func (s *Server) handleReceiver(w http.ResponseWriter, r *http.Request) {
@@ -277,16 +469,20 @@ func (s *Server) handleReceiver(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, receiverResponse{
-		NodeName: s.nodeName,
+		NodeName:     s.nodeName,
+		DistanceUnit: string(s.distanceUnit()),
 	})
 }
 
 type healthResponse struct {
-	Status        string                           `json:"status"`
-	Uptime        string                           `json:"uptime"`
-	AircraftCount int                              `json:"aircraft_count"`
-	Ready         bool                             `json:"ready"`
-	Components    map[string]health.ComponentState `json:"components,omitempty"`
+	Status             string                           `json:"status"`
+	Uptime             string                           `json:"uptime"`
+	AircraftCount      int                              `json:"aircraft_count"`
+	Ready              bool                             `json:"ready"`
+	Components         map[string]health.ComponentState `json:"components,omitempty"`
+	Database           *health.DatabaseStatus           `json:"database,omitempty"`
+	Subscribers        []tracker.SubscriberStats        `json:"subscribers"`
+	EvictedSubscribers uint64                           `json:"evicted_subscribers"`
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -296,10 +492,12 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := healthResponse{
-		Status:        "ok",
-		Uptime:        time.Since(s.startTime).Round(time.Second).String(),
-		AircraftCount: s.tracker.Count(),
-		Ready:         true,
+		Status:             "ok",
+		Uptime:             time.Since(s.startTime).Round(time.Second).String(),
+		AircraftCount:      s.tracker.Count(),
+		Ready:              true,
+		Subscribers:        s.tracker.SubscriberStats(),
+		EvictedSubscribers: s.tracker.EvictedSubscribers(),
 	}
 	if s.readiness != nil {
 		resp.Ready = s.readiness.Ready()
@@ -308,6 +506,13 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 			resp.Status = "initializing"
 		}
 	}
+	if s.dbCheck != nil {
+		status := s.dbCheck.Status()
+		resp.Database = &status
+		if !status.Connected && !status.LastCheck.IsZero() {
+			resp.Status = "degraded"
+		}
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -316,7 +521,10 @@ type statsResponse struct {
 	AircraftNow  int     `json:"aircraft_now"`
 	TotalSeen    int     `json:"total_seen"`
 	MaxRangeNM   float64 `json:"max_range_nm"`
+	MaxRange     float64 `json:"max_range"`
+	DistanceUnit string  `json:"distance_unit"`
 	MaxRangeICAO string  `json:"max_range_icao,omitempty"`
+	Evicted      int     `json:"evicted"`
 }
 
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
@@ -331,11 +539,21 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		AircraftNow:  stats.AircraftCount,
 		TotalSeen:    stats.TotalSeen,
 		MaxRangeNM:   stats.MaxRangeNM,
+		MaxRange:     math.Round(s.distanceUnit().FromNM(stats.MaxRangeNM)*10) / 10,
+		DistanceUnit: string(s.distanceUnit()),
 		MaxRangeICAO: stats.MaxRangeICAO,
+		Evicted:      stats.Evicted,
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
+func (s *Server) distanceUnit() models.DistanceUnit {
+	if s.units == "" {
+		return models.UnitNM
+	}
+	return s.units
+}
+
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -513,6 +731,10 @@ func (s *Server) handleStatsRecent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to get recent aircraft", http.StatusInternalServerError)
 		return
 	}
+	if err := sortAircraft(aircraft, r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	writeJSON(w, http.StatusOK, aircraft)
 }
@@ -532,6 +754,28 @@ func (s *Server) handleReceiverHealth(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, stats)
 }
 
+func (s *Server) handleHealthHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.healthMonitor == nil {
+		http.Error(w, "Health monitor not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	minutes := 60
+	if m := r.URL.Query().Get("minutes"); m != "" {
+		if parsed, err := strconv.Atoi(m); err == nil && parsed > 0 && parsed <= 60 {
+			minutes = parsed
+		}
+	}
+
+	since := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	writeJSON(w, http.StatusOK, s.healthMonitor.History(since))
+}
+
 func (s *Server) handleReceiverFeed(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -558,12 +802,26 @@ func (s *Server) handleWebhookTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.webhooks.SendTestWebhook(); err != nil {
+	kind := r.URL.Query().Get("type")
+	if kind == "" {
+		if err := s.webhooks.SendTestWebhook(); err != nil {
+			http.Error(w, "Failed to send test webhook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "message": "Test webhook sent"})
+		return
+	}
+
+	if err := s.webhooks.SendTestEvent(r.Context(), kind); err != nil {
+		if errors.Is(err, webhook.ErrUnknownTestEvent) {
+			http.Error(w, "Unknown type, expected one of: "+strings.Join(webhook.TestEventKinds, ", "), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to send test webhook: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "message": "Test webhook sent"})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "message": "Test " + kind + " webhook sent"})
 }
 
 func (s *Server) handleStatsRange(w http.ResponseWriter, r *http.Request) {
@@ -581,6 +839,85 @@ func (s *Server) handleStatsRange(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, stats)
 }
 
+// handleRange returns the all-time range rings, or with ?window= the rings
+// computed from position history over that trailing window.
+func (s *Server) handleRange(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		s.handleStatsRange(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		http.Error(w, "Invalid window, expected a duration such as 24h", http.StatusBadRequest)
+		return
+	}
+	if s.repo == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+	rx := s.tracker.GetReceiverInfo()
+	if rx == nil {
+		http.Error(w, "Receiver location not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	bucketCount := rangetracker.DefaultBucketCount
+	if s.rangeTracker != nil {
+		bucketCount = s.rangeTracker.BucketCount()
+	}
+
+	dbStats, err := s.repo.RangeStatsSince(time.Now().Add(-d), rx.Lat, rx.Lon, bucketCount)
+	if err != nil {
+		logger.Error("windowed range query failed", "error", err)
+		http.Error(w, "Failed to get range stats", http.StatusInternalServerError)
+		return
+	}
+
+	buckets := make([]rangetracker.BucketStats, len(dbStats))
+	for i, b := range dbStats {
+		buckets[i] = rangetracker.BucketStats{
+			Bearing:      b.Bearing,
+			MaxRangeNM:   b.MaxRangeNM,
+			MaxRangeICAO: b.MaxRangeICAO,
+			ContactCount: b.ContactCount,
+		}
+	}
+	stats := rangetracker.StatsFromBuckets(bucketCount, buckets)
+	stats.Window = d.String()
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) handleRangeReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireAPIKey(w, r) {
+		return
+	}
+
+	if s.rangeTracker == nil {
+		http.Error(w, "Range tracking not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats, err := s.rangeTracker.Reset()
+	if err != nil {
+		logger.Error("failed to clear stored range stats", "error", err)
+		http.Error(w, "Failed to reset range stats", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("range statistics reset")
+	writeJSON(w, http.StatusOK, stats)
+}
+
 func (s *Server) handleStatsPeak(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -612,6 +949,17 @@ func (s *Server) handleFlights(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wantsCSV(r) {
+		limit := 1000
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 10000 {
+				limit = parsed
+			}
+		}
+		s.writeFlightsCSV(w, limit)
+		return
+	}
+
 	limit := 50
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
@@ -628,6 +976,43 @@ func (s *Server) handleFlights(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, flights)
 }
 
+type activeFlightResponse struct {
+	flight.ActiveFlight
+	DurationSeconds int64    `json:"duration_s"`
+	AvgSpeedKt      *float64 `json:"avg_speed_kt,omitempty"`
+}
+
+func (s *Server) handleActiveFlights(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.flightTracker == nil {
+		writeJSON(w, http.StatusOK, []interface{}{})
+		return
+	}
+
+	active := s.flightTracker.GetActiveFlights()
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].FirstSeen.Before(active[j].FirstSeen)
+	})
+
+	resp := make([]activeFlightResponse, len(active))
+	for i := range active {
+		f := &active[i]
+		resp[i] = activeFlightResponse{
+			ActiveFlight:    *f,
+			DurationSeconds: int64(f.Duration().Seconds()),
+		}
+		if speed, ok := f.AvgSpeedKt(); ok {
+			speed = math.Round(speed*10) / 10
+			resp[i].AvgSpeedKt = &speed
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (s *Server) handleFlightByID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"adsb-tracker/pkg/models"
+)
+
+// sortAircraft orders aircraft by the `sort` (distance, altitude, callsign,
+// last_seen) and `order` (asc, desc) query params. Aircraft missing the sort
+// field always go last. Without a sort param the slice is left untouched.
+func sortAircraft(aircraft []models.Aircraft, query url.Values) error {
+	key := strings.ToLower(query.Get("sort"))
+	if key == "" {
+		return nil
+	}
+
+	desc := false
+	switch strings.ToLower(query.Get("order")) {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return fmt.Errorf("invalid order %q (expected asc or desc)", query.Get("order"))
+	}
+
+	// compare returns <0, 0 or >0 for present values and reports whether
+	// each side has the field at all.
+	var compare func(a, b *models.Aircraft) (int, bool, bool)
+	switch key {
+	case "distance":
+		compare = func(a, b *models.Aircraft) (int, bool, bool) {
+			if a.DistanceNM == nil || b.DistanceNM == nil {
+				return 0, a.DistanceNM != nil, b.DistanceNM != nil
+			}
+			return cmpFloat(*a.DistanceNM, *b.DistanceNM), true, true
+		}
+	case "altitude":
+		compare = func(a, b *models.Aircraft) (int, bool, bool) {
+			if a.AltitudeFt == nil || b.AltitudeFt == nil {
+				return 0, a.AltitudeFt != nil, b.AltitudeFt != nil
+			}
+			return *a.AltitudeFt - *b.AltitudeFt, true, true
+		}
+	case "callsign":
+		compare = func(a, b *models.Aircraft) (int, bool, bool) {
+			if a.Callsign == "" || b.Callsign == "" {
+				return 0, a.Callsign != "", b.Callsign != ""
+			}
+			return strings.Compare(a.Callsign, b.Callsign), true, true
+		}
+	case "last_seen":
+		compare = func(a, b *models.Aircraft) (int, bool, bool) {
+			return a.LastSeen.Compare(b.LastSeen), true, true
+		}
+	default:
+		return fmt.Errorf("invalid sort %q (expected distance, altitude, callsign or last_seen)", key)
+	}
+
+	sort.SliceStable(aircraft, func(i, j int) bool {
+		c, hasI, hasJ := compare(&aircraft[i], &aircraft[j])
+		if !hasI || !hasJ {
+			return hasI && !hasJ
+		}
+		if c == 0 {
+			return aircraft[i].ICAO < aircraft[j].ICAO
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	return nil
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"os"
+
+	"adsb-tracker/web"
+)
+
+// webDir is where the built UI is looked for, relative to the working
+// directory, when it is not embedded in the binary.
+const webDir = "web/dist"
+
+//go:embed static/missing-ui.html
+var missingUIPage []byte
+
+// webHandler serves the UI compiled into the binary, or else the one in
+// webDir. Without either it serves a page explaining how to build the UI.
+func webHandler() http.Handler {
+	if web.Assets != nil {
+		return http.FileServer(http.FS(web.Assets))
+	}
+	if info, err := os.Stat(webDir); err == nil && info.IsDir() {
+		return http.FileServer(http.Dir(webDir))
+	}
+
+	logger.Warn("web UI not found, serving a placeholder page; build it or use -tags embedweb", "dir", webDir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(missingUIPage)
+	})
+}
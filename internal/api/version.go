@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// BuildInfo identifies the running build. Fields left empty are filled from
+// the VCS details Go embeds when building from a git checkout.
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildDate string
+}
+
+type versionResponse struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	BuildDate string    `json:"build_date,omitempty"`
+	Modified  bool      `json:"modified,omitempty"`
+	GoVersion string    `json:"go_version"`
+	Platform  string    `json:"platform"`
+	StartTime time.Time `json:"start_time"`
+	Uptime    string    `json:"uptime"`
+}
+
+func (s *Server) SetBuildInfo(info BuildInfo) {
+	s.buildInfo = info
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := versionResponse{
+		Version:   s.buildInfo.Version,
+		Commit:    s.buildInfo.Commit,
+		BuildDate: s.buildInfo.BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		StartTime: s.startTime.UTC(),
+		Uptime:    time.Since(s.startTime).Round(time.Second).String(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if resp.Commit == "" {
+					resp.Commit = setting.Value
+				}
+			case "vcs.time":
+				if resp.BuildDate == "" {
+					resp.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				resp.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if resp.Version == "" {
+		resp.Version = "dev"
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
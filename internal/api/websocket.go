@@ -2,15 +2,19 @@ package api
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"strings"
 	"sync"
 
+	"adsb-tracker/internal/logging"
 	"adsb-tracker/internal/tracker"
+	"adsb-tracker/pkg/models"
 
 	"github.com/gorilla/websocket"
 )
 
+var wsLogger = logging.Component("websocket")
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -20,9 +24,33 @@ var upgrader = websocket.Upgrader{
 }
 
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan []byte
+	filter clientFilter
+}
+
+// clientFilter narrows which events a client receives. It is only touched
+// by the hub's Run loop.
+type clientFilter struct {
+	icao string
+}
+
+func (f clientFilter) matches(ac *models.Aircraft) bool {
+	return f.icao == "" || ac.ICAO == f.icao
+}
+
+// clientMessage is what a client may send over the socket, e.g.
+// {"subscribe":{"icao":"A1B2C3"}}. An empty icao clears the filter.
+type clientMessage struct {
+	Subscribe *struct {
+		ICAO string `json:"icao"`
+	} `json:"subscribe"`
+}
+
+type subscription struct {
+	client *Client
+	filter clientFilter
 }
 
 type Hub struct {
@@ -31,6 +59,7 @@ type Hub struct {
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
+	subscribe  chan subscription
 	mu         sync.RWMutex
 }
 
@@ -41,12 +70,15 @@ func NewHub(t *tracker.Tracker) *Hub {
 		broadcast:  make(chan []byte, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		subscribe:  make(chan subscription),
 	}
 }
 
 func (h *Hub) Run() {
 	events := h.tracker.Subscribe()
-	defer h.tracker.Unsubscribe(events)
+	defer func() {
+		h.tracker.Unsubscribe(events)
+	}()
 
 	for {
 		select {
@@ -54,7 +86,7 @@ func (h *Hub) Run() {
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
-			log.Printf("[WS] Client connected, total: %d", len(h.clients))
+			wsLogger.Info("client connected", "clients", len(h.clients))
 
 		case client := <-h.unregister:
 			h.mu.Lock()
@@ -63,26 +95,23 @@ func (h *Hub) Run() {
 				close(client.send)
 			}
 			h.mu.Unlock()
-			log.Printf("[WS] Client disconnected, total: %d", len(h.clients))
-
-		case event := <-events:
-			msg := struct {
-				Event    string      `json:"event"`
-				Aircraft interface{} `json:"aircraft"`
-			}{
-				Aircraft: event.Aircraft,
-			}
-			switch event.Type {
-			case tracker.EventAdd:
-				msg.Event = "add"
-			case tracker.EventUpdate:
-				msg.Event = "update"
-			case tracker.EventRemove:
-				msg.Event = "remove"
+			wsLogger.Info("client disconnected", "clients", len(h.clients))
+
+		case sub := <-h.subscribe:
+			h.applySubscription(sub)
+
+		case event, ok := <-events:
+			if !ok {
+				wsLogger.Warn("tracker dropped hub subscription, resubscribing")
+				events = h.tracker.Subscribe()
+				continue
 			}
-			data, _ := json.Marshal(msg)
+			data := encodeEvent(event)
 			h.mu.RLock()
 			for client := range h.clients {
+				if !client.filter.matches(&event.Aircraft) {
+					continue
+				}
 				select {
 				case client.send <- data:
 				default:
@@ -95,10 +124,41 @@ func (h *Hub) Run() {
 	}
 }
 
+// applySubscription sets a client's filter and sends it the current state of
+// the aircraft it subscribed to, if tracked.
+func (h *Hub) applySubscription(sub subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client := sub.client
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	client.filter = sub.filter
+	if sub.filter.icao == "" {
+		return
+	}
+
+	ac, ok := h.tracker.Get(sub.filter.icao)
+	if !ok {
+		return
+	}
+	data, _ := json.Marshal(struct {
+		Event    string          `json:"event"`
+		Aircraft models.Aircraft `json:"aircraft"`
+	}{Event: "snapshot", Aircraft: ac})
+	select {
+	case client.send <- data:
+	default:
+		close(client.send)
+		delete(h.clients, client)
+	}
+}
+
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("[WS] Upgrade error: %v", err)
+		wsLogger.Warn("upgrade failed", "error", err)
 		return
 	}
 
@@ -120,10 +180,17 @@ func (c *Client) readPump() {
 	}()
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
+
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Subscribe == nil {
+			continue
+		}
+		icao := strings.ToUpper(strings.TrimSpace(msg.Subscribe.ICAO))
+		c.hub.subscribe <- subscription{client: c, filter: clientFilter{icao: icao}}
 	}
 }
 
@@ -137,3 +204,27 @@ func (c *Client) writePump() {
 	}
 }
 
+// eventName maps a tracker event type to the name clients see.
+func eventName(t tracker.EventType) string {
+	switch t {
+	case tracker.EventAdd:
+		return "add"
+	case tracker.EventUpdate:
+		return "update"
+	case tracker.EventRemove:
+		return "remove"
+	}
+	return ""
+}
+
+func encodeEvent(event tracker.AircraftEvent) []byte {
+	msg := struct {
+		Event    string      `json:"event"`
+		Aircraft interface{} `json:"aircraft"`
+	}{
+		Event:    eventName(event.Type),
+		Aircraft: event.Aircraft,
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
@@ -0,0 +1,131 @@
+package autogain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"adsb-tracker/internal/config"
+	"adsb-tracker/internal/dump1090"
+	"adsb-tracker/internal/logging"
+)
+
+var logger = logging.Component("autogain")
+
+const (
+	sampleInterval = 5 * time.Second
+	tolerance      = 0.2
+)
+
+type RateSource interface {
+	MessageRate() (perSec float64, connected bool)
+}
+
+type GainSetter interface {
+	Gain() float64
+	SetGain(gain float64) error
+}
+
+// Controller nudges the receiver gain one step at a time so the feed message
+// rate settles near the configured target. Below the target the gain is
+// raised; above it the gain is lowered to reject noise and overload.
+type Controller struct {
+	cfg    config.AutoGainConfig
+	source RateSource
+	gain   GainSetter
+
+	mu         sync.Mutex
+	sum        float64
+	samples    int
+	lastChange time.Time
+}
+
+func New(cfg config.AutoGainConfig, source RateSource, gain GainSetter) *Controller {
+	if cfg.AdjustmentInterval <= 0 {
+		cfg.AdjustmentInterval = 5 * time.Minute
+	}
+	return &Controller{
+		cfg:        cfg,
+		source:     source,
+		gain:       gain,
+		lastChange: time.Now(),
+	}
+}
+
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	logger.Info("auto gain running", "target_msg_per_sec", c.cfg.TargetMessagesPerSec, "interval", c.cfg.AdjustmentInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.sample()
+		}
+	}
+}
+
+func (c *Controller) sample() {
+	rate, connected := c.source.MessageRate()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !connected {
+		return
+	}
+	c.sum += rate
+	c.samples++
+
+	if time.Since(c.lastChange) < c.cfg.AdjustmentInterval || c.samples == 0 {
+		return
+	}
+
+	avg := c.sum / float64(c.samples)
+	c.sum = 0
+	c.samples = 0
+	c.lastChange = time.Now()
+
+	current := c.gain.Gain()
+	next := nextGain(current, avg, float64(c.cfg.TargetMessagesPerSec))
+	if next == current {
+		return
+	}
+
+	logger.Info("changing gain", "avg_msg_per_sec", avg, "target_msg_per_sec", c.cfg.TargetMessagesPerSec,
+		"from_db", current, "to_db", next)
+	if err := c.gain.SetGain(next); err != nil {
+		logger.Error("failed to apply gain", "error", err)
+	}
+}
+
+func nextGain(current, rate, target float64) float64 {
+	idx := closestGainIndex(current)
+	switch {
+	case rate < target*(1-tolerance) && idx < len(dump1090.Gains)-1:
+		idx++
+	case rate > target*(1+tolerance) && idx > 0:
+		idx--
+	}
+	return dump1090.Gains[idx]
+}
+
+func closestGainIndex(gain float64) int {
+	best := 0
+	for i, g := range dump1090.Gains {
+		if abs(g-gain) < abs(dump1090.Gains[best]-gain) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
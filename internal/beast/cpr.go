@@ -9,12 +9,17 @@ import (
 const (
 	cprMaxDelta = 10 * time.Second
 	nzLat       = 15.0
+
+	// maxAircraftSpeedNM bounds how far an aircraft can move between the
+	// even and odd frames of a pair, in NM per second (about 1300 kt).
+	maxAircraftSpeedNM = 0.36
 )
 
 type CPRFrame struct {
 	Lat       uint32
 	Lon       uint32
 	Odd       bool
+	Surface   bool
 	Timestamp time.Time
 }
 
@@ -30,6 +35,9 @@ type CPRDecoder struct {
 type Position struct {
 	Lat float64
 	Lon float64
+	// Global is set when the position traces back to a global (even/odd
+	// pair) decode rather than only a local decode against the receiver.
+	Global bool
 }
 
 func NewCPRDecoder() *CPRDecoder {
@@ -48,6 +56,17 @@ func (d *CPRDecoder) SetReference(lat, lon float64) {
 }
 
 func (d *CPRDecoder) AddFrame(icao string, lat, lon uint32, odd bool) (float64, float64, bool) {
+	return d.addFrame(icao, lat, lon, odd, false)
+}
+
+// AddSurfaceFrame decodes a surface position. Surface CPR only resolves a
+// position within a 90 degree quadrant, so decoding needs a reference: the
+// aircraft's last known position or the receiver location.
+func (d *CPRDecoder) AddSurfaceFrame(icao string, lat, lon uint32, odd bool) (float64, float64, bool) {
+	return d.addFrame(icao, lat, lon, odd, true)
+}
+
+func (d *CPRDecoder) addFrame(icao string, lat, lon uint32, odd, surface bool) (float64, float64, bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
@@ -56,6 +75,7 @@ func (d *CPRDecoder) AddFrame(icao string, lat, lon uint32, odd bool) (float64,
 		Lat:       lat,
 		Lon:       lon,
 		Odd:       odd,
+		Surface:   surface,
 		Timestamp: now,
 	}
 
@@ -68,38 +88,78 @@ func (d *CPRDecoder) AddFrame(icao string, lat, lon uint32, odd bool) (float64,
 	d.frames[icao] = frames
 
 	var refLat, refLon float64
-	var hasRef bool
+	var hasRef, lastGlobal bool
 
 	if pos, ok := d.lastPos[icao]; ok {
 		refLat = pos.Lat
 		refLon = pos.Lon
 		hasRef = true
+		lastGlobal = pos.Global
 	} else if d.hasRef {
 		refLat = d.refLat
 		refLon = d.refLon
 		hasRef = true
 	}
 
-	if hasRef {
-		decodedLat, decodedLon, ok := decodeLocalCPR(lat, lon, odd, refLat, refLon)
-		if ok {
+	// Once a global decode has anchored the aircraft, single frames can be
+	// decoded locally against it.
+	if lastGlobal {
+		if decodedLat, decodedLon, ok := decodeLocalCPR(lat, lon, odd, surface, refLat, refLon); ok {
+			d.lastPos[icao] = &Position{Lat: decodedLat, Lon: decodedLon, Global: true}
+			return decodedLat, decodedLon, true
+		}
+	}
+
+	if !hasRef && surface {
+		return 0, 0, false
+	}
+
+	if decodedLat, decodedLon, ok := d.decodePair(frames, now, odd, surface, refLat, refLon); ok {
+		d.lastPos[icao] = &Position{Lat: decodedLat, Lon: decodedLon, Global: true}
+		return decodedLat, decodedLon, true
+	}
+
+	// Without a usable pair, fall back to a local decode against the
+	// receiver or an unconfirmed previous position.
+	if hasRef && !lastGlobal {
+		if decodedLat, decodedLon, ok := decodeLocalCPR(lat, lon, odd, surface, refLat, refLon); ok {
 			d.lastPos[icao] = &Position{Lat: decodedLat, Lon: decodedLon}
 			return decodedLat, decodedLon, true
 		}
 	}
 
+	return 0, 0, false
+}
+
+func (d *CPRDecoder) decodePair(frames [2]*CPRFrame, now time.Time, odd, surface bool, refLat, refLon float64) (float64, float64, bool) {
 	even := frames[0]
 	oddFrame := frames[1]
 
-	if even == nil || oddFrame == nil {
+	// A pair is only usable if both frames describe the same kind of
+	// position and were received close together; pairing a fresh frame with
+	// a stale one of the other parity can land in the wrong latitude zone.
+	if even == nil || oddFrame == nil || even.Surface != oddFrame.Surface {
 		return 0, 0, false
 	}
 
-	if now.Sub(even.Timestamp) > cprMaxDelta || now.Sub(oddFrame.Timestamp) > cprMaxDelta {
+	gap := even.Timestamp.Sub(oddFrame.Timestamp)
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > cprMaxDelta || now.Sub(even.Timestamp) > cprMaxDelta || now.Sub(oddFrame.Timestamp) > cprMaxDelta {
 		return 0, 0, false
 	}
-
-	decodedLat, decodedLon, ok := decodeCPR(even.Lat, even.Lon, oddFrame.Lat, oddFrame.Lon, odd)
+	// Allow for how far the aircraft could have moved between the frames,
+	// plus a little slack for CPR quantisation.
+	maxMoveNM := gap.Seconds()*maxAircraftSpeedNM + 0.5
+
+	var decodedLat, decodedLon float64
+	var ok bool
+	if surface {
+		decodedLat, decodedLon, ok = decodeSurfaceCPR(even.Lat, even.Lon, oddFrame.Lat, oddFrame.Lon, odd, refLat, refLon, maxMoveNM)
+	} else {
+		decodedLat, decodedLon, ok = decodeCPR(even.Lat, even.Lon, oddFrame.Lat, oddFrame.Lon, odd, maxMoveNM)
+	}
 	if !ok {
 		return 0, 0, false
 	}
@@ -111,21 +171,36 @@ func (d *CPRDecoder) AddFrame(icao string, lat, lon uint32, odd bool) (float64,
 		}
 	}
 
-	d.lastPos[icao] = &Position{Lat: decodedLat, Lon: decodedLon}
 	return decodedLat, decodedLon, true
 }
 
-func decodeLocalCPR(cprLat, cprLon uint32, odd bool, refLat, refLon float64) (float64, float64, bool) {
+// IsGlobal reports whether the aircraft's current position is anchored by a
+// global decode.
+func (d *CPRDecoder) IsGlobal(icao string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	pos, ok := d.lastPos[icao]
+	return ok && pos.Global
+}
+
+// decodeLocalCPR resolves a single frame against a nearby reference. The
+// reference must be within half a zone: 180 NM airborne, 45 NM on the surface.
+func decodeLocalCPR(cprLat, cprLon uint32, odd, surface bool, refLat, refLon float64) (float64, float64, bool) {
 	const cprScale = 131072.0
 
 	latCpr := float64(cprLat) / cprScale
 	lonCpr := float64(cprLon) / cprScale
 
+	span, maxDist := 360.0, 180.0
+	if surface {
+		span, maxDist = 90.0, 45.0
+	}
+
 	var dLat float64
 	if odd {
-		dLat = 360.0 / 59.0
+		dLat = span / 59.0
 	} else {
-		dLat = 360.0 / 60.0
+		dLat = span / 60.0
 	}
 
 	j := math.Floor(refLat/dLat) + math.Floor(0.5+mod(refLat, dLat)/dLat-latCpr)
@@ -146,7 +221,7 @@ func decodeLocalCPR(cprLat, cprLon uint32, odd bool, refLat, refLon float64) (fl
 		nlVal = 1
 	}
 
-	dLon := 360.0 / float64(nlVal)
+	dLon := span / float64(nlVal)
 	m := math.Floor(refLon/dLon) + math.Floor(0.5+mod(refLon, dLon)/dLon-lonCpr)
 	lon := dLon * (m + lonCpr)
 
@@ -158,14 +233,18 @@ func decodeLocalCPR(cprLat, cprLon uint32, odd bool, refLat, refLon float64) (fl
 	}
 
 	dist := quickDist(refLat, refLon, lat, lon)
-	if dist > 180 {
+	if dist > maxDist {
 		return 0, 0, false
 	}
 
 	return lat, lon, true
 }
 
-func decodeCPR(evenLat, evenLon, oddLat, oddLon uint32, useOdd bool) (float64, float64, bool) {
+// decodeCPR performs a global airborne decode of an even/odd pair. Both
+// frames are decoded and must land within maxMoveNM of each other; a pair
+// whose frames came from different places resolves to two positions that
+// disagree, even when each looks plausible on its own.
+func decodeCPR(evenLat, evenLon, oddLat, oddLon uint32, useOdd bool, maxMoveNM float64) (float64, float64, bool) {
 	const cprScale = 131072.0
 
 	latEven := float64(evenLat) / cprScale
@@ -185,6 +264,10 @@ func decodeCPR(evenLat, evenLon, oddLat, oddLon uint32, useOdd bool) (float64, f
 		latO -= 360
 	}
 
+	if latE < -90 || latE > 90 || latO < -90 || latO > 90 {
+		return 0, 0, false
+	}
+
 	nlE := nl(latE)
 	nlO := nl(latO)
 
@@ -192,35 +275,98 @@ func decodeCPR(evenLat, evenLon, oddLat, oddLon uint32, useOdd bool) (float64, f
 		return 0, 0, false
 	}
 
-	var lat, lon float64
+	// Odd frames divide longitude into NL-1 zones.
+	n := float64(nlE)
+	ni := math.Max(n-1, 1)
+	m := math.Floor(lonEven*(n-1) - lonOdd*n + 0.5)
+	lonE := normalizeLon((360.0 / n) * (mod(m, n) + lonEven))
+	lonO := normalizeLon((360.0 / ni) * (mod(m, ni) + lonOdd))
+
+	if pairDist(latE, lonE, latO, lonO) > maxMoveNM {
+		return 0, 0, false
+	}
 
 	if useOdd {
-		lat = latO
-		if nlO > 0 {
-			m := math.Floor(lonEven*(float64(nlO)-1) - lonOdd*float64(nlO) + 0.5)
-			lon = (360.0 / float64(nlO)) * (mod(m, float64(nlO)) + lonOdd)
-		} else {
-			lon = lonOdd * 360.0
-		}
-	} else {
-		lat = latE
-		if nlE > 0 {
-			m := math.Floor(lonEven*(float64(nlE)-1) - lonOdd*float64(nlE) + 0.5)
-			lon = (360.0 / float64(nlE)) * (mod(m, float64(nlE)) + lonEven)
-		} else {
-			lon = lonEven * 360.0
-		}
+		return latO, lonO, true
 	}
+	return latE, lonE, true
+}
 
-	if lon >= 180 {
-		lon -= 360
+// decodeSurfaceCPR performs a global decode of a surface even/odd pair.
+// Surface encoding repeats every 90 degrees, so of the candidate positions
+// the one nearest the reference is chosen.
+func decodeSurfaceCPR(evenLat, evenLon, oddLat, oddLon uint32, useOdd bool, refLat, refLon, maxMoveNM float64) (float64, float64, bool) {
+	const cprScale = 131072.0
+
+	latEven := float64(evenLat) / cprScale
+	latOdd := float64(oddLat) / cprScale
+	lonEven := float64(evenLon) / cprScale
+	lonOdd := float64(oddLon) / cprScale
+
+	j := math.Floor(59*latEven - 60*latOdd + 0.5)
+
+	latE := (90.0 / 60.0) * (mod(j, 60) + latEven)
+	latO := (90.0 / 59.0) * (mod(j, 59) + latOdd)
+
+	// Both hemispheres are valid solutions; pick the one nearer the reference.
+	if math.Abs(refLat-(latE-90)) < math.Abs(refLat-latE) {
+		latE -= 90
+	}
+	if math.Abs(refLat-(latO-90)) < math.Abs(refLat-latO) {
+		latO -= 90
 	}
 
-	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+	nlE := nl(latE)
+	nlO := nl(latO)
+	if nlE != nlO {
 		return 0, 0, false
 	}
 
-	return lat, lon, true
+	n := float64(nlE)
+	ni := math.Max(n-1, 1)
+	m := math.Floor(lonEven*(n-1) - lonOdd*n + 0.5)
+	lonE := nearestQuadrant((90.0/n)*(mod(m, n)+lonEven), refLon)
+	lonO := nearestQuadrant((90.0/ni)*(mod(m, ni)+lonOdd), refLon)
+
+	if pairDist(latE, lonE, latO, lonO) > maxMoveNM {
+		return 0, 0, false
+	}
+
+	if useOdd {
+		return latO, lonO, true
+	}
+	return latE, lonE, true
+}
+
+// nearestQuadrant returns whichever of lon + k*90 lies closest to refLon.
+func nearestQuadrant(lon, refLon float64) float64 {
+	best := lon
+	for k := 1; k < 4; k++ {
+		candidate := lon + float64(k)*90
+		if math.Abs(angleDiff(candidate, refLon)) < math.Abs(angleDiff(best, refLon)) {
+			best = candidate
+		}
+	}
+	return normalizeLon(best)
+}
+
+func normalizeLon(lon float64) float64 {
+	lon = mod(lon+180, 360) - 180
+	return lon
+}
+
+// pairDist is quickDist with the longitude difference wrapped, so positions
+// either side of the antimeridian compare correctly.
+func pairDist(lat1, lon1, lat2, lon2 float64) float64 {
+	return quickDist(lat1, 0, lat2, angleDiff(lon2, lon1))
+}
+
+func angleDiff(a, b float64) float64 {
+	d := mod(a-b, 360)
+	if d > 180 {
+		d -= 360
+	}
+	return d
 }
 
 func quickDist(lat1, lon1, lat2, lon2 float64) float64 {
@@ -0,0 +1,190 @@
+package beast
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+)
+
+func mustMessage(t *testing.T, s string) *Message {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return &Message{Type: TypeModeLong, Data: data}
+}
+
+func cprFields(t *testing.T, s string) (uint32, uint32) {
+	t.Helper()
+	data := mustMessage(t, s).Data
+	me := data[4:11]
+	lat := (uint32(me[2]&0x03) << 15) | (uint32(me[3]) << 7) | (uint32(me[4]) >> 1)
+	lon := (uint32(me[4]&0x01) << 16) | (uint32(me[5]) << 8) | uint32(me[6])
+	return lat, lon
+}
+
+// Surface pair from "The 1090 Megahertz Riddle", decoded near Schiphol.
+const (
+	surfaceEven = "8C4841753AAB238733C8CD4020B1"
+	surfaceOdd  = "8C4841753A8A35323FAEBDAC702D"
+	schipholLat = 51.990
+	schipholLon = 4.375
+)
+
+func TestDecodeSurfacePosition(t *testing.T) {
+	p := NewParser()
+	p.SetReceiverLocation(schipholLat, schipholLon)
+
+	p.Decode(mustMessage(t, surfaceEven))
+	ac := p.Decode(mustMessage(t, surfaceOdd))
+	if ac == nil || ac.Lat == nil || ac.Lon == nil {
+		t.Fatal("expected a surface position")
+	}
+	if math.Abs(*ac.Lat-52.32061) > 0.0001 || math.Abs(*ac.Lon-4.73473) > 0.0001 {
+		t.Fatalf("unexpected position %.5f, %.5f", *ac.Lat, *ac.Lon)
+	}
+	if ac.OnGround == nil || !*ac.OnGround {
+		t.Fatal("expected on_ground to be set")
+	}
+	if ac.SpeedKt == nil || *ac.SpeedKt != 17 {
+		t.Fatalf("expected 17 kt ground speed, got %v", ac.SpeedKt)
+	}
+	if ac.Heading == nil || math.Abs(*ac.Heading-98.4375) > 0.001 {
+		t.Fatalf("expected track 98.4, got %v", ac.Heading)
+	}
+}
+
+func TestDecodeSurfaceGlobalCPR(t *testing.T) {
+	evenLat, evenLon := cprFields(t, surfaceEven)
+	oddLat, oddLon := cprFields(t, surfaceOdd)
+
+	lat, lon, ok := decodeSurfaceCPR(evenLat, evenLon, oddLat, oddLon, true, schipholLat, schipholLon, 2)
+	if !ok {
+		t.Fatal("global surface decode failed")
+	}
+	if math.Abs(lat-52.32061) > 0.0001 || math.Abs(lon-4.73473) > 0.0001 {
+		t.Fatalf("unexpected position %.5f, %.5f", lat, lon)
+	}
+}
+
+func TestSurfaceFrameNeedsReference(t *testing.T) {
+	d := NewCPRDecoder()
+	evenLat, evenLon := cprFields(t, surfaceEven)
+	oddLat, oddLon := cprFields(t, surfaceOdd)
+
+	d.AddSurfaceFrame("484175", evenLat, evenLon, false)
+	if _, _, ok := d.AddSurfaceFrame("484175", oddLat, oddLon, true); ok {
+		t.Fatal("surface decode without a reference should fail")
+	}
+}
+
+// encodeCPR produces airborne CPR fields for a position, for building test
+// frames.
+func encodeCPR(lat, lon float64, odd bool) (uint32, uint32) {
+	const scale = 131072.0
+	i := 0.0
+	if odd {
+		i = 1
+	}
+	dLat := 360.0 / (60 - i)
+	yz := math.Floor(scale*mod(lat, dLat)/dLat + 0.5)
+	rLat := dLat * (yz/scale + math.Floor(lat/dLat))
+	dLon := 360.0 / math.Max(float64(nl(rLat))-i, 1)
+	xz := math.Floor(scale*mod(lon, dLon)/dLon + 0.5)
+	return uint32(yz) & 0x1ffff, uint32(xz) & 0x1ffff
+}
+
+func TestGlobalCPRDecodesAirbornePair(t *testing.T) {
+	evenLat, evenLon := cprFields(t, "8D40621D58C382D690C8AC2863A7")
+	oddLat, oddLon := cprFields(t, "8D40621D58C386435CC412692AD6")
+
+	lat, lon, ok := decodeCPR(evenLat, evenLon, oddLat, oddLon, false, 2)
+	if !ok || math.Abs(lat-52.25720) > 0.0001 || math.Abs(lon-3.91937) > 0.0001 {
+		t.Fatalf("even decode: got %.5f, %.5f (ok=%v)", lat, lon, ok)
+	}
+
+	lat, lon, ok = decodeCPR(evenLat, evenLon, oddLat, oddLon, true, 2)
+	if !ok || math.Abs(lat-52.26578) > 0.0001 || math.Abs(lon-3.93891) > 0.0001 {
+		t.Fatalf("odd decode: got %.5f, %.5f (ok=%v)", lat, lon, ok)
+	}
+}
+
+func TestGlobalCPRRejectsMismatchedFrames(t *testing.T) {
+	d := NewCPRDecoder()
+
+	// An even frame from a different latitude zone paired with a fresh odd
+	// frame, both inside cprMaxDelta of now. Without the even/odd agreement
+	// check this decodes to -81.97, 19.69, and with no receiver reference
+	// there is no range gate to catch it.
+	evenLat, evenLon := encodeCPR(50.0, 3.9, false)
+	oddLat, oddLon := encodeCPR(52.26578, 3.93891, true)
+
+	d.AddFrame("40621D", evenLat, evenLon, false)
+	d.mu.Lock()
+	frames := d.frames["40621D"]
+	frames[0].Timestamp = time.Now().Add(-5 * time.Second)
+	d.mu.Unlock()
+
+	if lat, lon, ok := d.AddFrame("40621D", oddLat, oddLon, true); ok {
+		t.Fatalf("expected mismatched pair to be rejected, decoded %.5f, %.5f", lat, lon)
+	}
+}
+
+func TestGlobalCPRRejectsFramesFarApartInTime(t *testing.T) {
+	d := NewCPRDecoder()
+	evenLat, evenLon := cprFields(t, "8D40621D58C382D690C8AC2863A7")
+	oddLat, oddLon := cprFields(t, "8D40621D58C386435CC412692AD6")
+
+	d.AddFrame("40621D", evenLat, evenLon, false)
+	d.mu.Lock()
+	d.frames["40621D"][0].Timestamp = time.Now().Add(-cprMaxDelta - time.Second)
+	d.mu.Unlock()
+
+	if _, _, ok := d.AddFrame("40621D", oddLat, oddLon, true); ok {
+		t.Fatal("expected a pair further apart than cprMaxDelta to be rejected")
+	}
+}
+
+func TestGlobalCPRRejectsSurfaceAirborneMix(t *testing.T) {
+	d := NewCPRDecoder()
+	evenLat, evenLon := cprFields(t, "8D40621D58C382D690C8AC2863A7")
+	oddLat, oddLon := cprFields(t, "8D40621D58C386435CC412692AD6")
+
+	d.AddFrame("40621D", evenLat, evenLon, false)
+	d.mu.Lock()
+	d.frames["40621D"][0].Surface = true
+	d.mu.Unlock()
+
+	if _, _, ok := d.AddFrame("40621D", oddLat, oddLon, true); ok {
+		t.Fatal("expected a surface frame not to pair with an airborne frame")
+	}
+}
+
+func TestCPRPrefersGlobalDecodeOverReceiverLocal(t *testing.T) {
+	d := NewCPRDecoder()
+	d.SetReference(52.0, 4.0)
+
+	evenLat, evenLon := cprFields(t, "8D40621D58C382D690C8AC2863A7")
+	oddLat, oddLon := cprFields(t, "8D40621D58C386435CC412692AD6")
+
+	if _, _, ok := d.AddFrame("40621D", evenLat, evenLon, false); !ok {
+		t.Fatal("expected local decode against the receiver")
+	}
+	if d.IsGlobal("40621D") {
+		t.Fatal("receiver-relative decode should not be marked global")
+	}
+	// The book frames were received a few seconds apart.
+	d.mu.Lock()
+	d.frames["40621D"][0].Timestamp = time.Now().Add(-5 * time.Second)
+	d.mu.Unlock()
+
+	lat, lon, ok := d.AddFrame("40621D", oddLat, oddLon, true)
+	if !ok || math.Abs(lat-52.26578) > 0.0001 || math.Abs(lon-3.93891) > 0.0001 {
+		t.Fatalf("pair decode: got %.5f, %.5f (ok=%v)", lat, lon, ok)
+	}
+	if !d.IsGlobal("40621D") {
+		t.Fatal("expected position to be anchored by the global decode")
+	}
+}
@@ -1,6 +1,7 @@
 package beast
 
 import (
+	"sync"
 	"time"
 
 	"adsb-tracker/pkg/models"
@@ -12,6 +13,10 @@ const (
 	TypeModeAC    = '1'
 	TypeModeShort = '2'
 	TypeModeLong  = '3'
+
+	// mlatTimestamp is the magic timestamp ("\xff\x00MLAT") mlat-client
+	// writes on positions it has multilaterated.
+	mlatTimestamp = 0xFF004D4C4154
 )
 
 type Message struct {
@@ -23,11 +28,37 @@ type Message struct {
 
 type Parser struct {
 	cpr *CPRDecoder
+
+	statusMu sync.Mutex
+	status   map[string]opStatus
+
+	baroMu sync.Mutex
+	baro   map[string]baroFix
+}
+
+// baroFix is the last barometric altitude decoded for an aircraft, used to
+// turn the GNSS/baro difference in velocity messages into a GNSS altitude.
+type baroFix struct {
+	alt  int
+	seen time.Time
+}
+
+const baroFixMaxAge = 30 * time.Second
+
+// opStatus is the last Operational Status (TC 31) seen for an aircraft.
+// The NIC supplement it carries is needed to turn a position message's
+// type code into a NIC value.
+type opStatus struct {
+	version int
+	nicA    bool
+	seen    time.Time
 }
 
 func NewParser() *Parser {
 	return &Parser{
-		cpr: NewCPRDecoder(),
+		cpr:    NewCPRDecoder(),
+		status: make(map[string]opStatus),
+		baro:   make(map[string]baroFix),
 	}
 }
 
@@ -115,13 +146,22 @@ func (p *Parser) Decode(msg *Message) *models.Aircraft {
 		return nil
 	}
 
+	source, nonICAO, ok := messageSource(msg, df)
+	if !ok {
+		return nil
+	}
+
 	icao := icaoFromData(msg.Data)
 	if icao == "" {
 		return nil
 	}
+	if nonICAO {
+		icao = models.NonICAOPrefix + icao
+	}
 
 	ac := &models.Aircraft{
 		ICAO:     icao,
+		Source:   source,
 		LastSeen: time.Now().UTC(),
 	}
 
@@ -134,17 +174,55 @@ func (p *Parser) Decode(msg *Message) *models.Aircraft {
 	switch {
 	case tc >= 1 && tc <= 4:
 		parseIdent(me, ac)
+	case tc >= 5 && tc <= 8:
+		p.parseSurface(me, ac, icao)
+		p.setNIC(ac, icao, tc)
 	case tc >= 9 && tc <= 18:
 		p.parseAirborne(me, ac, icao)
+		p.setNIC(ac, icao, tc)
 	case tc == 19:
-		parseVelocity(me, ac)
+		p.parseVelocity(me, ac, icao)
 	case tc >= 20 && tc <= 22:
 		p.parseAirborne(me, ac, icao)
+		p.setNIC(ac, icao, tc)
+	case tc == 29:
+		parseTargetState(me, ac)
+	case tc == 31:
+		p.parseOperationalStatus(me, ac, icao)
 	}
 
 	return ac
 }
 
+// messageSource classifies an extended squitter by how it reached us. DF18
+// frames are split on their CF subfield. CF 1 and 5 carry an anonymous or
+// track file address rather than an ICAO one, reported as nonICAO. Coarse
+// TIS-B (CF 3) packs its position differently, and the TIS-B/ADS-R
+// management and reserved formats don't carry a regular ME field, so those
+// are dropped.
+func messageSource(msg *Message, df byte) (source string, nonICAO, ok bool) {
+	if msg.Timestamp == mlatTimestamp {
+		return models.SourceMLAT, false, true
+	}
+	if df == 17 {
+		return models.SourceADSB, false, true
+	}
+
+	switch msg.Data[0] & 0x07 {
+	case 0:
+		return models.SourceADSB, false, true
+	case 1:
+		return models.SourceADSB, true, true
+	case 2:
+		return models.SourceTISB, false, true
+	case 5:
+		return models.SourceTISB, true, true
+	case 6:
+		return models.SourceADSR, false, true
+	}
+	return "", false, false
+}
+
 func icaoFromData(data []byte) string {
 	if len(data) < 4 {
 		return ""
@@ -188,6 +266,12 @@ func parseIdent(me []byte, ac *models.Aircraft) {
 	if callsign != "" {
 		ac.Callsign = callsign
 	}
+
+	// TC 4, 3, 2 and 1 are category sets A to D; CA 0 means no category.
+	tc := (me[0] >> 3) & 0x1f
+	if ca := me[0] & 0x07; ca != 0 {
+		ac.EmitterCategory = string(rune('A'+4-tc)) + string(rune('0'+ca))
+	}
 }
 
 func (p *Parser) parseAirborne(me []byte, ac *models.Aircraft, icao string) {
@@ -198,19 +282,28 @@ func (p *Parser) parseAirborne(me []byte, ac *models.Aircraft, icao string) {
 	tc := (me[0] >> 3) & 0x1f
 
 	altCode := (uint(me[1])<<4 | uint(me[2])>>4) & 0xfff
-	if altCode > 0 {
+	switch {
+	case altCode == 0:
+	case tc >= 20:
+		// TC 20-22 carry GNSS height in metres rather than pressure altitude.
+		alt := int(float64(altCode)*3.28084 + 0.5)
+		if alt < 60000 {
+			ac.AltitudeGNSS = &alt
+		}
+	default:
 		var alt int
-		if tc < 20 {
-			qBit := (altCode >> 4) & 1
-			if qBit == 1 {
-				n := ((altCode & 0xf) | ((altCode >> 1) & 0x7f0))
-				alt = int(n)*25 - 1000
-			}
-		} else {
-			alt = int(altCode) * 25
+		qBit := (altCode >> 4) & 1
+		if qBit == 1 {
+			n := ((altCode & 0xf) | ((altCode >> 1) & 0x7f0))
+			alt = int(n)*25 - 1000
+		} else if a, ok := gillhamAltitude(altCode); ok {
+			alt = a
 		}
 		if alt > -1000 && alt < 60000 {
 			ac.AltitudeFt = &alt
+			p.baroMu.Lock()
+			p.baro[icao] = baroFix{alt: alt, seen: time.Now()}
+			p.baroMu.Unlock()
 		}
 	}
 
@@ -221,10 +314,247 @@ func (p *Parser) parseAirborne(me []byte, ac *models.Aircraft, icao string) {
 	if lat, lon, ok := p.cpr.AddFrame(icao, cprLat, cprLon, oddFlag == 1); ok {
 		ac.Lat = &lat
 		ac.Lon = &lon
+		global := p.cpr.IsGlobal(icao)
+		ac.PositionGlobal = &global
+	}
+}
+
+// gillhamAltitude decodes a 12-bit altitude field with the Q bit clear,
+// which carries the 100 ft Gillham (Mode C) Gray code. The field is laid out
+// C1 A1 C2 A2 C4 A4 B1 Q B2 D2 B4 D4.
+func gillhamAltitude(code uint) (int, bool) {
+	bit := func(n uint) bool { return code&(1<<n) != 0 }
+
+	c1, a1, c2, a2, c4, a4 := bit(11), bit(10), bit(9), bit(8), bit(7), bit(6)
+	b1, b2, d2, b4, d4 := bit(5), bit(3), bit(2), bit(1), bit(0)
+
+	// C bits give the 100 ft step within a 500 ft band; all clear is illegal.
+	if !c1 && !c2 && !c4 {
+		return 0, false
+	}
+	oneHundreds := 0
+	if c1 {
+		oneHundreds ^= 7
+	}
+	if c2 {
+		oneHundreds ^= 3
+	}
+	if c4 {
+		oneHundreds ^= 1
+	}
+	if oneHundreds&5 == 5 {
+		oneHundreds ^= 2
+	}
+	if oneHundreds > 5 {
+		return 0, false
+	}
+
+	// D2 D4 A1 A2 A4 B1 B2 B4 are a reflected Gray code of the 500 ft band.
+	fiveHundreds := 0
+	for i, set := range []bool{d2, d4, a1, a2, a4, b1, b2, b4} {
+		if set {
+			fiveHundreds ^= 0xff >> uint(i)
+		}
+	}
+	if fiveHundreds&1 == 1 {
+		oneHundreds = 6 - oneHundreds
+	}
+
+	return (fiveHundreds*5 + oneHundreds - 13) * 100, true
+}
+
+func (p *Parser) parseSurface(me []byte, ac *models.Aircraft, icao string) {
+	if len(me) < 7 {
+		return
+	}
+
+	onGround := true
+	ac.OnGround = &onGround
+
+	movement := (me[0]&0x07)<<4 | me[1]>>4
+	if speed, ok := surfaceSpeed(movement); ok {
+		ac.SpeedKt = &speed
+	}
+
+	if (me[1]>>3)&1 == 1 {
+		track := float64((me[1]&0x07)<<4|me[2]>>4) * 360.0 / 128.0
+		ac.Heading = &track
+	}
+
+	oddFlag := (me[2] >> 2) & 1
+	cprLat := (uint32(me[2]&0x03) << 15) | (uint32(me[3]) << 7) | (uint32(me[4]) >> 1)
+	cprLon := (uint32(me[4]&0x01) << 16) | (uint32(me[5]) << 8) | uint32(me[6])
+
+	if lat, lon, ok := p.cpr.AddSurfaceFrame(icao, cprLat, cprLon, oddFlag == 1); ok {
+		ac.Lat = &lat
+		ac.Lon = &lon
+		global := p.cpr.IsGlobal(icao)
+		ac.PositionGlobal = &global
 	}
 }
 
-func parseVelocity(me []byte, ac *models.Aircraft) {
+// parseTargetState decodes the autopilot selected altitude and heading from
+// a Target State and Status message. Subtype 0 is the DO-260A layout,
+// subtype 1 the DO-260B one.
+func parseTargetState(me []byte, ac *models.Aircraft) {
+	if len(me) < 7 {
+		return
+	}
+
+	switch meBits(me, 6, 2) {
+	case 0:
+		if meBits(me, 8, 2) != 0 {
+			alt := int(meBits(me, 16, 10))*100 - 1000
+			ac.SelectedAltFt = &alt
+		}
+		if meBits(me, 26, 2) != 0 {
+			hdg := float64(meBits(me, 28, 9))
+			if hdg < 360 {
+				ac.SelectedHeading = &hdg
+			}
+		}
+	case 1:
+		if v := meBits(me, 10, 11); v != 0 {
+			alt := int(v-1) * 32
+			ac.SelectedAltFt = &alt
+		}
+		if meBits(me, 30, 1) == 1 {
+			hdg := float64(meBits(me, 31, 9)) * 180.0 / 256.0
+			ac.SelectedHeading = &hdg
+		}
+	}
+}
+
+// meBits returns n bits of the ME field starting at 1-indexed bit start,
+// the numbering used by the ADS-B message specifications.
+func meBits(me []byte, start, n int) uint32 {
+	var v uint32
+	for i := start - 1; i < start-1+n; i++ {
+		v = v<<1 | uint32(me[i/8]>>(7-uint(i%8)))&1
+	}
+	return v
+}
+
+func (p *Parser) parseOperationalStatus(me []byte, ac *models.Aircraft, icao string) {
+	if len(me) < 7 {
+		return
+	}
+
+	subtype := me[0] & 0x07
+	if subtype > 1 {
+		return
+	}
+
+	version := int(me[5]>>5) & 0x07
+	ac.ADSBVersion = &version
+
+	st := opStatus{version: version, seen: time.Now()}
+
+	// Version 0 transponders do not report NACp or the NIC supplement.
+	if version >= 1 {
+		st.nicA = (me[5]>>4)&1 == 1
+		nacp := int(me[5] & 0x0f)
+		ac.NACp = &nacp
+	}
+
+	p.statusMu.Lock()
+	p.status[icao] = st
+	p.statusMu.Unlock()
+}
+
+// setNIC derives the Navigation Integrity Category from a position
+// message's type code and the aircraft's NIC supplement-A. Where the
+// supplement is needed but unknown, the lower category is reported.
+func (p *Parser) setNIC(ac *models.Aircraft, icao string, tc byte) {
+	p.statusMu.Lock()
+	st, ok := p.status[icao]
+	p.statusMu.Unlock()
+	nicA := ok && st.nicA
+
+	var nic int
+	switch tc {
+	case 5, 9, 20:
+		nic = 11
+	case 6, 10, 21:
+		nic = 10
+	case 7, 11:
+		nic = 8
+		if nicA {
+			nic = 9
+		}
+	case 8:
+		nic = 0
+		if nicA {
+			nic = 6
+		}
+	case 12:
+		nic = 7
+	case 13:
+		nic = 6
+	case 14:
+		nic = 5
+	case 15:
+		nic = 4
+	case 16:
+		nic = 2
+		if nicA {
+			nic = 3
+		}
+	case 17:
+		nic = 1
+	default:
+		nic = 0
+	}
+	ac.NIC = &nic
+}
+
+// Cleanup drops CPR frames, operational status and baro altitudes for aircraft that have
+// not been heard from recently.
+func (p *Parser) Cleanup() {
+	p.cpr.Cleanup()
+
+	p.statusMu.Lock()
+	for icao, st := range p.status {
+		if time.Since(st.seen) > 10*time.Minute {
+			delete(p.status, icao)
+		}
+	}
+	p.statusMu.Unlock()
+
+	p.baroMu.Lock()
+	for icao, fix := range p.baro {
+		if time.Since(fix.seen) > baroFixMaxAge {
+			delete(p.baro, icao)
+		}
+	}
+	p.baroMu.Unlock()
+}
+
+// surfaceSpeed decodes the non-linear ground movement field in knots.
+func surfaceSpeed(movement byte) (float64, bool) {
+	m := float64(movement)
+	switch {
+	case movement == 1:
+		return 0, true
+	case movement >= 2 && movement <= 8:
+		return 0.125 * (m - 1), true
+	case movement >= 9 && movement <= 12:
+		return 1 + 0.25*(m-8), true
+	case movement >= 13 && movement <= 38:
+		return 2 + 0.5*(m-12), true
+	case movement >= 39 && movement <= 93:
+		return 15 + (m - 38), true
+	case movement >= 94 && movement <= 108:
+		return 70 + 2*(m-93), true
+	case movement >= 109 && movement <= 123:
+		return 100 + 5*(m-108), true
+	case movement == 124:
+		return 175, true
+	}
+	return 0, false
+}
+
+func (p *Parser) parseVelocity(me []byte, ac *models.Aircraft, icao string) {
 	if len(me) < 7 {
 		return
 	}
@@ -256,17 +586,44 @@ func parseVelocity(me []byte, ac *models.Aircraft) {
 			ac.SpeedKt = &speed
 			ac.Heading = &heading
 		}
+	}
 
-		vertSign := (me[4] >> 3) & 1
-		vertRate := int(((uint(me[4])&0x07)<<6)|(uint(me[5])>>2)) - 1
-		if vertRate >= 0 {
-			vr := vertRate * 64
-			if vertSign == 1 {
-				vr = -vr
-			}
-			ac.VerticalRate = &vr
+	if subtype < 1 || subtype > 4 {
+		return
+	}
+
+	vertSign := (me[4] >> 3) & 1
+	vertRate := int(((uint(me[4])&0x07)<<6)|(uint(me[5])>>2)) - 1
+	if vertRate >= 0 {
+		vr := vertRate * 64
+		if vertSign == 1 {
+			vr = -vr
+		}
+		ac.VerticalRate = &vr
+		if (me[4]>>4)&1 == 1 {
+			ac.VerticalRateSrc = "baro"
+		} else {
+			ac.VerticalRateSrc = "gnss"
 		}
 	}
+
+	// The last byte is the GNSS height minus baro altitude in 25 ft steps.
+	diff := int(me[6]&0x7f) - 1
+	if diff < 0 {
+		return
+	}
+	diff *= 25
+	if me[6]&0x80 != 0 {
+		diff = -diff
+	}
+
+	p.baroMu.Lock()
+	fix, ok := p.baro[icao]
+	p.baroMu.Unlock()
+	if ok && time.Since(fix.seen) <= baroFixMaxAge {
+		gnss := fix.alt + diff
+		ac.AltitudeGNSS = &gnss
+	}
 }
 
 func sqrt(x float64) float64 {
@@ -0,0 +1,148 @@
+package beast
+
+import (
+	"math"
+	"testing"
+
+	"adsb-tracker/pkg/models"
+)
+
+func TestDecodeTargetStateV2(t *testing.T) {
+	ac := NewParser().Decode(mustMessage(t, "8DA05629EA21485CBF3F8CADAEEB"))
+	if ac == nil {
+		t.Fatal("expected aircraft")
+	}
+	if ac.SelectedAltFt == nil || *ac.SelectedAltFt != 16992 {
+		t.Fatalf("expected selected altitude 16992, got %v", ac.SelectedAltFt)
+	}
+	if ac.SelectedHeading == nil || math.Abs(*ac.SelectedHeading-66.8) > 0.1 {
+		t.Fatalf("expected selected heading 66.8, got %v", ac.SelectedHeading)
+	}
+}
+
+func TestGillhamAltitude(t *testing.T) {
+	// Field order: C1 A1 C2 A2 C4 A4 B1 Q B2 D2 B4 D4.
+	const (
+		c1 = 1 << 11
+		a1 = 1 << 10
+		c2 = 1 << 9
+		c4 = 1 << 7
+		b4 = 1 << 1
+		d4 = 1 << 0
+	)
+
+	cases := []struct {
+		code uint
+		want int
+	}{
+		{c4, -1200},
+		{c2 | c4, -1100},
+		{c1, -800},
+		{b4 | c1, -700},
+		{b4 | c4, -300},
+		{d4 | a1 | c2, 31000},
+	}
+	for _, tc := range cases {
+		got, ok := gillhamAltitude(tc.code)
+		if !ok || got != tc.want {
+			t.Fatalf("code %012b: expected %d got %d (ok=%v)", tc.code, tc.want, got, ok)
+		}
+	}
+
+	if _, ok := gillhamAltitude(a1); ok {
+		t.Fatal("expected code without C bits to be rejected")
+	}
+}
+
+func TestDecodeAirborneGillhamAltitude(t *testing.T) {
+	// TC 11 airborne position with a Gillham-coded altitude of 31000 ft.
+	ac := NewParser().Decode(mustMessage(t, "8D40621D58601000000000000000"))
+	if ac == nil || ac.AltitudeFt == nil {
+		t.Fatal("expected an altitude")
+	}
+	if *ac.AltitudeFt != 31000 {
+		t.Fatalf("expected 31000 ft, got %d", *ac.AltitudeFt)
+	}
+}
+
+func TestDecodeVelocityGNSSDifference(t *testing.T) {
+	p := NewParser()
+	if ac := p.Decode(mustMessage(t, "8D48502058C382D690C8AC000000")); ac == nil || ac.AltitudeFt == nil || *ac.AltitudeFt != 38000 {
+		t.Fatalf("expected baro altitude 38000, got %+v", ac)
+	}
+
+	ac := p.Decode(mustMessage(t, "8D485020994409940838175B284F"))
+	if ac == nil {
+		t.Fatal("expected aircraft")
+	}
+	if ac.VerticalRate == nil || *ac.VerticalRate != -832 {
+		t.Fatalf("expected vertical rate -832, got %v", ac.VerticalRate)
+	}
+	if ac.VerticalRateSrc != "gnss" {
+		t.Fatalf("expected gnss vertical rate source, got %q", ac.VerticalRateSrc)
+	}
+	if ac.AltitudeGNSS == nil || *ac.AltitudeGNSS != 38550 {
+		t.Fatalf("expected GNSS altitude 38550, got %v", ac.AltitudeGNSS)
+	}
+}
+
+func TestDecodeMessageSource(t *testing.T) {
+	cases := []struct {
+		hex  string
+		want string
+		icao string
+	}{
+		{"8DA05629EA21485CBF3F8CADAEEB", models.SourceADSB, "A05629"},
+		{"90A05629EA21485CBF3F8CADAEEB", models.SourceADSB, "A05629"},
+		// CF 1: ADS-B from a non-ICAO (anonymous) address.
+		{"91A05629EA21485CBF3F8CADAEEB", models.SourceADSB, "~A05629"},
+		{"92A05629EA21485CBF3F8CADAEEB", models.SourceTISB, "A05629"},
+		// CF 3: coarse TIS-B, not decoded.
+		{"93A05629EA21485CBF3F8CADAEEB", "", ""},
+		{"94A05629EA21485CBF3F8CADAEEB", "", ""},
+		// CF 5: TIS-B with a track file address.
+		{"95A05629EA21485CBF3F8CADAEEB", models.SourceTISB, "~A05629"},
+		{"96A05629EA21485CBF3F8CADAEEB", models.SourceADSR, "A05629"},
+	}
+
+	for _, tc := range cases {
+		ac := NewParser().Decode(mustMessage(t, tc.hex))
+		if tc.want == "" {
+			if ac != nil {
+				t.Errorf("%s: expected frame to be dropped, got source %q", tc.hex, ac.Source)
+			}
+			continue
+		}
+		if ac == nil || ac.Source != tc.want || ac.ICAO != tc.icao {
+			t.Errorf("%s: expected source %q and key %q, got %+v", tc.hex, tc.want, tc.icao, ac)
+		}
+	}
+
+	msg := mustMessage(t, "8DA05629EA21485CBF3F8CADAEEB")
+	msg.Timestamp = mlatTimestamp
+	if ac := NewParser().Decode(msg); ac == nil || ac.Source != models.SourceMLAT {
+		t.Fatalf("expected mlat source, got %+v", ac)
+	}
+}
+
+func TestDecodeIdentEmitterCategory(t *testing.T) {
+	cases := []struct {
+		hex  string
+		want string
+	}{
+		{"8D4840D6202CC371C32CE0576098", ""},
+		{"8D4840D6252CC371C32CE0576098", "A5"},
+		{"8D4840D6192CC371C32CE0576098", "B1"},
+		{"8D4840D6122CC371C32CE0576098", "C2"},
+	}
+
+	for _, tc := range cases {
+		ac := NewParser().Decode(mustMessage(t, tc.hex))
+		if ac == nil || ac.Callsign != "KLM1023" {
+			t.Fatalf("%s: expected callsign KLM1023, got %+v", tc.hex, ac)
+		}
+		if ac.EmitterCategory != tc.want {
+			t.Errorf("%s: expected category %q, got %q", tc.hex, tc.want, ac.EmitterCategory)
+		}
+	}
+}
@@ -2,36 +2,211 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type DatabaseConfig struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	DBName   string `json:"dbname"`
-	SSLMode  string `json:"sslmode"`
+	Host            string        `json:"host"`
+	Port            int           `json:"port"`
+	User            string        `json:"user"`
+	Password        string        `json:"password"`
+	DBName          string        `json:"dbname"`
+	SSLMode         string        `json:"sslmode"`
+	MaxOpenConns    int           `json:"max_open_conns"`
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	ConnectTimeout  time.Duration `json:"connect_timeout"`
 }
 
 type WebhookEventsConfig struct {
-	EmergencySquawk   bool     `json:"emergency_squawk"`
-	AircraftWatchlist []string `json:"aircraft_watchlist"`
-	NewAircraft       bool     `json:"new_aircraft"`
-	HealthAlerts      bool     `json:"health_alerts"`
+	EmergencySquawk  bool     `json:"emergency_squawk"`
+	EmergencySquawks []string `json:"emergency_squawks"`
+	// EmergencyAlerts switches alerts for individual emergency codes, or
+	// EmergencyFlag, on or off. Codes not listed alert.
+	EmergencyAlerts   map[string]bool  `json:"emergency_alerts"`
+	AircraftWatchlist []string         `json:"aircraft_watchlist"`
+	Watchlists        []WatchlistGroup `json:"watchlists"`
+	NewAircraft       bool             `json:"new_aircraft"`
+	HealthAlerts      bool             `json:"health_alerts"`
+	MaxRange          bool             `json:"max_range"`
+	FeedStatus        bool             `json:"feed_status"`
+	FlightComplete    bool             `json:"flight_complete"`
+	Digest            bool             `json:"digest"`
+}
+
+// EmergencyFlag is the EmergencyAlerts key for the transponder's emergency
+// flag set without an emergency squawk.
+const EmergencyFlag = "flag"
+
+// EmergencyAlertEnabled reports whether an emergency with the given code,
+// or EmergencyFlag, should alert.
+func (e WebhookEventsConfig) EmergencyAlertEnabled(code string) bool {
+	if !e.EmergencySquawk {
+		return false
+	}
+	enabled, ok := e.EmergencyAlerts[code]
+	return !ok || enabled
+}
+
+// WatchlistGroup is a named set of watchlist patterns. Color (e.g. "#FFAA00")
+// and URL optionally override the embed color and webhook destination used
+// for the group's matches.
+type WatchlistGroup struct {
+	Name     string   `json:"name"`
+	Patterns []string `json:"patterns"`
+	Color    string   `json:"color"`
+	URL      string   `json:"url"`
+}
+
+// ColorValue parses Color as a hex RGB value.
+func (g WatchlistGroup) ColorValue() (int, bool) {
+	c := strings.TrimPrefix(strings.TrimSpace(g.Color), "#")
+	if c == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(c, 16, 32)
+	if err != nil || v > 0xFFFFFF {
+		return 0, false
+	}
+	return int(v), true
+}
+
+// WatchlistGroups returns every watchlist in match order. Entries in the
+// legacy aircraft_watchlist list come first as a single unnamed group.
+func (e WebhookEventsConfig) WatchlistGroups() []WatchlistGroup {
+	groups := make([]WatchlistGroup, 0, len(e.Watchlists)+1)
+	if len(e.AircraftWatchlist) > 0 {
+		groups = append(groups, WatchlistGroup{Patterns: e.AircraftWatchlist})
+	}
+	return append(groups, e.Watchlists...)
 }
 
 type HealthThresholdsConfig struct {
-	CPUPercent    int `json:"cpu_percent"`
-	MemoryPercent int `json:"memory_percent"`
-	TempCelsius   int `json:"temp_celsius"`
+	CPUPercent       int    `json:"cpu_percent"`
+	MemoryPercent    int    `json:"memory_percent"`
+	TempCelsius      int    `json:"temp_celsius"`
+	DiskPercent      int    `json:"disk_percent"`
+	DiskPath         string `json:"disk_path"`
+	NetworkInterface string `json:"network_interface"`
+	// MinMessagesPerSec alerts when the feed stays connected but its rate
+	// stays below this for MinMessagesDuration. 0 disables it.
+	MinMessagesPerSec   int           `json:"min_messages_per_sec"`
+	MinMessagesDuration time.Duration `json:"min_messages_duration"`
 }
 
 type WebhookConfig struct {
+	Provider         string                 `json:"provider"`
+	URL              string                 `json:"url"`
 	DiscordURL       string                 `json:"discord_url"`
+	Routes           map[string]string      `json:"routes"`
 	Events           WebhookEventsConfig    `json:"events"`
 	HealthThresholds HealthThresholdsConfig `json:"health_thresholds"`
+	Map              WebhookMapConfig       `json:"map"`
+	// Cooldowns is how long an alert with the same subject is suppressed
+	// after one is sent, keyed by event type.
+	Cooldowns map[string]time.Duration `json:"cooldowns"`
+	// NewAircraftPerMinute caps new aircraft alerts across all aircraft; 0
+	// disables the cap.
+	NewAircraftPerMinute int              `json:"new_aircraft_per_minute"`
+	DigestInterval       time.Duration    `json:"digest_interval"`
+	Escalation           EscalationConfig `json:"escalation"`
+}
+
+// EscalationConfig repeats emergency alerts every Interval while the
+// aircraft keeps squawking one of Codes, or any emergency code when Codes is
+// empty. A zero Interval disables it.
+type EscalationConfig struct {
+	Interval time.Duration `json:"interval"`
+	Codes    []string      `json:"codes"`
+}
+
+// Escalates reports whether an emergency with the given code, or
+// EmergencyFlag, is repeated.
+func (e EscalationConfig) Escalates(code string) bool {
+	if e.Interval <= 0 {
+		return false
+	}
+	return len(e.Codes) == 0 || slices.Contains(e.Codes, code)
+}
+
+// DefaultWebhookCooldown applies to event types without an entry in
+// Cooldowns.
+const DefaultWebhookCooldown = 5 * time.Minute
+
+// WebhookEventTypes lists the event types accepted as routes and cooldowns.
+var WebhookEventTypes = []string{
+	"emergency_squawk", "watchlist_match", "new_aircraft", "health_alert",
+	"max_range", "feed_down", "feed_up", "flight_complete", "digest",
+}
+
+var defaultWebhookCooldowns = map[string]time.Duration{
+	"emergency_squawk": 0,
+	"watchlist_match":  DefaultWebhookCooldown,
+	"new_aircraft":     time.Hour,
+	"health_alert":     DefaultWebhookCooldown,
+	"max_range":        DefaultWebhookCooldown,
+	"feed_down":        DefaultWebhookCooldown,
+	"feed_up":          DefaultWebhookCooldown,
+	"flight_complete":  0,
+	"digest":           0,
+}
+
+// DefaultWebhookCooldowns returns the per-event cooldowns used unless the
+// config overrides them. Emergencies already alert only on a change of
+// state, and flight summaries are sent once per flight.
+func DefaultWebhookCooldowns() map[string]time.Duration {
+	return maps.Clone(defaultWebhookCooldowns)
+}
+
+// Cooldown returns the suppression window for an event type.
+func (w WebhookConfig) Cooldown(eventType string) time.Duration {
+	if d, ok := w.Cooldowns[eventType]; ok {
+		return d
+	}
+	if d, ok := defaultWebhookCooldowns[eventType]; ok {
+		return d
+	}
+	return DefaultWebhookCooldown
+}
+
+// WebhookMapConfig enables a map image centred on the aircraft in Discord
+// embeds. TileURL is a slippy map tile template containing {z}, {x} and {y};
+// the image is skipped when it is empty.
+type WebhookMapConfig struct {
+	TileURL string `json:"tile_url"`
+	Zoom    int    `json:"zoom"`
+}
+
+// Endpoint returns the configured webhook URL, preferring the generic url
+// field over the legacy discord_url.
+func (w WebhookConfig) Endpoint() string {
+	if w.URL != "" {
+		return w.URL
+	}
+	return w.DiscordURL
+}
+
+// Enabled reports whether any webhook destination is configured.
+func (w WebhookConfig) Enabled() bool {
+	if w.Endpoint() != "" {
+		return true
+	}
+	for _, url := range w.Routes {
+		if url != "" {
+			return true
+		}
+	}
+	return false
 }
 
 type AutoGainConfig struct {
@@ -40,111 +215,256 @@ type AutoGainConfig struct {
 	AdjustmentInterval   time.Duration `json:"adjustment_interval"`
 }
 
+// GPSDConfig updates the receiver location from a gpsd daemon, for receivers
+// in vehicles or boats.
+type GPSDConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Addr     string        `json:"addr"`
+	Interval time.Duration `json:"interval"`
+}
+
+type LookupConfig struct {
+	Sources     []string      `json:"sources"`
+	CSVPath     string        `json:"csv_path"`
+	NotFoundTTL time.Duration `json:"not_found_ttl"`
+	RateLimit   float64       `json:"rate_limit"`
+}
+
 type Config struct {
-	SBSHost      string         `json:"sbs_host"`
-	SBSPort      int            `json:"sbs_port"`
-	FeedFormat   string         `json:"feed_format"`
-	HTTPAddr     string         `json:"http_addr"`
-	RxLat        float64        `json:"rx_lat"`
-	RxLon        float64        `json:"rx_lon"`
-	NodeName     string         `json:"node_name"`
-	StaleTimeout time.Duration  `json:"stale_timeout"`
-	DeviceIndex  int            `json:"device_index"`
-	Database     DatabaseConfig `json:"database"`
-	TrailLength  int            `json:"trail_length"`
-	Webhooks     WebhookConfig  `json:"webhooks"`
-	AutoGain     AutoGainConfig `json:"auto_gain"`
+	SBSHost         string         `json:"sbs_host"`
+	SBSPort         int            `json:"sbs_port"`
+	FeedFormat      string         `json:"feed_format"`
+	HTTPAddr        string         `json:"http_addr"`
+	PprofAddr       string         `json:"pprof_addr"`
+	RxLat           float64        `json:"rx_lat"`
+	RxLon           float64        `json:"rx_lon"`
+	NodeName        string         `json:"node_name"`
+	APIKey          string         `json:"api_key"`
+	Units           string         `json:"units"`
+	LogLevel        string         `json:"log_level"`
+	LogFormat       string         `json:"log_format"`
+	StaleTimeout    time.Duration  `json:"stale_timeout"`
+	MaxTimestampAge time.Duration  `json:"max_timestamp_age"`
+	FlightSplitGap  time.Duration  `json:"flight_split_gap"`
+	HealthInterval  time.Duration  `json:"health_interval"`
+	DeviceIndex     int            `json:"device_index"`
+	Dump1090Verbose bool           `json:"dump1090_verbose"`
+	Dump1090Gain    *float64       `json:"dump1090_gain,omitempty"`
+	Database        DatabaseConfig `json:"database"`
+	TrailLength     int            `json:"trail_length"`
+	MaxAircraft     int            `json:"max_aircraft"`
+	RangeBuckets    int            `json:"range_buckets"`
+	Webhooks        WebhookConfig  `json:"webhooks"`
+	AutoGain        AutoGainConfig `json:"auto_gain"`
+	GPSD            GPSDConfig     `json:"gpsd"`
+	Lookup          LookupConfig   `json:"lookup"`
+
+	// HasRxLat and HasRxLon are set when rx_lat or rx_lon was given
+	// anywhere, so a receiver on the equator or prime meridian still counts
+	// as located.
+	HasRxLat bool `json:"-"`
+	HasRxLon bool `json:"-"`
+}
+
+// HasRxLocation reports whether both rx_lat and rx_lon were given.
+func (c *Config) HasRxLocation() bool {
+	return c.HasRxLat && c.HasRxLon
 }
 
 func Default() *Config {
 	return &Config{
-		SBSHost:      "127.0.0.1",
-		SBSPort:      30003,
-		FeedFormat:   "sbs",
-		HTTPAddr:     ":8080",
-		NodeName:     "Skywatch Node",
-		StaleTimeout: 60 * time.Second,
-		DeviceIndex:  0,
-		TrailLength:  50,
+		SBSHost:         "127.0.0.1",
+		SBSPort:         30003,
+		FeedFormat:      "sbs",
+		HTTPAddr:        ":8080",
+		NodeName:        "Skywatch Node",
+		Units:           "nm",
+		LogLevel:        "info",
+		LogFormat:       "text",
+		StaleTimeout:    60 * time.Second,
+		MaxTimestampAge: 10 * time.Second,
+		FlightSplitGap:  5 * time.Minute,
+		HealthInterval:  10 * time.Second,
+		DeviceIndex:     0,
+		TrailLength:     50,
+		RangeBuckets:    36,
 		Database: DatabaseConfig{
-			Host:    "localhost",
-			Port:    5432,
-			User:    "postgres",
-			DBName:  "adsb",
-			SSLMode: "disable",
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			DBName:          "adsb",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
 		},
 		Webhooks: WebhookConfig{
 			Events: WebhookEventsConfig{
-				EmergencySquawk: true,
-				HealthAlerts:    true,
+				EmergencySquawk:  true,
+				EmergencySquawks: DefaultEmergencySquawks(),
+				HealthAlerts:     true,
 			},
 			HealthThresholds: HealthThresholdsConfig{
-				CPUPercent:    90,
-				MemoryPercent: 90,
-				TempCelsius:   80,
+				CPUPercent:          90,
+				MemoryPercent:       90,
+				TempCelsius:         80,
+				DiskPercent:         90,
+				DiskPath:            "/",
+				MinMessagesDuration: 5 * time.Minute,
+			},
+			Map: WebhookMapConfig{
+				Zoom: 9,
 			},
+			Cooldowns:            DefaultWebhookCooldowns(),
+			NewAircraftPerMinute: 10,
+			DigestInterval:       time.Hour,
 		},
 		AutoGain: AutoGainConfig{
 			Enabled:              false,
 			TargetMessagesPerSec: 100,
 			AdjustmentInterval:   5 * time.Minute,
 		},
+		GPSD: GPSDConfig{
+			Addr:     "127.0.0.1:2947",
+			Interval: 30 * time.Second,
+		},
+		Lookup: LookupConfig{
+			Sources:     []string{"hexdb"},
+			NotFoundTTL: time.Hour,
+			RateLimit:   1,
+		},
 	}
 }
 
+func DefaultEmergencySquawks() []string {
+	return []string{"7500", "7600", "7700"}
+}
+
+// Load builds the configuration from defaults, then the file at path (if it
+// exists), then SKYWATCH_* environment variables. Command-line flags are
+// applied on top by the caller.
 func Load(path string) (*Config, error) {
 	cfg := Default()
 
+	if err := loadFile(cfg, path); err != nil {
+		return nil, err
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return cfg, nil
+			return nil
 		}
-		return nil, err
+		return err
 	}
 
 	var fileCfg struct {
-		SBSHost      string  `json:"sbs_host"`
-		SBSPort      int     `json:"sbs_port"`
-		FeedFormat   string  `json:"feed_format"`
-		HTTPAddr     string  `json:"http_addr"`
-		RxLat        float64 `json:"rx_lat"`
-		RxLon        float64 `json:"rx_lon"`
-		NodeName     string  `json:"node_name"`
-		StaleTimeout string  `json:"stale_timeout"`
-		DeviceIndex  int     `json:"device_index"`
-		TrailLength  int     `json:"trail_length"`
-		Database     struct {
-			Host     string `json:"host"`
-			Port     int    `json:"port"`
-			User     string `json:"user"`
-			Password string `json:"password"`
-			DBName   string `json:"dbname"`
-			SSLMode  string `json:"sslmode"`
+		SBSHost         string   `json:"sbs_host"`
+		SBSPort         int      `json:"sbs_port"`
+		FeedFormat      string   `json:"feed_format"`
+		HTTPAddr        string   `json:"http_addr"`
+		PprofAddr       string   `json:"pprof_addr"`
+		RxLat           *float64 `json:"rx_lat"`
+		RxLon           *float64 `json:"rx_lon"`
+		NodeName        string   `json:"node_name"`
+		APIKey          string   `json:"api_key"`
+		Units           string   `json:"units"`
+		LogLevel        string   `json:"log_level"`
+		LogFormat       string   `json:"log_format"`
+		StaleTimeout    string   `json:"stale_timeout"`
+		MaxTimestampAge string   `json:"max_timestamp_age"`
+		FlightSplitGap  string   `json:"flight_split_gap"`
+		HealthInterval  string   `json:"health_interval"`
+		DeviceIndex     int      `json:"device_index"`
+		Dump1090Verbose bool     `json:"dump1090_verbose"`
+		Dump1090Gain    *float64 `json:"dump1090_gain"`
+		TrailLength     int      `json:"trail_length"`
+		MaxAircraft     int      `json:"max_aircraft"`
+		RangeBuckets    int      `json:"range_buckets"`
+		Database        struct {
+			Host            string `json:"host"`
+			Port            int    `json:"port"`
+			User            string `json:"user"`
+			Password        string `json:"password"`
+			DBName          string `json:"dbname"`
+			SSLMode         string `json:"sslmode"`
+			MaxOpenConns    int    `json:"max_open_conns"`
+			MaxIdleConns    *int   `json:"max_idle_conns"`
+			ConnMaxLifetime string `json:"conn_max_lifetime"`
+			ConnectTimeout  string `json:"connect_timeout"`
 		} `json:"database"`
 		Webhooks struct {
-			DiscordURL string `json:"discord_url"`
+			Provider   string            `json:"provider"`
+			URL        string            `json:"url"`
+			DiscordURL string            `json:"discord_url"`
+			Routes     map[string]string `json:"routes"`
 			Events     struct {
-				EmergencySquawk   bool     `json:"emergency_squawk"`
-				AircraftWatchlist []string `json:"aircraft_watchlist"`
-				NewAircraft       bool     `json:"new_aircraft"`
-				HealthAlerts      bool     `json:"health_alerts"`
+				EmergencySquawk   bool             `json:"emergency_squawk"`
+				EmergencySquawks  []string         `json:"emergency_squawks"`
+				EmergencyAlerts   map[string]bool  `json:"emergency_alerts"`
+				AircraftWatchlist []string         `json:"aircraft_watchlist"`
+				Watchlists        []WatchlistGroup `json:"watchlists"`
+				NewAircraft       bool             `json:"new_aircraft"`
+				HealthAlerts      bool             `json:"health_alerts"`
+				MaxRange          bool             `json:"max_range"`
+				FeedStatus        bool             `json:"feed_status"`
+				FlightComplete    bool             `json:"flight_complete"`
+				Digest            bool             `json:"digest"`
 			} `json:"events"`
 			HealthThresholds struct {
-				CPUPercent    int `json:"cpu_percent"`
-				MemoryPercent int `json:"memory_percent"`
-				TempCelsius   int `json:"temp_celsius"`
+				CPUPercent          int    `json:"cpu_percent"`
+				MemoryPercent       int    `json:"memory_percent"`
+				TempCelsius         int    `json:"temp_celsius"`
+				DiskPercent         int    `json:"disk_percent"`
+				DiskPath            string `json:"disk_path"`
+				NetworkInterface    string `json:"network_interface"`
+				MinMessagesPerSec   int    `json:"min_messages_per_sec"`
+				MinMessagesDuration string `json:"min_messages_duration"`
 			} `json:"health_thresholds"`
+			Map struct {
+				TileURL string `json:"tile_url"`
+				Zoom    int    `json:"zoom"`
+			} `json:"map"`
+			Cooldowns            map[string]string `json:"cooldowns"`
+			NewAircraftPerMinute *int              `json:"new_aircraft_per_minute"`
+			DigestInterval       string            `json:"digest_interval"`
+			Escalation           struct {
+				Interval string   `json:"interval"`
+				Codes    []string `json:"codes"`
+			} `json:"escalation"`
 		} `json:"webhooks"`
 		AutoGain struct {
 			Enabled              bool   `json:"enabled"`
 			TargetMessagesPerSec int    `json:"target_messages_per_sec"`
 			AdjustmentInterval   string `json:"adjustment_interval"`
 		} `json:"auto_gain"`
+		GPSD struct {
+			Enabled  bool   `json:"enabled"`
+			Addr     string `json:"addr"`
+			Interval string `json:"interval"`
+		} `json:"gpsd"`
+		Lookup struct {
+			Sources     []string `json:"sources"`
+			CSVPath     string   `json:"csv_path"`
+			NotFoundTTL string   `json:"not_found_ttl"`
+			RateLimit   *float64 `json:"rate_limit"`
+		} `json:"lookup"`
 	}
 
+	if isYAML(path) {
+		if data, err = yamlToJSON(data, reflect.TypeOf(fileCfg)); err != nil {
+			return err
+		}
+	}
 	if err := json.Unmarshal(data, &fileCfg); err != nil {
-		return nil, err
+		return err
 	}
 
 	if fileCfg.SBSHost != "" {
@@ -159,26 +479,66 @@ func Load(path string) (*Config, error) {
 	if fileCfg.HTTPAddr != "" {
 		cfg.HTTPAddr = fileCfg.HTTPAddr
 	}
-	if fileCfg.RxLat != 0 {
-		cfg.RxLat = fileCfg.RxLat
+	if fileCfg.PprofAddr != "" {
+		cfg.PprofAddr = fileCfg.PprofAddr
 	}
-	if fileCfg.RxLon != 0 {
-		cfg.RxLon = fileCfg.RxLon
+	if fileCfg.RxLat != nil {
+		cfg.RxLat = *fileCfg.RxLat
+		cfg.HasRxLat = true
+	}
+	if fileCfg.RxLon != nil {
+		cfg.RxLon = *fileCfg.RxLon
+		cfg.HasRxLon = true
 	}
 	if fileCfg.NodeName != "" {
 		cfg.NodeName = fileCfg.NodeName
 	}
+	if fileCfg.APIKey != "" {
+		cfg.APIKey = fileCfg.APIKey
+	}
+	if fileCfg.Units != "" {
+		cfg.Units = fileCfg.Units
+	}
+	if fileCfg.LogLevel != "" {
+		cfg.LogLevel = fileCfg.LogLevel
+	}
+	if fileCfg.LogFormat != "" {
+		cfg.LogFormat = fileCfg.LogFormat
+	}
 	if fileCfg.StaleTimeout != "" {
 		if d, err := time.ParseDuration(fileCfg.StaleTimeout); err == nil {
 			cfg.StaleTimeout = d
 		}
 	}
+	if fileCfg.FlightSplitGap != "" {
+		if d, err := time.ParseDuration(fileCfg.FlightSplitGap); err == nil {
+			cfg.FlightSplitGap = d
+		}
+	}
+	if fileCfg.MaxTimestampAge != "" {
+		if d, err := time.ParseDuration(fileCfg.MaxTimestampAge); err == nil {
+			cfg.MaxTimestampAge = d
+		}
+	}
+	if fileCfg.HealthInterval != "" {
+		if d, err := time.ParseDuration(fileCfg.HealthInterval); err == nil && d > 0 {
+			cfg.HealthInterval = d
+		}
+	}
 	if fileCfg.DeviceIndex != 0 {
 		cfg.DeviceIndex = fileCfg.DeviceIndex
 	}
+	cfg.Dump1090Verbose = fileCfg.Dump1090Verbose
+	if fileCfg.Dump1090Gain != nil {
+		cfg.Dump1090Gain = fileCfg.Dump1090Gain
+	}
 	if fileCfg.TrailLength != 0 {
 		cfg.TrailLength = fileCfg.TrailLength
 	}
+	cfg.MaxAircraft = fileCfg.MaxAircraft
+	if fileCfg.RangeBuckets != 0 {
+		cfg.RangeBuckets = fileCfg.RangeBuckets
+	}
 
 	if fileCfg.Database.Host != "" {
 		cfg.Database.Host = fileCfg.Database.Host
@@ -198,14 +558,48 @@ func Load(path string) (*Config, error) {
 	if fileCfg.Database.SSLMode != "" {
 		cfg.Database.SSLMode = fileCfg.Database.SSLMode
 	}
+	if fileCfg.Database.MaxOpenConns != 0 {
+		cfg.Database.MaxOpenConns = fileCfg.Database.MaxOpenConns
+	}
+	if fileCfg.Database.MaxIdleConns != nil {
+		cfg.Database.MaxIdleConns = *fileCfg.Database.MaxIdleConns
+	}
+	if fileCfg.Database.ConnMaxLifetime != "" {
+		if d, err := time.ParseDuration(fileCfg.Database.ConnMaxLifetime); err == nil {
+			cfg.Database.ConnMaxLifetime = d
+		}
+	}
+	if fileCfg.Database.ConnectTimeout != "" {
+		if d, err := time.ParseDuration(fileCfg.Database.ConnectTimeout); err == nil {
+			cfg.Database.ConnectTimeout = d
+		}
+	}
 
+	if fileCfg.Webhooks.Provider != "" {
+		cfg.Webhooks.Provider = fileCfg.Webhooks.Provider
+	}
+	if fileCfg.Webhooks.URL != "" {
+		cfg.Webhooks.URL = fileCfg.Webhooks.URL
+	}
 	if fileCfg.Webhooks.DiscordURL != "" {
 		cfg.Webhooks.DiscordURL = fileCfg.Webhooks.DiscordURL
 	}
+	if len(fileCfg.Webhooks.Routes) > 0 {
+		cfg.Webhooks.Routes = fileCfg.Webhooks.Routes
+	}
 	cfg.Webhooks.Events.EmergencySquawk = fileCfg.Webhooks.Events.EmergencySquawk
+	if len(fileCfg.Webhooks.Events.EmergencySquawks) > 0 {
+		cfg.Webhooks.Events.EmergencySquawks = fileCfg.Webhooks.Events.EmergencySquawks
+	}
+	cfg.Webhooks.Events.EmergencyAlerts = fileCfg.Webhooks.Events.EmergencyAlerts
 	cfg.Webhooks.Events.AircraftWatchlist = fileCfg.Webhooks.Events.AircraftWatchlist
+	cfg.Webhooks.Events.Watchlists = fileCfg.Webhooks.Events.Watchlists
 	cfg.Webhooks.Events.NewAircraft = fileCfg.Webhooks.Events.NewAircraft
 	cfg.Webhooks.Events.HealthAlerts = fileCfg.Webhooks.Events.HealthAlerts
+	cfg.Webhooks.Events.MaxRange = fileCfg.Webhooks.Events.MaxRange
+	cfg.Webhooks.Events.FeedStatus = fileCfg.Webhooks.Events.FeedStatus
+	cfg.Webhooks.Events.FlightComplete = fileCfg.Webhooks.Events.FlightComplete
+	cfg.Webhooks.Events.Digest = fileCfg.Webhooks.Events.Digest
 	if fileCfg.Webhooks.HealthThresholds.CPUPercent != 0 {
 		cfg.Webhooks.HealthThresholds.CPUPercent = fileCfg.Webhooks.HealthThresholds.CPUPercent
 	}
@@ -215,6 +609,44 @@ func Load(path string) (*Config, error) {
 	if fileCfg.Webhooks.HealthThresholds.TempCelsius != 0 {
 		cfg.Webhooks.HealthThresholds.TempCelsius = fileCfg.Webhooks.HealthThresholds.TempCelsius
 	}
+	if fileCfg.Webhooks.HealthThresholds.DiskPercent != 0 {
+		cfg.Webhooks.HealthThresholds.DiskPercent = fileCfg.Webhooks.HealthThresholds.DiskPercent
+	}
+	if fileCfg.Webhooks.HealthThresholds.DiskPath != "" {
+		cfg.Webhooks.HealthThresholds.DiskPath = fileCfg.Webhooks.HealthThresholds.DiskPath
+	}
+	cfg.Webhooks.HealthThresholds.NetworkInterface = fileCfg.Webhooks.HealthThresholds.NetworkInterface
+	cfg.Webhooks.HealthThresholds.MinMessagesPerSec = fileCfg.Webhooks.HealthThresholds.MinMessagesPerSec
+	if fileCfg.Webhooks.HealthThresholds.MinMessagesDuration != "" {
+		if d, err := time.ParseDuration(fileCfg.Webhooks.HealthThresholds.MinMessagesDuration); err == nil && d > 0 {
+			cfg.Webhooks.HealthThresholds.MinMessagesDuration = d
+		}
+	}
+	if fileCfg.Webhooks.Map.TileURL != "" {
+		cfg.Webhooks.Map.TileURL = fileCfg.Webhooks.Map.TileURL
+	}
+	if fileCfg.Webhooks.Map.Zoom != 0 {
+		cfg.Webhooks.Map.Zoom = fileCfg.Webhooks.Map.Zoom
+	}
+	for eventType, value := range fileCfg.Webhooks.Cooldowns {
+		if d, err := time.ParseDuration(value); err == nil {
+			cfg.Webhooks.Cooldowns[eventType] = d
+		}
+	}
+	if fileCfg.Webhooks.NewAircraftPerMinute != nil {
+		cfg.Webhooks.NewAircraftPerMinute = *fileCfg.Webhooks.NewAircraftPerMinute
+	}
+	if fileCfg.Webhooks.Escalation.Interval != "" {
+		if d, err := time.ParseDuration(fileCfg.Webhooks.Escalation.Interval); err == nil {
+			cfg.Webhooks.Escalation.Interval = d
+		}
+	}
+	cfg.Webhooks.Escalation.Codes = fileCfg.Webhooks.Escalation.Codes
+	if fileCfg.Webhooks.DigestInterval != "" {
+		if d, err := time.ParseDuration(fileCfg.Webhooks.DigestInterval); err == nil && d > 0 {
+			cfg.Webhooks.DigestInterval = d
+		}
+	}
 
 	cfg.AutoGain.Enabled = fileCfg.AutoGain.Enabled
 	if fileCfg.AutoGain.TargetMessagesPerSec != 0 {
@@ -226,5 +658,159 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
-	return cfg, nil
+	cfg.GPSD.Enabled = fileCfg.GPSD.Enabled
+	if fileCfg.GPSD.Addr != "" {
+		cfg.GPSD.Addr = fileCfg.GPSD.Addr
+	}
+	if fileCfg.GPSD.Interval != "" {
+		if d, err := time.ParseDuration(fileCfg.GPSD.Interval); err == nil && d > 0 {
+			cfg.GPSD.Interval = d
+		}
+	}
+
+	if len(fileCfg.Lookup.Sources) > 0 {
+		cfg.Lookup.Sources = fileCfg.Lookup.Sources
+	}
+	if fileCfg.Lookup.CSVPath != "" {
+		cfg.Lookup.CSVPath = fileCfg.Lookup.CSVPath
+	}
+	if fileCfg.Lookup.NotFoundTTL != "" {
+		if d, err := time.ParseDuration(fileCfg.Lookup.NotFoundTTL); err == nil && d > 0 {
+			cfg.Lookup.NotFoundTTL = d
+		}
+	}
+	if fileCfg.Lookup.RateLimit != nil {
+		cfg.Lookup.RateLimit = *fileCfg.Lookup.RateLimit
+	}
+
+	return nil
+}
+
+func isYAML(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// yamlToJSON re-encodes a YAML document as JSON so YAML configs decode
+// through the same json-tagged structs as config.json.
+func yamlToJSON(data []byte, target reflect.Type) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return []byte("{}"), nil
+	}
+	value, err := yamlValue(doc.Content[0], target)
+	if err != nil {
+		return nil, fmt.Errorf("convert yaml: %w", err)
+	}
+	if value == nil {
+		return []byte("{}"), nil
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("convert yaml: %w", err)
+	}
+	return out, nil
+}
+
+// yamlValue converts a YAML node into a value for json.Marshal, guided by
+// the type it will be decoded into. Scalars bound for string fields keep
+// their source text, so unquoted squawks such as 7500 or hex codes such as
+// 123456 load as written instead of as numbers.
+func yamlValue(n *yaml.Node, t reflect.Type) (any, error) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(n.Alias, t)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		if err := yamlMapping(n, t, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		list := make([]any, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := yamlValue(item, elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.ScalarNode:
+		if t != nil && t.Kind() == reflect.String && n.ShortTag() != "!!null" {
+			return n.Value, nil
+		}
+	}
+
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func yamlMapping(n *yaml.Node, t reflect.Type, m map[string]any) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		if key.ShortTag() == "!!merge" {
+			if val.Kind == yaml.AliasNode {
+				val = val.Alias
+			}
+			if val.Kind == yaml.MappingNode {
+				if err := yamlMapping(val, t, m); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		v, err := yamlValue(val, fieldType(t, key.Value))
+		if err != nil {
+			return err
+		}
+		m[key.Value] = v
+	}
+	return nil
+}
+
+// fieldType returns the type a JSON object key decodes into within t, or
+// nil when it is unknown.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		var fold reflect.Type
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" {
+				name = f.Name
+			}
+			if name == key {
+				return f.Type
+			}
+			if fold == nil && strings.EqualFold(name, key) {
+				fold = f.Type
+			}
+		}
+		return fold
+	}
+	return nil
 }
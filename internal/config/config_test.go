@@ -0,0 +1,234 @@
+package config
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `# receiver
+sbs_host: 10.0.0.5
+feed_format: beast
+rx_lat: 51.5
+stale_timeout: 90s
+webhooks:
+  events:
+    aircraft_watchlist: [A12345, ABCDEF]
+  cooldowns:
+    new_aircraft: 6h
+  new_aircraft_per_minute: 0
+auto_gain:
+  adjustment_interval: 10m
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.SBSHost != "10.0.0.5" || cfg.FeedFormat != "beast" || cfg.RxLat != 51.5 {
+		t.Fatalf("unexpected feed settings: %+v", cfg)
+	}
+	if cfg.StaleTimeout != 90*time.Second || cfg.AutoGain.AdjustmentInterval != 10*time.Minute {
+		t.Fatalf("durations not parsed: stale=%v adjust=%v", cfg.StaleTimeout, cfg.AutoGain.AdjustmentInterval)
+	}
+	if len(cfg.Webhooks.Events.AircraftWatchlist) != 2 {
+		t.Fatalf("expected watchlist of 2, got %v", cfg.Webhooks.Events.AircraftWatchlist)
+	}
+	if cfg.Webhooks.Cooldown("new_aircraft") != 6*time.Hour || cfg.Webhooks.Cooldown("watchlist_match") != DefaultWebhookCooldown {
+		t.Fatalf("unexpected cooldowns: %v", cfg.Webhooks.Cooldowns)
+	}
+	if cfg.Webhooks.NewAircraftPerMinute != 0 {
+		t.Fatalf("expected new aircraft cap to be disabled, got %d", cfg.Webhooks.NewAircraftPerMinute)
+	}
+	if cfg.SBSPort != 30003 {
+		t.Fatalf("expected default port to be kept, got %d", cfg.SBSPort)
+	}
+}
+
+func TestLoadYAMLUnquotedCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	yaml := `sbs_port: 30005
+webhooks:
+  events:
+    emergency_squawks: [7500, 7700, 0020]
+    aircraft_watchlist:
+      - 123456
+      - A1B2C3
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := strings.Join(cfg.Webhooks.Events.EmergencySquawks, ","); got != "7500,7700,0020" {
+		t.Fatalf("expected squawks as written, got %s", got)
+	}
+	if got := strings.Join(cfg.Webhooks.Events.AircraftWatchlist, ","); got != "123456,A1B2C3" {
+		t.Fatalf("expected watchlist as written, got %s", got)
+	}
+	if cfg.SBSPort != 30005 {
+		t.Fatalf("expected numeric port, got %d", cfg.SBSPort)
+	}
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"sbs_host": "10.0.0.5", "http_addr": ":9000"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SKYWATCH_SBS_HOST", "feeder.local")
+	t.Setenv("SKYWATCH_DB_PASSWORD", "secret")
+	t.Setenv("SKYWATCH_SBS_PORT", "30005")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.SBSHost != "feeder.local" || cfg.SBSPort != 30005 {
+		t.Fatalf("env not applied: host=%q port=%d", cfg.SBSHost, cfg.SBSPort)
+	}
+	if cfg.HTTPAddr != ":9000" {
+		t.Fatalf("expected file value to survive, got %q", cfg.HTTPAddr)
+	}
+	if cfg.Database.Password != "secret" {
+		t.Fatalf("expected db password from env")
+	}
+
+	t.Setenv("SKYWATCH_SBS_PORT", "nope")
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for malformed SKYWATCH_SBS_PORT")
+	}
+}
+
+func TestReceiverAtZeroIsConfigured(t *testing.T) {
+	dir := t.TempDir()
+	unset := filepath.Join(dir, "unset.json")
+	zero := filepath.Join(dir, "zero.json")
+	if err := os.WriteFile(unset, []byte(`{"node_name": "Boat"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(zero, []byte(`{"rx_lat": 0, "rx_lon": 0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(unset)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HasRxLocation() {
+		t.Fatal("expected no receiver location when rx_lat/rx_lon are absent")
+	}
+
+	cfg, err = Load(zero)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.HasRxLocation() || cfg.RxLat != 0 || cfg.RxLon != 0 {
+		t.Fatalf("expected a receiver at 0,0, got %v,%v set=%v", cfg.RxLat, cfg.RxLon, cfg.HasRxLocation())
+	}
+
+	t.Setenv("SKYWATCH_RX_LON", "0")
+	cfg, err = Load(unset)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HasRxLocation() {
+		t.Fatal("expected SKYWATCH_RX_LON alone not to set the receiver location")
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "set together") {
+		t.Fatalf("expected a lone rx_lon to be rejected, got %v", err)
+	}
+
+	t.Setenv("SKYWATCH_RX_LAT", "51.47")
+	cfg, err = Load(unset)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.HasRxLocation() || cfg.Validate() != nil {
+		t.Fatal("expected SKYWATCH_RX_LAT and SKYWATCH_RX_LON to set the receiver location")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config should be valid: %v", err)
+	}
+
+	cfg := Default()
+	cfg.FeedFormat = "baest"
+	cfg.LogLevel = "verbose"
+	cfg.SBSPort = -1
+	cfg.RxLat = 91
+	cfg.RxLon = math.NaN()
+	cfg.StaleTimeout = 0
+	cfg.RangeBuckets = 7
+	cfg.Database.MaxIdleConns = 50
+	cfg.Webhooks.HealthThresholds.CPUPercent = 150
+	cfg.Webhooks.Cooldowns["new_aircarft"] = time.Minute
+	cfg.Webhooks.Events.EmergencyAlerts = map[string]bool{"7070": false}
+	cfg.GPSD = GPSDConfig{Enabled: true, Addr: "localhost", Interval: 30 * time.Second}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"feed_format", "log_level", "sbs_port", "rx_lat", "rx_lon", "stale_timeout", "range_buckets", "max_idle_conns", "cpu_percent", "new_aircarft", "7070", "gpsd.addr"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestWatchlistGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"webhooks": {"events": {
+		"aircraft_watchlist": ["N12345"],
+		"watchlists": [{"name": "Military", "patterns": ["RCH*"], "color": "#556B2F"}]
+	}}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	groups := cfg.Webhooks.Events.WatchlistGroups()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", groups)
+	}
+	if groups[0].Name != "" || groups[0].Patterns[0] != "N12345" {
+		t.Fatalf("expected legacy list first as unnamed group, got %+v", groups[0])
+	}
+	if color, ok := groups[1].ColorValue(); groups[1].Name != "Military" || !ok || color != 0x556B2F {
+		t.Fatalf("unexpected named group %+v (color %x)", groups[1], color)
+	}
+}
+
+func TestLoadZeroIdleConns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"database": {"max_idle_conns": 0}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Database.MaxIdleConns != 0 {
+		t.Fatalf("expected max_idle_conns 0 to be kept, got %d", cfg.Database.MaxIdleConns)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected max_idle_conns 0 to be valid: %v", err)
+	}
+}
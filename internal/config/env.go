@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const envPrefix = "SKYWATCH_"
+
+// applyEnv overlays SKYWATCH_* environment variables onto cfg. It lets
+// container deployments keep secrets such as the database password and
+// webhook URLs out of the config file.
+func applyEnv(cfg *Config) error {
+	strs := map[string]*string{
+		"SBS_HOST":             &cfg.SBSHost,
+		"FEED_FORMAT":          &cfg.FeedFormat,
+		"HTTP_ADDR":            &cfg.HTTPAddr,
+		"PPROF_ADDR":           &cfg.PprofAddr,
+		"NODE_NAME":            &cfg.NodeName,
+		"API_KEY":              &cfg.APIKey,
+		"UNITS":                &cfg.Units,
+		"LOG_LEVEL":            &cfg.LogLevel,
+		"LOG_FORMAT":           &cfg.LogFormat,
+		"DB_HOST":              &cfg.Database.Host,
+		"DB_USER":              &cfg.Database.User,
+		"DB_PASSWORD":          &cfg.Database.Password,
+		"DB_NAME":              &cfg.Database.DBName,
+		"DB_SSLMODE":           &cfg.Database.SSLMode,
+		"WEBHOOK_PROVIDER":     &cfg.Webhooks.Provider,
+		"WEBHOOK_URL":          &cfg.Webhooks.URL,
+		"DISCORD_URL":          &cfg.Webhooks.DiscordURL,
+		"WEBHOOK_MAP_TILE_URL": &cfg.Webhooks.Map.TileURL,
+		"GPSD_ADDR":            &cfg.GPSD.Addr,
+	}
+	for name, dst := range strs {
+		if v, ok := lookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"SBS_PORT":          &cfg.SBSPort,
+		"DB_PORT":           &cfg.Database.Port,
+		"DB_MAX_OPEN_CONNS": &cfg.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &cfg.Database.MaxIdleConns,
+		"DEVICE_INDEX":      &cfg.DeviceIndex,
+		"TRAIL_LENGTH":      &cfg.TrailLength,
+		"MAX_AIRCRAFT":      &cfg.MaxAircraft,
+		"RANGE_BUCKETS":     &cfg.RangeBuckets,
+		"WEBHOOK_MAP_ZOOM":  &cfg.Webhooks.Map.Zoom,
+	}
+	for name, dst := range ints {
+		if v, ok := lookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, name, err)
+			}
+			*dst = n
+		}
+	}
+
+	floats := map[string]*float64{
+		"RX_LAT": &cfg.RxLat,
+		"RX_LON": &cfg.RxLon,
+	}
+	for name, dst := range floats {
+		if v, ok := lookupEnv(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, name, err)
+			}
+			*dst = f
+			switch dst {
+			case &cfg.RxLat:
+				cfg.HasRxLat = true
+			case &cfg.RxLon:
+				cfg.HasRxLon = true
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"STALE_TIMEOUT":        &cfg.StaleTimeout,
+		"FLIGHT_SPLIT_GAP":     &cfg.FlightSplitGap,
+		"HEALTH_INTERVAL":      &cfg.HealthInterval,
+		"MAX_TIMESTAMP_AGE":    &cfg.MaxTimestampAge,
+		"DB_CONN_MAX_LIFETIME": &cfg.Database.ConnMaxLifetime,
+		"DB_CONNECT_TIMEOUT":   &cfg.Database.ConnectTimeout,
+	}
+	for name, dst := range durations {
+		if v, ok := lookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, name, err)
+			}
+			*dst = d
+		}
+	}
+
+	return nil
+}
+
+func lookupEnv(name string) (string, bool) {
+	v, ok := os.LookupEnv(envPrefix + name)
+	if !ok || v == "" {
+		return "", false
+	}
+	return v, true
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate reports every setting that would leave the tracker in a broken
+// state, so misconfiguration fails at startup instead of at connect time.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.SBSHost == "" {
+		add("sbs_host must not be empty")
+	}
+	if c.SBSPort < 1 || c.SBSPort > 65535 {
+		add("sbs_port %d is out of range 1-65535", c.SBSPort)
+	}
+	switch c.FeedFormat {
+	case "sbs", "beast":
+	default:
+		add("unknown feed_format %q (expected \"sbs\" or \"beast\")", c.FeedFormat)
+	}
+	switch c.Units {
+	case "nm", "km", "mi":
+	default:
+		add("unknown units %q (expected \"nm\", \"km\" or \"mi\")", c.Units)
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		add("unknown log_level %q (expected \"debug\", \"info\", \"warn\" or \"error\")", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "text", "json":
+	default:
+		add("unknown log_format %q (expected \"text\" or \"json\")", c.LogFormat)
+	}
+	// Written as negated ranges so NaN is rejected too.
+	if !(c.RxLat >= -90 && c.RxLat <= 90) {
+		add("rx_lat %v is out of range -90 to 90", c.RxLat)
+	}
+	if !(c.RxLon >= -180 && c.RxLon <= 180) {
+		add("rx_lon %v is out of range -180 to 180", c.RxLon)
+	}
+	if c.HasRxLat != c.HasRxLon {
+		add("rx_lat and rx_lon must be set together")
+	}
+	if c.StaleTimeout <= 0 {
+		add("stale_timeout must be positive, got %v", c.StaleTimeout)
+	}
+	if c.MaxTimestampAge < 0 {
+		add("max_timestamp_age must not be negative, got %v", c.MaxTimestampAge)
+	}
+	if c.FlightSplitGap <= 0 {
+		add("flight_split_gap must be positive, got %v", c.FlightSplitGap)
+	}
+	if c.TrailLength < 0 {
+		add("trail_length must not be negative, got %d", c.TrailLength)
+	}
+	if c.Dump1090Gain != nil && !(*c.Dump1090Gain >= 0 && *c.Dump1090Gain <= 49.6) {
+		add("dump1090_gain %v is out of range 0 to 49.6", *c.Dump1090Gain)
+	}
+	if c.MaxAircraft < 0 {
+		add("max_aircraft must not be negative, got %d", c.MaxAircraft)
+	}
+	if c.RangeBuckets < 1 || 360%c.RangeBuckets != 0 {
+		add("range_buckets %d must divide 360 evenly (e.g. 36 or 72)", c.RangeBuckets)
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		add("database.port %d is out of range 1-65535", c.Database.Port)
+	}
+	if c.Database.MaxOpenConns < 1 {
+		add("database.max_open_conns must be at least 1, got %d", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns %d must be between 0 and max_open_conns", c.Database.MaxIdleConns)
+	}
+	if c.Database.ConnMaxLifetime <= 0 {
+		add("database.conn_max_lifetime must be positive, got %v", c.Database.ConnMaxLifetime)
+	}
+	if c.Database.ConnectTimeout < 0 {
+		add("database.connect_timeout must not be negative, got %v", c.Database.ConnectTimeout)
+	}
+	if c.Lookup.RateLimit < 0 {
+		add("lookup.rate_limit must not be negative, got %v", c.Lookup.RateLimit)
+	}
+
+	thresholds := []struct {
+		name  string
+		value int
+	}{
+		{"cpu_percent", c.Webhooks.HealthThresholds.CPUPercent},
+		{"memory_percent", c.Webhooks.HealthThresholds.MemoryPercent},
+		{"temp_celsius", c.Webhooks.HealthThresholds.TempCelsius},
+		{"disk_percent", c.Webhooks.HealthThresholds.DiskPercent},
+	}
+	for _, t := range thresholds {
+		if t.value < 0 || t.value > 100 {
+			add("webhooks.health_thresholds.%s %d is out of range 0-100", t.name, t.value)
+		}
+	}
+	if c.Webhooks.HealthThresholds.MinMessagesPerSec < 0 {
+		add("webhooks.health_thresholds.min_messages_per_sec %d must not be negative", c.Webhooks.HealthThresholds.MinMessagesPerSec)
+	}
+
+	if m := c.Webhooks.Map; m.TileURL != "" {
+		for _, p := range []string{"{z}", "{x}", "{y}"} {
+			if !strings.Contains(m.TileURL, p) {
+				add("webhooks.map.tile_url must contain %s", p)
+			}
+		}
+		if m.Zoom < 0 || m.Zoom > 19 {
+			add("webhooks.map.zoom %d is out of range 0-19", m.Zoom)
+		}
+	}
+
+	squawks := c.Webhooks.Events.EmergencySquawks
+	if len(squawks) == 0 {
+		squawks = DefaultEmergencySquawks()
+	}
+	for code := range c.Webhooks.Events.EmergencyAlerts {
+		if code != EmergencyFlag && !slices.Contains(squawks, code) {
+			add("webhooks.events.emergency_alerts code %q is not in emergency_squawks", code)
+		}
+	}
+
+	if esc := c.Webhooks.Escalation; esc.Interval != 0 {
+		if esc.Interval < time.Minute {
+			add("webhooks.escalation.interval must be 0 or at least 1m, got %v", esc.Interval)
+		}
+		for _, code := range esc.Codes {
+			if code != EmergencyFlag && !slices.Contains(squawks, code) {
+				add("webhooks.escalation.codes code %q is not in emergency_squawks", code)
+			}
+		}
+	}
+
+	for eventType, d := range c.Webhooks.Cooldowns {
+		if !slices.Contains(WebhookEventTypes, eventType) {
+			add("webhooks.cooldowns has unknown event type %q", eventType)
+		}
+		if d < 0 {
+			add("webhooks.cooldowns.%s must not be negative, got %v", eventType, d)
+		}
+	}
+	if c.Webhooks.Events.Digest && c.Webhooks.DigestInterval < time.Minute {
+		add("webhooks.digest_interval must be at least 1m, got %v", c.Webhooks.DigestInterval)
+	}
+	if c.Webhooks.NewAircraftPerMinute < 0 {
+		add("webhooks.new_aircraft_per_minute must not be negative, got %d", c.Webhooks.NewAircraftPerMinute)
+	}
+
+	for i, g := range c.Webhooks.Events.Watchlists {
+		if len(g.Patterns) == 0 {
+			add("webhooks.events.watchlists[%d] (%s) has no patterns", i, g.Name)
+		}
+		if _, ok := g.ColorValue(); g.Color != "" && !ok {
+			add("webhooks.events.watchlists[%d] (%s) color %q is not a hex RGB value", i, g.Name, g.Color)
+		}
+	}
+
+	if c.GPSD.Enabled {
+		if _, _, err := net.SplitHostPort(c.GPSD.Addr); err != nil {
+			add("gpsd.addr %q must be host:port", c.GPSD.Addr)
+		}
+		if c.GPSD.Interval < time.Second {
+			add("gpsd.interval must be at least 1s, got %v", c.GPSD.Interval)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migration is one step of the schema. Steps run in version order and each
+// is recorded in schema_migrations once applied. Add new steps to the end
+// of migrations and never change one that has shipped. Steps should still
+// use IF NOT EXISTS, since databases created before versioning already
+// have some of the schema.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+var migrations = []migration{
+	{1, "initial schema", `
+	CREATE TABLE IF NOT EXISTS aircraft (
+		icao VARCHAR(6) PRIMARY KEY,
+		callsign VARCHAR(10),
+		registration VARCHAR(10),
+		aircraft_type VARCHAR(10),
+		operator VARCHAR(100),
+		lat DOUBLE PRECISION,
+		lon DOUBLE PRECISION,
+		altitude_ft INTEGER,
+		speed_kt DOUBLE PRECISION,
+		heading DOUBLE PRECISION,
+		vertical_rate INTEGER,
+		squawk VARCHAR(4),
+		on_ground BOOLEAN,
+		last_seen TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS position_history (
+		id SERIAL PRIMARY KEY,
+		icao VARCHAR(6) NOT NULL,
+		lat DOUBLE PRECISION NOT NULL,
+		lon DOUBLE PRECISION NOT NULL,
+		altitude_ft INTEGER,
+		speed_kt DOUBLE PRECISION,
+		heading DOUBLE PRECISION,
+		timestamp TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_position_history_icao ON position_history(icao);
+	CREATE INDEX IF NOT EXISTS idx_position_history_timestamp ON position_history(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_position_history_icao_timestamp ON position_history(icao, timestamp DESC);
+
+	CREATE TABLE IF NOT EXISTS faa_registry (
+		icao VARCHAR(6) PRIMARY KEY,
+		registration VARCHAR(10),
+		aircraft_type VARCHAR(10),
+		manufacturer VARCHAR(100),
+		model VARCHAR(100),
+		operator VARCHAR(100),
+		owner VARCHAR(100)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_faa_registry_registration ON faa_registry(registration);
+
+	CREATE TABLE IF NOT EXISTS faa_lookup_misses (
+		icao VARCHAR(6) PRIMARY KEY,
+		checked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS session_stats (
+		id INTEGER PRIMARY KEY DEFAULT 1,
+		total_seen INTEGER DEFAULT 0,
+		max_range_nm DOUBLE PRECISION DEFAULT 0,
+		max_range_icao VARCHAR(6),
+		session_start TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		last_save TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		CONSTRAINT single_row CHECK (id = 1)
+	);
+
+	CREATE TABLE IF NOT EXISTS flights (
+		id SERIAL PRIMARY KEY,
+		icao VARCHAR(6) NOT NULL,
+		callsign VARCHAR(10),
+		registration VARCHAR(10),
+		aircraft_type VARCHAR(10),
+		first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+		last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+		first_lat DOUBLE PRECISION,
+		first_lon DOUBLE PRECISION,
+		last_lat DOUBLE PRECISION,
+		last_lon DOUBLE PRECISION,
+		max_alt_ft INTEGER,
+		total_dist_nm DOUBLE PRECISION DEFAULT 0,
+		completed BOOLEAN DEFAULT FALSE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_flights_icao ON flights(icao);
+	CREATE INDEX IF NOT EXISTS idx_flights_last_seen ON flights(last_seen DESC);
+	CREATE INDEX IF NOT EXISTS idx_flights_completed ON flights(completed);
+	`},
+	{2, "range stats per altitude band and bucket count", `
+	CREATE TABLE IF NOT EXISTS range_stats (
+		altitude_band VARCHAR(8) NOT NULL DEFAULT 'all',
+		bucket_count INTEGER NOT NULL DEFAULT 36,
+		bearing_bucket INTEGER NOT NULL,
+		max_range_nm DOUBLE PRECISION DEFAULT 0,
+		max_range_icao VARCHAR(6),
+		contact_count BIGINT DEFAULT 0,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		CONSTRAINT range_stats_key PRIMARY KEY (altitude_band, bucket_count, bearing_bucket),
+		CONSTRAINT range_stats_bucket_range CHECK (bucket_count > 0 AND 360 % bucket_count = 0 AND bearing_bucket >= 0 AND bearing_bucket < bucket_count)
+	);
+
+	ALTER TABLE range_stats ADD COLUMN IF NOT EXISTS altitude_band VARCHAR(8) NOT NULL DEFAULT 'all';
+	ALTER TABLE range_stats ADD COLUMN IF NOT EXISTS bucket_count INTEGER NOT NULL DEFAULT 36;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'range_stats_key') THEN
+			ALTER TABLE range_stats DROP CONSTRAINT IF EXISTS range_stats_pkey;
+			ALTER TABLE range_stats DROP CONSTRAINT IF EXISTS range_stats_band_bucket_pkey;
+			ALTER TABLE range_stats ADD CONSTRAINT range_stats_key PRIMARY KEY (altitude_band, bucket_count, bearing_bucket);
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'range_stats_bucket_range') THEN
+			ALTER TABLE range_stats ADD CONSTRAINT range_stats_bucket_range
+				CHECK (bucket_count > 0 AND 360 % bucket_count = 0 AND bearing_bucket >= 0 AND bearing_bucket < bucket_count);
+		END IF;
+	END $$;
+	`},
+	{3, "flight duration, average speed and altitude profile", `
+	ALTER TABLE flights ADD COLUMN IF NOT EXISTS duration_s INTEGER;
+	ALTER TABLE flights ADD COLUMN IF NOT EXISTS avg_speed_kt DOUBLE PRECISION;
+	ALTER TABLE flights ADD COLUMN IF NOT EXISTS altitude_profile JSONB;
+	`},
+	{4, "registry record source and age", `
+	ALTER TABLE faa_registry ADD COLUMN IF NOT EXISTS source VARCHAR(20);
+	ALTER TABLE faa_registry ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+	ALTER TABLE faa_registry ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+	`},
+	{5, "room for non-ICAO addresses", `
+	ALTER TABLE aircraft ALTER COLUMN icao TYPE VARCHAR(7);
+	ALTER TABLE position_history ALTER COLUMN icao TYPE VARCHAR(7);
+	ALTER TABLE flights ALTER COLUMN icao TYPE VARCHAR(7);
+	ALTER TABLE session_stats ALTER COLUMN max_range_icao TYPE VARCHAR(7);
+	ALTER TABLE range_stats ALTER COLUMN max_range_icao TYPE VARCHAR(7);
+	`},
+}
+
+// migrationLock is the advisory lock key held while a migration runs, so
+// two trackers starting against the same database don't race.
+const migrationLock = 0x736b7977
+
+func (db *DB) migrate(steps []migration) error {
+	_, err := db.conn.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	for _, m := range steps {
+		applied, err := db.applyMigration(m)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if applied {
+			logger.Info("applied migration", "version", m.version, "name", m.name)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs m in a transaction unless it has already been
+// applied, and reports whether it ran.
+func (db *DB) applyMigration(m migration) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return false, err
+	}
+
+	var version int
+	err = tx.QueryRow(`SELECT version FROM schema_migrations WHERE version = $1`, m.version).Scan(&version)
+	if err == nil {
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,14 @@
+package database
+
+import "testing"
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Fatalf("migration %q has version %d, expected %d", m.name, m.version, i+1)
+		}
+		if m.name == "" || m.sql == "" {
+			t.Fatalf("migration %d is missing a name or SQL", m.version)
+		}
+	}
+}
@@ -3,23 +3,38 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
+	"adsb-tracker/internal/logging"
+
 	_ "github.com/lib/pq"
 )
 
+var logger = logging.Component("database")
+
 type DB struct {
 	conn *sql.DB
 }
 
+// Connection pool defaults, used when the matching Config field is zero, or
+// for MaxIdleConns negative, since 0 idle connections is a valid setting.
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 5 * time.Minute
+)
+
 type Config struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host            string
+	Port            int
+	User            string
+	Password        string
+	DBName          string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 func (c Config) ConnectionString() string {
@@ -28,7 +43,37 @@ func (c Config) ConnectionString() string {
 		sslMode = "disable"
 	}
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.Host, c.Port, c.User, c.Password, c.DBName, sslMode)
+		quoteValue(c.Host), c.Port, quoteValue(c.User), quoteValue(c.Password),
+		quoteValue(c.DBName), quoteValue(sslMode))
+}
+
+// quoteValue quotes a keyword/value connection string value the way libpq
+// expects: empty values and values containing whitespace, quotes or
+// backslashes are wrapped in single quotes, with quotes and backslashes
+// escaped.
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\r\v\f'\\") {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(v) + "'"
+}
+
+// poolLimits returns the pool settings with defaults filled in.
+func (c Config) poolLimits() (maxOpen, maxIdle int, lifetime time.Duration) {
+	maxOpen = c.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = DefaultMaxOpenConns
+	}
+	maxIdle = c.MaxIdleConns
+	if maxIdle < 0 {
+		maxIdle = DefaultMaxIdleConns
+	}
+	lifetime = c.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultConnMaxLifetime
+	}
+	return maxOpen, maxIdle, lifetime
 }
 
 func Connect(cfg Config) (*DB, error) {
@@ -37,115 +82,63 @@ func Connect(cfg Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	conn.SetMaxOpenConns(25)
-	conn.SetMaxIdleConns(5)
-	conn.SetConnMaxLifetime(5 * time.Minute)
+	maxOpen, maxIdle, lifetime := cfg.poolLimits()
+	conn.SetMaxOpenConns(maxOpen)
+	conn.SetMaxIdleConns(maxIdle)
+	conn.SetConnMaxLifetime(lifetime)
 
 	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Printf("[DB] Connected to PostgreSQL at %s:%d", cfg.Host, cfg.Port)
+	logger.Info("connected to PostgreSQL", "host", cfg.Host, "port", cfg.Port)
 	return &DB{conn: conn}, nil
 }
 
+// maxRetryBackoff caps the wait between connection attempts.
+const maxRetryBackoff = 10 * time.Second
+
+// ConnectWithRetry calls Connect until it succeeds or timeout has passed,
+// doubling the wait between attempts from one second. This rides out a
+// database that starts a little after the tracker. A timeout of zero tries
+// once.
+func ConnectWithRetry(cfg Config, timeout time.Duration) (*DB, error) {
+	deadline := time.Now().Add(timeout)
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		db, err := Connect(cfg)
+		if err == nil {
+			return db, nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+		if backoff < wait {
+			wait = backoff
+		}
+		logger.Warn("connection attempt failed", "attempt", attempt, "error", err, "retry_in", wait.Round(time.Millisecond))
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
 func (db *DB) Migrate() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS aircraft (
-		icao VARCHAR(6) PRIMARY KEY,
-		callsign VARCHAR(10),
-		registration VARCHAR(10),
-		aircraft_type VARCHAR(10),
-		operator VARCHAR(100),
-		lat DOUBLE PRECISION,
-		lon DOUBLE PRECISION,
-		altitude_ft INTEGER,
-		speed_kt DOUBLE PRECISION,
-		heading DOUBLE PRECISION,
-		vertical_rate INTEGER,
-		squawk VARCHAR(4),
-		on_ground BOOLEAN,
-		last_seen TIMESTAMP WITH TIME ZONE,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-
-	CREATE TABLE IF NOT EXISTS position_history (
-		id SERIAL PRIMARY KEY,
-		icao VARCHAR(6) NOT NULL,
-		lat DOUBLE PRECISION NOT NULL,
-		lon DOUBLE PRECISION NOT NULL,
-		altitude_ft INTEGER,
-		speed_kt DOUBLE PRECISION,
-		heading DOUBLE PRECISION,
-		timestamp TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_position_history_icao ON position_history(icao);
-	CREATE INDEX IF NOT EXISTS idx_position_history_timestamp ON position_history(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_position_history_icao_timestamp ON position_history(icao, timestamp DESC);
-
-	CREATE TABLE IF NOT EXISTS faa_registry (
-		icao VARCHAR(6) PRIMARY KEY,
-		registration VARCHAR(10),
-		aircraft_type VARCHAR(10),
-		manufacturer VARCHAR(100),
-		model VARCHAR(100),
-		operator VARCHAR(100),
-		owner VARCHAR(100)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_faa_registry_registration ON faa_registry(registration);
-
-	CREATE TABLE IF NOT EXISTS session_stats (
-		id INTEGER PRIMARY KEY DEFAULT 1,
-		total_seen INTEGER DEFAULT 0,
-		max_range_nm DOUBLE PRECISION DEFAULT 0,
-		max_range_icao VARCHAR(6),
-		session_start TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		last_save TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		CONSTRAINT single_row CHECK (id = 1)
-	);
-
-	CREATE TABLE IF NOT EXISTS range_stats (
-		bearing_bucket INTEGER PRIMARY KEY,
-		max_range_nm DOUBLE PRECISION DEFAULT 0,
-		max_range_icao VARCHAR(6),
-		contact_count BIGINT DEFAULT 0,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-
-	CREATE TABLE IF NOT EXISTS flights (
-		id SERIAL PRIMARY KEY,
-		icao VARCHAR(6) NOT NULL,
-		callsign VARCHAR(10),
-		registration VARCHAR(10),
-		aircraft_type VARCHAR(10),
-		first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
-		last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
-		first_lat DOUBLE PRECISION,
-		first_lon DOUBLE PRECISION,
-		last_lat DOUBLE PRECISION,
-		last_lon DOUBLE PRECISION,
-		max_alt_ft INTEGER,
-		total_dist_nm DOUBLE PRECISION DEFAULT 0,
-		completed BOOLEAN DEFAULT FALSE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_flights_icao ON flights(icao);
-	CREATE INDEX IF NOT EXISTS idx_flights_last_seen ON flights(last_seen DESC);
-	CREATE INDEX IF NOT EXISTS idx_flights_completed ON flights(completed);
-	`
-
-	_, err := db.conn.Exec(schema)
-	if err != nil {
+	if err := db.migrate(migrations); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Printf("[DB] Database schema migrated successfully")
+	logger.Info("database schema migrated")
 	return nil
 }
 
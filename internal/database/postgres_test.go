@@ -0,0 +1,83 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestConnectionStringQuotesValues(t *testing.T) {
+	cfg := Config{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "skywatch",
+		Password: `it's a pass\word`,
+		DBName:   "adsb",
+	}
+
+	got := cfg.ConnectionString()
+	want := `host=localhost port=5432 user=skywatch password='it\'s a pass\\word' dbname=adsb sslmode=disable`
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if _, err := pq.NewConnector(got); err != nil {
+		t.Fatalf("connection string rejected: %v", err)
+	}
+}
+
+func TestConnectionStringEmptyPassword(t *testing.T) {
+	cfg := Config{Host: "localhost", Port: 5432, User: "skywatch", DBName: "adsb", SSLMode: "require"}
+
+	got := cfg.ConnectionString()
+	want := `host=localhost port=5432 user=skywatch password='' dbname=adsb sslmode=require`
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if _, err := pq.NewConnector(got); err != nil {
+		t.Fatalf("connection string rejected: %v", err)
+	}
+}
+
+func TestConnectWithRetryGivesUp(t *testing.T) {
+	// Nothing listens on port 1, so every attempt is refused at once.
+	cfg := Config{Host: "127.0.0.1", Port: 1, User: "skywatch", DBName: "adsb"}
+
+	start := time.Now()
+	if _, err := ConnectWithRetry(cfg, 0); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected a single attempt with zero timeout, took %v", elapsed)
+	}
+
+	start = time.Now()
+	_, err := ConnectWithRetry(cfg, 1500*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "gave up after 3 attempts") {
+		t.Fatalf("expected attempts at 0s, 1s and the 1.5s deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("expected to retry until the timeout, took %v", elapsed)
+	}
+}
+
+func TestPoolLimits(t *testing.T) {
+	maxOpen, maxIdle, lifetime := Config{}.poolLimits()
+	if maxOpen != DefaultMaxOpenConns || maxIdle != 0 || lifetime != DefaultConnMaxLifetime {
+		t.Fatalf("unexpected limits for zero config: %d %d %v", maxOpen, maxIdle, lifetime)
+	}
+
+	_, maxIdle, _ = Config{MaxIdleConns: -1}.poolLimits()
+	if maxIdle != DefaultMaxIdleConns {
+		t.Fatalf("expected negative max idle to use the default, got %d", maxIdle)
+	}
+
+	maxOpen, maxIdle, lifetime = Config{MaxOpenConns: 10, MaxIdleConns: 2, ConnMaxLifetime: time.Minute}.poolLimits()
+	if maxOpen != 10 || maxIdle != 2 || lifetime != time.Minute {
+		t.Fatalf("expected configured limits to be kept, got %d %d %v", maxOpen, maxIdle, lifetime)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"math"
+	"time"
+
+	"adsb-tracker/pkg/models"
+)
+
+// downsamplePositions averages time-ordered positions into at most points
+// buckets of equal duration, leaving out empty buckets. Heading is dropped
+// since it does not average meaningfully. Positions already within points
+// are returned unchanged.
+func downsamplePositions(positions []models.Position, points int) []models.Position {
+	if points <= 0 || len(positions) <= points {
+		return positions
+	}
+
+	first := positions[0].Timestamp
+	span := positions[len(positions)-1].Timestamp.Sub(first)
+
+	type bucket struct {
+		n            int
+		lat, lon     float64
+		alt, speed   float64
+		altN, speedN int
+		offset       time.Duration
+	}
+	buckets := make([]bucket, points)
+	for _, p := range positions {
+		i := 0
+		if span > 0 {
+			i = int(float64(p.Timestamp.Sub(first)) / float64(span) * float64(points))
+		}
+		if i >= points {
+			i = points - 1
+		}
+		b := &buckets[i]
+		b.n++
+		b.lat += p.Lat
+		b.lon += p.Lon
+		b.offset += p.Timestamp.Sub(first)
+		if p.AltitudeFt != nil {
+			b.alt += float64(*p.AltitudeFt)
+			b.altN++
+		}
+		if p.SpeedKt != nil {
+			b.speed += *p.SpeedKt
+			b.speedN++
+		}
+	}
+
+	out := make([]models.Position, 0, points)
+	for _, b := range buckets {
+		if b.n == 0 {
+			continue
+		}
+		p := models.Position{
+			Lat:       b.lat / float64(b.n),
+			Lon:       b.lon / float64(b.n),
+			Timestamp: first.Add(b.offset / time.Duration(b.n)),
+		}
+		if b.altN > 0 {
+			alt := int(math.Round(b.alt / float64(b.altN)))
+			p.AltitudeFt = &alt
+		}
+		if b.speedN > 0 {
+			speed := b.speed / float64(b.speedN)
+			p.SpeedKt = &speed
+		}
+		out = append(out, p)
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"adsb-tracker/pkg/models"
+)
+
+func TestDownsamplePositions(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var positions []models.Position
+	for i := 0; i < 10; i++ {
+		alt := 1000 * i
+		speed := float64(100 + i)
+		positions = append(positions, models.Position{
+			Lat:        50 + float64(i)/10,
+			Lon:        -1,
+			AltitudeFt: &alt,
+			SpeedKt:    &speed,
+			Timestamp:  start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	positions[1].AltitudeFt = nil
+
+	if got := downsamplePositions(positions, 0); len(got) != 10 {
+		t.Fatalf("expected points=0 to keep all positions, got %d", len(got))
+	}
+	if got := downsamplePositions(positions, 20); len(got) != 10 {
+		t.Fatalf("expected fewer positions than points to be kept, got %d", len(got))
+	}
+
+	got := downsamplePositions(positions, 5)
+	if len(got) != 5 {
+		t.Fatalf("expected 5 points, got %d", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].Timestamp.After(got[i-1].Timestamp) {
+			t.Fatalf("expected ascending timestamps, got %v then %v", got[i-1].Timestamp, got[i].Timestamp)
+		}
+	}
+	// The first bucket holds minutes 0 and 1; only minute 0 has an altitude.
+	first := got[0]
+	if *first.AltitudeFt != 0 || *first.SpeedKt != 100.5 || first.Timestamp != start.Add(30*time.Second) {
+		t.Fatalf("unexpected first bucket %+v alt=%d speed=%v", first, *first.AltitudeFt, *first.SpeedKt)
+	}
+	if last := got[4]; *last.AltitudeFt != 8500 {
+		t.Fatalf("expected last bucket altitude 8500, got %d", *last.AltitudeFt)
+	}
+}
@@ -2,36 +2,73 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
 	"time"
 
 	"adsb-tracker/pkg/models"
+
+	"github.com/lib/pq"
 )
 
 type Repository struct {
 	db *sql.DB
+
+	stmtMu sync.Mutex
+	stmts  map[string]*sql.Stmt
 }
 
 func NewRepository(db *DB) *Repository {
 	return &Repository{db: db.Conn()}
 }
 
-func (r *Repository) SaveAircraft(ac *models.Aircraft) error {
-	query := `
-		INSERT INTO aircraft (icao, callsign, lat, lon, altitude_ft, speed_kt, heading, vertical_rate, squawk, on_ground, last_seen)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (icao) DO UPDATE SET
-			callsign = COALESCE(NULLIF($2, ''), aircraft.callsign),
-			lat = COALESCE($3, aircraft.lat),
-			lon = COALESCE($4, aircraft.lon),
-			altitude_ft = COALESCE($5, aircraft.altitude_ft),
-			speed_kt = COALESCE($6, aircraft.speed_kt),
-			heading = COALESCE($7, aircraft.heading),
-			vertical_rate = COALESCE($8, aircraft.vertical_rate),
-			squawk = COALESCE(NULLIF($9, ''), aircraft.squawk),
-			on_ground = COALESCE($10, aircraft.on_ground),
-			last_seen = $11
-	`
+// exec runs a statement on the persistence hot path through a cache of
+// prepared statements, so it is parsed once per connection rather than on
+// every call. It falls back to a plain Exec if preparing fails.
+func (r *Repository) exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := r.prepare(query)
+	if err != nil {
+		return r.db.Exec(query, args...)
+	}
+	return stmt.Exec(args...)
+}
+
+func (r *Repository) prepare(query string) (*sql.Stmt, error) {
+	r.stmtMu.Lock()
+	defer r.stmtMu.Unlock()
 
+	if stmt, ok := r.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if r.stmts == nil {
+		r.stmts = make(map[string]*sql.Stmt)
+	}
+	r.stmts[query] = stmt
+	return stmt, nil
+}
+
+const saveAircraftQuery = `
+	INSERT INTO aircraft (icao, callsign, lat, lon, altitude_ft, speed_kt, heading, vertical_rate, squawk, on_ground, last_seen)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (icao) DO UPDATE SET
+		callsign = COALESCE(NULLIF($2, ''), aircraft.callsign),
+		lat = COALESCE($3, aircraft.lat),
+		lon = COALESCE($4, aircraft.lon),
+		altitude_ft = COALESCE($5, aircraft.altitude_ft),
+		speed_kt = COALESCE($6, aircraft.speed_kt),
+		heading = COALESCE($7, aircraft.heading),
+		vertical_rate = COALESCE($8, aircraft.vertical_rate),
+		squawk = COALESCE(NULLIF($9, ''), aircraft.squawk),
+		on_ground = COALESCE($10, aircraft.on_ground),
+		last_seen = $11
+`
+
+func (r *Repository) SaveAircraft(ac *models.Aircraft) error {
 	var lat, lon, speedKt, heading *float64
 	var altFt, vertRate *int
 	var onGround *bool
@@ -58,21 +95,21 @@ func (r *Repository) SaveAircraft(ac *models.Aircraft) error {
 		onGround = ac.OnGround
 	}
 
-	_, err := r.db.Exec(query, ac.ICAO, ac.Callsign, lat, lon, altFt, speedKt, heading, vertRate, ac.Squawk, onGround, ac.LastSeen)
+	_, err := r.exec(saveAircraftQuery, ac.ICAO, ac.Callsign, lat, lon, altFt, speedKt, heading, vertRate, ac.Squawk, onGround, ac.LastSeen)
 	return err
 }
 
+const savePositionQuery = `
+	INSERT INTO position_history (icao, lat, lon, altitude_ft, speed_kt, heading, timestamp)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+`
+
 func (r *Repository) SavePosition(ac *models.Aircraft) error {
 	if ac.Lat == nil || ac.Lon == nil {
 		return nil
 	}
 
-	query := `
-		INSERT INTO position_history (icao, lat, lon, altitude_ft, speed_kt, heading, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-
-	_, err := r.db.Exec(query, ac.ICAO, *ac.Lat, *ac.Lon, ac.AltitudeFt, ac.SpeedKt, ac.Heading, ac.LastSeen)
+	_, err := r.exec(savePositionQuery, ac.ICAO, *ac.Lat, *ac.Lon, ac.AltitudeFt, ac.SpeedKt, ac.Heading, ac.LastSeen)
 	return err
 }
 
@@ -91,6 +128,12 @@ func (r *Repository) GetPositionHistory(icao string, limit int) ([]models.Positi
 	}
 	defer rows.Close()
 
+	return scanPositions(rows)
+}
+
+// scanPositions reads rows of lat, lon, altitude_ft, speed_kt, heading and
+// timestamp.
+func scanPositions(rows *sql.Rows) ([]models.Position, error) {
 	positions := []models.Position{}
 	for rows.Next() {
 		var p models.Position
@@ -159,31 +202,36 @@ func (r *Repository) GetPositionHistoryTimeRange(icao string, from, to *time.Tim
 	}
 	defer rows.Close()
 
-	positions := []models.Position{}
-	for rows.Next() {
-		var p models.Position
-		var altFt sql.NullInt64
-		var speedKt, heading sql.NullFloat64
+	return scanPositions(rows)
+}
 
-		if err := rows.Scan(&p.Lat, &p.Lon, &altFt, &speedKt, &heading, &p.Timestamp); err != nil {
-			return []models.Position{}, err
-		}
+// maxProfileRows bounds how many positions GetPositionProfile reads before
+// downsampling.
+const maxProfileRows = 50000
 
-		if altFt.Valid {
-			v := int(altFt.Int64)
-			p.AltitudeFt = &v
-		}
-		if speedKt.Valid {
-			p.SpeedKt = &speedKt.Float64
-		}
-		if heading.Valid {
-			p.Heading = &heading.Float64
-		}
+// GetPositionProfile returns the positions recorded for icao between from
+// and to, oldest first. When points is positive and there are more rows
+// than that, they are averaged into at most points equal time buckets.
+func (r *Repository) GetPositionProfile(icao string, from, to time.Time, points int) ([]models.Position, error) {
+	query := `
+		SELECT lat, lon, altitude_ft, speed_kt, heading, timestamp
+		FROM position_history
+		WHERE icao = $1 AND timestamp >= $2 AND timestamp <= $3
+		ORDER BY timestamp ASC
+		LIMIT $4
+	`
 
-		positions = append(positions, p)
+	rows, err := r.db.Query(query, icao, from, to, maxProfileRows)
+	if err != nil {
+		return []models.Position{}, err
 	}
+	defer rows.Close()
 
-	return positions, rows.Err()
+	positions, err := scanPositions(rows)
+	if err != nil {
+		return positions, err
+	}
+	return downsamplePositions(positions, points), nil
 }
 
 func (r *Repository) CleanupOldPositions(maxAge time.Duration) (int64, error) {
@@ -195,17 +243,34 @@ func (r *Repository) CleanupOldPositions(maxAge time.Duration) (int64, error) {
 	return result.RowsAffected()
 }
 
+// FAARecord is a registry row with where it came from and when it was
+// last written. UpdatedAt is zero for rows saved before this was tracked.
+type FAARecord struct {
+	models.FAAInfo
+	Source    string
+	UpdatedAt time.Time
+}
+
 func (r *Repository) GetFAAInfo(icao string) (*models.FAAInfo, error) {
+	rec, err := r.GetFAARecord(icao)
+	if rec == nil || err != nil {
+		return nil, err
+	}
+	return &rec.FAAInfo, nil
+}
+
+func (r *Repository) GetFAARecord(icao string) (*FAARecord, error) {
 	query := `
-		SELECT registration, aircraft_type, manufacturer, model, operator, owner
+		SELECT registration, aircraft_type, manufacturer, model, operator, owner, source, updated_at
 		FROM faa_registry
 		WHERE icao = $1
 	`
 
-	var info models.FAAInfo
-	var reg, acType, mfr, model, operator, owner sql.NullString
+	var rec FAARecord
+	var reg, acType, mfr, model, operator, owner, source sql.NullString
+	var updatedAt sql.NullTime
 
-	err := r.db.QueryRow(query, icao).Scan(&reg, &acType, &mfr, &model, &operator, &owner)
+	err := r.db.QueryRow(query, icao).Scan(&reg, &acType, &mfr, &model, &operator, &owner, &source, &updatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -213,33 +278,173 @@ func (r *Repository) GetFAAInfo(icao string) (*models.FAAInfo, error) {
 		return nil, err
 	}
 
-	info.Registration = reg.String
-	info.AircraftType = acType.String
-	info.Manufacturer = mfr.String
-	info.Model = model.String
-	info.Operator = operator.String
-	info.Owner = owner.String
+	rec.Registration = reg.String
+	rec.AircraftType = acType.String
+	rec.Manufacturer = mfr.String
+	rec.Model = model.String
+	rec.Operator = operator.String
+	rec.Owner = owner.String
+	rec.Source = source.String
+	rec.UpdatedAt = updatedAt.Time
 
-	return &info, nil
+	return &rec, nil
 }
 
-func (r *Repository) SaveFAAInfo(icao string, info *models.FAAInfo) error {
+// SaveFAAInfo upserts a registry record, noting source as where it came
+// from.
+func (r *Repository) SaveFAAInfo(icao string, info *models.FAAInfo, source string) error {
 	query := `
-		INSERT INTO faa_registry (icao, registration, aircraft_type, manufacturer, model, operator, owner)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO faa_registry (icao, registration, aircraft_type, manufacturer, model, operator, owner, source, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
 		ON CONFLICT (icao) DO UPDATE SET
 			registration = $2,
 			aircraft_type = $3,
 			manufacturer = $4,
 			model = $5,
 			operator = $6,
-			owner = $7
+			owner = $7,
+			source = $8,
+			updated_at = NOW()
+	`
+
+	_, err := r.db.Exec(query, icao, info.Registration, info.AircraftType, info.Manufacturer, info.Model, info.Operator, info.Owner, source)
+	return err
+}
+
+// SaveFAAMiss records that no lookup source had data for icao, so the miss
+// survives restarts. A later successful save clears it.
+func (r *Repository) SaveFAAMiss(icao string) error {
+	query := `
+		INSERT INTO faa_lookup_misses (icao, checked_at)
+		VALUES ($1, NOW())
+		ON CONFLICT (icao) DO UPDATE SET checked_at = NOW()
+	`
+	_, err := r.db.Exec(query, icao)
+	return err
+}
+
+func (r *Repository) DeleteFAAMiss(icao string) error {
+	_, err := r.db.Exec(`DELETE FROM faa_lookup_misses WHERE icao = $1`, icao)
+	return err
+}
+
+// GetFAAMisses returns misses recorded after since, keyed by ICAO.
+func (r *Repository) GetFAAMisses(since time.Time) (map[string]time.Time, error) {
+	rows, err := r.db.Query(`SELECT icao, checked_at FROM faa_lookup_misses WHERE checked_at > $1`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	misses := make(map[string]time.Time)
+	for rows.Next() {
+		var icao string
+		var checkedAt time.Time
+		if err := rows.Scan(&icao, &checkedAt); err != nil {
+			return nil, err
+		}
+		misses[icao] = checkedAt
+	}
+	return misses, rows.Err()
+}
+
+// GetRecentFAAInfo returns registry data for aircraft seen after since.
+func (r *Repository) GetRecentFAAInfo(since time.Time) (map[string]*models.FAAInfo, error) {
+	query := `
+		SELECT f.icao, f.registration, f.aircraft_type, f.manufacturer, f.model, f.operator, f.owner
+		FROM faa_registry f
+		JOIN aircraft a ON a.icao = f.icao
+		WHERE a.last_seen > $1
 	`
 
-	_, err := r.db.Exec(query, icao, info.Registration, info.AircraftType, info.Manufacturer, info.Model, info.Operator, info.Owner)
+	rows, err := r.db.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]*models.FAAInfo)
+	for rows.Next() {
+		var icao string
+		var reg, acType, mfr, model, operator, owner sql.NullString
+		if err := rows.Scan(&icao, &reg, &acType, &mfr, &model, &operator, &owner); err != nil {
+			return nil, err
+		}
+		result[icao] = &models.FAAInfo{
+			Registration: reg.String,
+			AircraftType: acType.String,
+			Manufacturer: mfr.String,
+			Model:        model.String,
+			Operator:     operator.String,
+			Owner:        owner.String,
+		}
+	}
+	return result, rows.Err()
+}
+
+// SaveFAAInfoBatch upserts many registry records in a single transaction.
+func (r *Repository) SaveFAAInfoBatch(records map[string]*models.FAAInfo, source string) error {
+	_, _, err := r.ImportFAAInfo(records, source)
 	return err
 }
 
+// ImportFAAInfo upserts many registry records from source in a single
+// transaction, clearing any recorded lookup misses for them, and returns
+// how many rows were inserted and how many updated.
+func (r *Repository) ImportFAAInfo(records map[string]*models.FAAInfo, source string) (inserted, updated int, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// xmax is zero only for a freshly inserted row version.
+	stmt, err := tx.Prepare(`
+		INSERT INTO faa_registry (icao, registration, aircraft_type, manufacturer, model, operator, owner, source, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (icao) DO UPDATE SET
+			registration = $2,
+			aircraft_type = $3,
+			manufacturer = $4,
+			model = $5,
+			operator = $6,
+			owner = $7,
+			source = $8,
+			updated_at = NOW()
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	for icao, info := range records {
+		var isNew bool
+		err := stmt.QueryRow(icao, info.Registration, info.AircraftType, info.Manufacturer, info.Model, info.Operator, info.Owner, source).Scan(&isNew)
+		if err != nil {
+			return 0, 0, err
+		}
+		if isNew {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+
+	icaos := make([]string, 0, len(records))
+	for icao := range records {
+		icaos = append(icaos, icao)
+	}
+	if _, err := tx.Exec(`DELETE FROM faa_lookup_misses WHERE icao = ANY($1)`, pq.Array(icaos)); err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return inserted, updated, nil
+}
+
 type HourlyStats struct {
 	Hour  time.Time `json:"hour"`
 	Count int       `json:"count"`
@@ -325,18 +530,24 @@ func (r *Repository) GetDailyStats(days int) ([]DailyStats, error) {
 }
 
 func (r *Repository) GetTopAircraftTypes(limit int) ([]AircraftTypeStats, error) {
+	return r.GetTopAircraftTypesSince(time.Now().Add(-24*time.Hour), limit)
+}
+
+// GetTopAircraftTypesSince ranks aircraft types by the number of distinct
+// aircraft of each type with positions recorded since the given time.
+func (r *Repository) GetTopAircraftTypesSince(since time.Time, limit int) ([]AircraftTypeStats, error) {
 	query := `
 		SELECT f.aircraft_type, COUNT(DISTINCT p.icao) as count
 		FROM position_history p
 		JOIN faa_registry f ON p.icao = f.icao
 		WHERE f.aircraft_type IS NOT NULL AND f.aircraft_type != ''
-		AND p.timestamp > NOW() - INTERVAL '24 hours'
+		AND p.timestamp > $1
 		GROUP BY f.aircraft_type
 		ORDER BY count DESC
-		LIMIT $1
+		LIMIT $2
 	`
 
-	rows, err := r.db.Query(query, limit)
+	rows, err := r.db.Query(query, since, limit)
 	if err != nil {
 		return []AircraftTypeStats{}, err
 	}
@@ -382,6 +593,17 @@ func (r *Repository) GetTopOperators(limit int) ([]OperatorStats, error) {
 	return stats, rows.Err()
 }
 
+// GetActivitySince counts the distinct aircraft and positions recorded
+// since the given time.
+func (r *Repository) GetActivitySince(since time.Time) (aircraft, positions int, err error) {
+	err = r.db.QueryRow(`
+		SELECT COUNT(DISTINCT icao), COUNT(*)
+		FROM position_history
+		WHERE timestamp > $1
+	`, since).Scan(&aircraft, &positions)
+	return aircraft, positions, err
+}
+
 func (r *Repository) GetOverallStats() (*OverallStats, error) {
 	stats := &OverallStats{}
 
@@ -428,6 +650,32 @@ func (r *Repository) GetRecentAircraft(limit int) ([]models.Aircraft, error) {
 	if err != nil {
 		return []models.Aircraft{}, err
 	}
+	return scanAircraft(rows)
+}
+
+// GetAircraftByRegistration returns the previously seen aircraft whose
+// registry record has the given registration, most recently seen first.
+func (r *Repository) GetAircraftByRegistration(registration string) ([]models.Aircraft, error) {
+	query := `
+		SELECT a.icao, a.callsign, a.lat, a.lon, a.altitude_ft, a.speed_kt, a.heading,
+		       a.squawk, a.on_ground, a.last_seen,
+		       f.registration, f.aircraft_type, f.operator
+		FROM faa_registry f
+		JOIN aircraft a ON a.icao = f.icao
+		WHERE f.registration = $1
+		ORDER BY a.last_seen DESC
+	`
+
+	rows, err := r.db.Query(query, strings.ToUpper(strings.TrimSpace(registration)))
+	if err != nil {
+		return []models.Aircraft{}, err
+	}
+	return scanAircraft(rows)
+}
+
+// scanAircraft reads and closes rows of the aircraft columns joined with
+// their registry details.
+func scanAircraft(rows *sql.Rows) ([]models.Aircraft, error) {
 	defer rows.Close()
 
 	aircraft := []models.Aircraft{}
@@ -551,30 +799,38 @@ func (r *Repository) LoadSessionStats() (*SessionStats, error) {
 }
 
 type RangeBucketStats struct {
+	Band         string  `json:"altitude_band"`
 	Bearing      int     `json:"bearing"`
 	MaxRangeNM   float64 `json:"max_range_nm"`
 	MaxRangeICAO string  `json:"max_range_icao"`
 	ContactCount int64   `json:"contact_count"`
 }
 
-func (r *Repository) SaveRangeStats(bucket int, maxNM float64, icao string, count int64) error {
+func (r *Repository) SaveRangeStats(band string, bucketCount, bucket int, maxNM float64, icao string, count int64) error {
 	query := `
-		INSERT INTO range_stats (bearing_bucket, max_range_nm, max_range_icao, contact_count, updated_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		ON CONFLICT (bearing_bucket) DO UPDATE SET
-			max_range_nm = GREATEST(range_stats.max_range_nm, $2),
-			max_range_icao = CASE WHEN $2 > range_stats.max_range_nm THEN $3 ELSE range_stats.max_range_icao END,
-			contact_count = $4,
+		INSERT INTO range_stats (altitude_band, bucket_count, bearing_bucket, max_range_nm, max_range_icao, contact_count, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (altitude_band, bucket_count, bearing_bucket) DO UPDATE SET
+			max_range_nm = GREATEST(range_stats.max_range_nm, $4),
+			max_range_icao = CASE WHEN $4 > range_stats.max_range_nm THEN $5 ELSE range_stats.max_range_icao END,
+			contact_count = $6,
 			updated_at = NOW()
 	`
-	_, err := r.db.Exec(query, bucket, maxNM, icao, count)
+	_, err := r.db.Exec(query, band, bucketCount, bucket, maxNM, icao, count)
+	return err
+}
+
+// ResetRangeStats clears the stored range statistics.
+func (r *Repository) ResetRangeStats() error {
+	_, err := r.db.Exec(`TRUNCATE range_stats`)
 	return err
 }
 
-func (r *Repository) LoadRangeStats() ([]RangeBucketStats, error) {
-	query := `SELECT bearing_bucket, max_range_nm, COALESCE(max_range_icao, ''), contact_count FROM range_stats ORDER BY bearing_bucket`
+func (r *Repository) LoadRangeStats(bucketCount int) ([]RangeBucketStats, error) {
+	query := `SELECT altitude_band, bearing_bucket, max_range_nm, COALESCE(max_range_icao, ''), contact_count
+		FROM range_stats WHERE bucket_count = $1 ORDER BY altitude_band, bearing_bucket`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, bucketCount)
 	if err != nil {
 		return []RangeBucketStats{}, err
 	}
@@ -583,7 +839,7 @@ func (r *Repository) LoadRangeStats() ([]RangeBucketStats, error) {
 	stats := []RangeBucketStats{}
 	for rows.Next() {
 		var s RangeBucketStats
-		if err := rows.Scan(&s.Bearing, &s.MaxRangeNM, &s.MaxRangeICAO, &s.ContactCount); err != nil {
+		if err := rows.Scan(&s.Band, &s.Bearing, &s.MaxRangeNM, &s.MaxRangeICAO, &s.ContactCount); err != nil {
 			return []RangeBucketStats{}, err
 		}
 		stats = append(stats, s)
@@ -591,6 +847,51 @@ func (r *Repository) LoadRangeStats() ([]RangeBucketStats, error) {
 	return stats, rows.Err()
 }
 
+// RangeStatsSince computes range rings from position_history recorded since
+// the given time, measuring each position from the receiver. Buckets with
+// no positions are omitted.
+func (r *Repository) RangeStatsSince(since time.Time, rxLat, rxLon float64, bucketCount int) ([]RangeBucketStats, error) {
+	query := `
+		WITH measured AS (
+			SELECT icao,
+				3440.065 * 2 * ASIN(SQRT(
+					POWER(SIN(RADIANS(lat - $2) / 2), 2) +
+					COS(RADIANS($2)) * COS(RADIANS(lat)) * POWER(SIN(RADIANS(lon - $3) / 2), 2)
+				)) AS distance_nm,
+				MOD(CAST(DEGREES(ATAN2(
+					SIN(RADIANS(lon - $3)) * COS(RADIANS(lat)),
+					COS(RADIANS($2)) * SIN(RADIANS(lat)) - SIN(RADIANS($2)) * COS(RADIANS(lat)) * COS(RADIANS(lon - $3))
+				)) + 360 AS NUMERIC), 360) AS bearing
+			FROM position_history
+			WHERE timestamp >= $1
+		), bucketed AS (
+			SELECT icao, distance_nm,
+				LEAST(FLOOR(bearing / (360.0 / $4::INTEGER))::INTEGER, $4::INTEGER - 1) AS bucket
+			FROM measured
+		)
+		SELECT DISTINCT ON (bucket) bucket, distance_nm, icao,
+			COUNT(*) OVER (PARTITION BY bucket)
+		FROM bucketed
+		ORDER BY bucket, distance_nm DESC
+	`
+
+	rows, err := r.db.Query(query, since, rxLat, rxLon, bucketCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []RangeBucketStats{}
+	for rows.Next() {
+		var s RangeBucketStats
+		if err := rows.Scan(&s.Bearing, &s.MaxRangeNM, &s.MaxRangeICAO, &s.ContactCount); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
 type FlightRecord struct {
 	ID           int64     `json:"id"`
 	ICAO         string    `json:"icao"`
@@ -606,6 +907,18 @@ type FlightRecord struct {
 	MaxAltFt     *int      `json:"max_alt_ft,omitempty"`
 	TotalDistNM  float64   `json:"total_dist_nm"`
 	Completed    bool      `json:"completed"`
+
+	DurationSeconds int64    `json:"duration_s"`
+	AvgSpeedKt      *float64 `json:"avg_speed_kt,omitempty"`
+	// AltitudeProfile is only loaded for a single flight.
+	AltitudeProfile []ProfilePoint `json:"altitude_profile,omitempty"`
+}
+
+// ProfilePoint is one sample of a flight's vertical profile, T seconds after
+// the flight was first seen.
+type ProfilePoint struct {
+	T     int64 `json:"t"`
+	AltFt int   `json:"alt_ft"`
 }
 
 func (r *Repository) CreateFlight(flight *FlightRecord) (int64, error) {
@@ -624,6 +937,8 @@ func (r *Repository) CreateFlight(flight *FlightRecord) (int64, error) {
 	return id, err
 }
 
+// UpdateFlight saves a flight's progress. Progress that arrives after the
+// flight was completed is ignored, so a late flush can't reopen it.
 func (r *Repository) UpdateFlight(flight *FlightRecord) error {
 	query := `
 		UPDATE flights SET
@@ -633,22 +948,69 @@ func (r *Repository) UpdateFlight(flight *FlightRecord) error {
 			last_lon = COALESCE($5, last_lon),
 			max_alt_ft = GREATEST(COALESCE(max_alt_ft, 0), COALESCE($6, 0)),
 			total_dist_nm = $7,
-			completed = $8
-		WHERE id = $1
+			completed = $8,
+			duration_s = $9,
+			avg_speed_kt = $10,
+			altitude_profile = COALESCE($11::JSONB, altitude_profile),
+			first_lat = COALESCE(first_lat, $12),
+			first_lon = COALESCE(first_lon, $13)
+		WHERE id = $1 AND (NOT completed OR $8)
 	`
+	var profile []byte
+	if len(flight.AltitudeProfile) > 0 {
+		var err error
+		if profile, err = json.Marshal(flight.AltitudeProfile); err != nil {
+			return err
+		}
+	}
 	_, err := r.db.Exec(query,
 		flight.ID, flight.Callsign, flight.LastSeen,
 		flight.LastLat, flight.LastLon,
 		flight.MaxAltFt, flight.TotalDistNM, flight.Completed,
+		flight.DurationSeconds, flight.AvgSpeedKt, nullableJSON(profile),
+		flight.FirstLat, flight.FirstLon,
 	)
 	return err
 }
 
+// CompleteOpenFlights marks every flight still flagged as in progress as
+// completed and returns how many were closed.
+func (r *Repository) CompleteOpenFlights() (int64, error) {
+	res, err := r.db.Exec(`UPDATE flights SET completed = TRUE WHERE completed = FALSE`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func nullableJSON(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
+
 func (r *Repository) GetRecentFlights(limit int) ([]FlightRecord, error) {
+	flights := []FlightRecord{}
+	err := r.EachRecentFlight(limit, func(f *FlightRecord) error {
+		flights = append(flights, *f)
+		return nil
+	})
+	if err != nil {
+		return []FlightRecord{}, err
+	}
+	return flights, nil
+}
+
+// EachRecentFlight calls fn for each completed flight, newest first, as rows
+// are read so large exports never hold the whole result in memory. An error
+// from fn stops the iteration and is returned.
+func (r *Repository) EachRecentFlight(limit int, fn func(*FlightRecord) error) error {
 	query := `
 		SELECT id, icao, COALESCE(callsign, ''), COALESCE(registration, ''), COALESCE(aircraft_type, ''),
 		       first_seen, last_seen, first_lat, first_lon, last_lat, last_lon,
-		       max_alt_ft, total_dist_nm, completed
+		       max_alt_ft, total_dist_nm, completed,
+		       COALESCE(duration_s, EXTRACT(EPOCH FROM last_seen - first_seen)::INTEGER), avg_speed_kt
 		FROM flights
 		WHERE completed = true
 		ORDER BY last_seen DESC
@@ -657,21 +1019,20 @@ func (r *Repository) GetRecentFlights(limit int) ([]FlightRecord, error) {
 
 	rows, err := r.db.Query(query, limit)
 	if err != nil {
-		return []FlightRecord{}, err
+		return err
 	}
 	defer rows.Close()
 
-	flights := []FlightRecord{}
 	for rows.Next() {
 		var f FlightRecord
-		var firstLat, firstLon, lastLat, lastLon sql.NullFloat64
+		var firstLat, firstLon, lastLat, lastLon, avgSpeed sql.NullFloat64
 		var maxAlt sql.NullInt64
 
 		err := rows.Scan(&f.ID, &f.ICAO, &f.Callsign, &f.Registration, &f.AircraftType,
 			&f.FirstSeen, &f.LastSeen, &firstLat, &firstLon, &lastLat, &lastLon,
-			&maxAlt, &f.TotalDistNM, &f.Completed)
+			&maxAlt, &f.TotalDistNM, &f.Completed, &f.DurationSeconds, &avgSpeed)
 		if err != nil {
-			return []FlightRecord{}, err
+			return err
 		}
 
 		if firstLat.Valid {
@@ -690,28 +1051,36 @@ func (r *Repository) GetRecentFlights(limit int) ([]FlightRecord, error) {
 			v := int(maxAlt.Int64)
 			f.MaxAltFt = &v
 		}
+		if avgSpeed.Valid {
+			f.AvgSpeedKt = &avgSpeed.Float64
+		}
 
-		flights = append(flights, f)
+		if err := fn(&f); err != nil {
+			return err
+		}
 	}
-	return flights, rows.Err()
+	return rows.Err()
 }
 
 func (r *Repository) GetFlightByID(id int64) (*FlightRecord, error) {
 	query := `
 		SELECT id, icao, COALESCE(callsign, ''), COALESCE(registration, ''), COALESCE(aircraft_type, ''),
 		       first_seen, last_seen, first_lat, first_lon, last_lat, last_lon,
-		       max_alt_ft, total_dist_nm, completed
+		       max_alt_ft, total_dist_nm, completed,
+		       COALESCE(duration_s, EXTRACT(EPOCH FROM last_seen - first_seen)::INTEGER), avg_speed_kt,
+		       altitude_profile
 		FROM flights
 		WHERE id = $1
 	`
 
 	var f FlightRecord
-	var firstLat, firstLon, lastLat, lastLon sql.NullFloat64
+	var firstLat, firstLon, lastLat, lastLon, avgSpeed sql.NullFloat64
 	var maxAlt sql.NullInt64
+	var profile []byte
 
 	err := r.db.QueryRow(query, id).Scan(&f.ID, &f.ICAO, &f.Callsign, &f.Registration, &f.AircraftType,
 		&f.FirstSeen, &f.LastSeen, &firstLat, &firstLon, &lastLat, &lastLon,
-		&maxAlt, &f.TotalDistNM, &f.Completed)
+		&maxAlt, &f.TotalDistNM, &f.Completed, &f.DurationSeconds, &avgSpeed, &profile)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -735,6 +1104,14 @@ func (r *Repository) GetFlightByID(id int64) (*FlightRecord, error) {
 		v := int(maxAlt.Int64)
 		f.MaxAltFt = &v
 	}
+	if avgSpeed.Valid {
+		f.AvgSpeedKt = &avgSpeed.Float64
+	}
+	if len(profile) > 0 {
+		if err := json.Unmarshal(profile, &f.AltitudeProfile); err != nil {
+			return nil, err
+		}
+	}
 
 	return &f, nil
 }
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"adsb-tracker/pkg/models"
+)
+
+// benchRepository connects to the database named by SKYWATCH_TEST_DSN, a
+// libpq connection string for a scratch database, and skips when it is unset.
+func benchRepository(b *testing.B) *Repository {
+	dsn := os.Getenv("SKYWATCH_TEST_DSN")
+	if dsn == "" {
+		b.Skip("SKYWATCH_TEST_DSN not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	db := &DB{conn: conn}
+	b.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Exec(`DELETE FROM position_history WHERE icao = 'BENCH1'`) })
+	return NewRepository(db)
+}
+
+func benchAircraft() *models.Aircraft {
+	lat, lon, alt := 51.5, -0.1, 35000
+	return &models.Aircraft{ICAO: "BENCH1", Lat: &lat, Lon: &lon, AltitudeFt: &alt, LastSeen: time.Now()}
+}
+
+func BenchmarkSavePosition(b *testing.B) {
+	r := benchRepository(b)
+	ac := benchAircraft()
+
+	b.Run("prepared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := r.SavePosition(ac); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unprepared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := r.db.Exec(savePositionQuery, ac.ICAO, *ac.Lat, *ac.Lon, ac.AltitudeFt, ac.SpeedKt, ac.Heading, ac.LastSeen)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
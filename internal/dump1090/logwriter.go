@@ -0,0 +1,53 @@
+package dump1090
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// lineLogger is an io.Writer that forwards each complete line written by
+// dump1090 to slog at a fixed level.
+type lineLogger struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	level  slog.Level
+	stream string
+	buf    bytes.Buffer
+}
+
+func newLineLogger(level slog.Level, stream string) *lineLogger {
+	return &lineLogger{
+		logger: logger,
+		level:  level,
+		stream: stream,
+	}
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Write(p)
+	for {
+		line, err := l.buf.ReadString('\n')
+		if err != nil {
+			// Keep the partial line for the next write.
+			l.buf.Reset()
+			l.buf.WriteString(line)
+			break
+		}
+		l.emit(line)
+	}
+	return len(p), nil
+}
+
+func (l *lineLogger) emit(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	l.logger.Log(context.Background(), l.level, line, "stream", l.stream)
+}
@@ -0,0 +1,277 @@
+package dump1090
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"adsb-tracker/internal/logging"
+)
+
+var logger = logging.Component("dump1090")
+
+const (
+	stopTimeout       = 5 * time.Second
+	restartBackoffMin = time.Second
+	restartBackoffMax = time.Minute
+	stableRunTime     = time.Minute
+)
+
+// Gains lists the tuner gain steps (dB) supported by RTL-SDR R820T dongles.
+var Gains = []float64{
+	0.0, 0.9, 1.4, 2.7, 3.7, 7.7, 8.7, 12.5, 14.4, 15.7, 16.6, 19.7, 20.7, 22.9,
+	25.4, 28.0, 29.7, 32.8, 33.8, 36.4, 37.2, 38.6, 40.2, 42.1, 43.4, 43.9, 44.5,
+	48.0, 49.6,
+}
+
+type Options struct {
+	DeviceIndex int
+	Port        int
+	FeedFormat  string
+	// Gain is the tuner gain in dB. When nil, --gain is not passed and
+	// dump1090 uses its own default gain.
+	Gain *float64
+	// Verbose logs dump1090 stdout at info level instead of debug.
+	Verbose bool
+}
+
+// StatusHandler is notified whenever dump1090 starts or stops running.
+type StatusHandler func(running bool, reason string)
+
+// exitStatus belongs to a single start of dump1090. err is written before
+// done is closed, so it can be read without p.mu once done is closed.
+type exitStatus struct {
+	done chan struct{}
+	err  error
+}
+
+type Process struct {
+	mu        sync.Mutex
+	opts      Options
+	cmd       *exec.Cmd
+	exited    *exitStatus
+	startedAt time.Time
+	restarts  int
+	onStatus  StatusHandler
+}
+
+func New(opts Options) *Process {
+	return &Process{opts: opts}
+}
+
+func (p *Process) SetStatusHandler(fn StatusHandler) {
+	p.mu.Lock()
+	p.onStatus = fn
+	p.mu.Unlock()
+}
+
+func (p *Process) notify(running bool, reason string) {
+	p.mu.Lock()
+	fn := p.onStatus
+	p.mu.Unlock()
+	if fn != nil {
+		fn(running, reason)
+	}
+}
+
+func (p *Process) args() []string {
+	args := []string{"--device-index", strconv.Itoa(p.opts.DeviceIndex)}
+	if p.opts.Gain != nil {
+		args = append(args, "--gain", p.gainText())
+	}
+	args = append(args, "--net", "--quiet")
+
+	if p.opts.FeedFormat == "beast" {
+		args = append(args, "--net-bo-port", strconv.Itoa(p.opts.Port))
+	} else {
+		args = append(args, "--net-sbs-port", strconv.Itoa(p.opts.Port))
+	}
+	return args
+}
+
+func (p *Process) gainText() string {
+	if p.opts.Gain == nil {
+		return "default"
+	}
+	return strconv.FormatFloat(*p.opts.Gain, 'f', 1, 64)
+}
+
+func (p *Process) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.startLocked()
+}
+
+func (p *Process) startLocked() error {
+	cmd := exec.Command("dump1090", p.args()...)
+	stdoutLevel := slog.LevelDebug
+	if p.opts.Verbose {
+		stdoutLevel = slog.LevelInfo
+	}
+	cmd.Stdout = newLineLogger(stdoutLevel, "stdout")
+	cmd.Stderr = newLineLogger(slog.LevelWarn, "stderr")
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start dump1090: %w", err)
+	}
+
+	exited := &exitStatus{done: make(chan struct{})}
+	go func() {
+		exited.err = cmd.Wait()
+		close(exited.done)
+	}()
+
+	p.cmd = cmd
+	p.exited = exited
+	p.startedAt = time.Now()
+	logger.Info("started dump1090", "pid", cmd.Process.Pid, "port", p.opts.Port,
+		"format", p.opts.FeedFormat, "gain_db", p.gainText())
+	return nil
+}
+
+func (p *Process) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+}
+
+func (p *Process) stopLocked() {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return
+	}
+
+	p.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-p.exited.done:
+	case <-time.After(stopTimeout):
+		logger.Warn("dump1090 did not exit, killing", "timeout", stopTimeout)
+		p.cmd.Process.Kill()
+		<-p.exited.done
+	}
+	p.cmd = nil
+	p.exited = nil
+}
+
+// PID returns the process ID of the running dump1090, or 0 when it is not
+// running.
+func (p *Process) PID() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil || p.cmd.Process == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+func (p *Process) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.restarts
+}
+
+// Run supervises dump1090, restarting it with capped backoff whenever it
+// exits unexpectedly. Intentional restarts (such as gain changes) are not
+// counted. Run returns when ctx is cancelled; call Stop to terminate the
+// child process.
+func (p *Process) Run(ctx context.Context) {
+	backoff := restartBackoffMin
+
+	for {
+		p.mu.Lock()
+		exited := p.exited
+		p.mu.Unlock()
+
+		if exited == nil {
+			if err := p.Start(); err != nil {
+				logger.Warn("failed to start dump1090", "error", err, "retry_in", backoff)
+				p.notify(false, err.Error())
+				if !sleepCtx(ctx, backoff) {
+					return
+				}
+				backoff = min(backoff*2, restartBackoffMax)
+				continue
+			}
+			p.notify(true, "running")
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-exited.done:
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.mu.Lock()
+		if p.exited != exited {
+			// Replaced by an intentional restart.
+			p.mu.Unlock()
+			continue
+		}
+		exitErr := exited.err
+		ranFor := time.Since(p.startedAt)
+		p.cmd = nil
+		p.exited = nil
+		p.restarts++
+		p.mu.Unlock()
+
+		if ranFor > stableRunTime {
+			backoff = restartBackoffMin
+		}
+
+		reason := "exited"
+		if exitErr != nil {
+			reason = "exited: " + exitErr.Error()
+		}
+		logger.Warn("dump1090 stopped, restarting", "reason", reason, "ran_for", ranFor.Round(time.Second), "retry_in", backoff)
+		p.notify(false, reason)
+
+		if !sleepCtx(ctx, backoff) {
+			return
+		}
+		backoff = min(backoff*2, restartBackoffMax)
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Gain returns the tuner gain dump1090 was started with, or 0 when it was
+// left to choose its own.
+func (p *Process) Gain() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.opts.Gain == nil {
+		return 0
+	}
+	return *p.opts.Gain
+}
+
+// SetGain restarts dump1090 with the given tuner gain.
+func (p *Process) SetGain(gain float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.opts.Gain != nil && gain == *p.opts.Gain && p.cmd != nil {
+		return nil
+	}
+
+	logger.Info("restarting dump1090 to change gain", "from_db", p.gainText(), "to_db", gain)
+	p.stopLocked()
+	p.opts.Gain = &gain
+	return p.startLocked()
+}
@@ -0,0 +1,98 @@
+package dump1090
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeDump1090 puts a stand-in dump1090 on PATH that runs until signalled.
+func fakeDump1090(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake dump1090 is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nexec sleep 30\n"
+	if err := os.WriteFile(filepath.Join(dir, "dump1090"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestStartStop(t *testing.T) {
+	fakeDump1090(t)
+	p := New(Options{Port: 30005, FeedFormat: "beast"})
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if p.PID() == 0 {
+		t.Fatal("expected a PID while running")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopTimeout + 2*time.Second):
+		t.Fatal("Stop did not return")
+	}
+	if p.PID() != 0 {
+		t.Fatal("expected no PID after stop")
+	}
+}
+
+func TestSetGainRestarts(t *testing.T) {
+	fakeDump1090(t)
+	p := New(Options{Port: 30005, FeedFormat: "beast"})
+	if err := p.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer p.Stop()
+	before := p.PID()
+
+	done := make(chan error, 1)
+	go func() { done <- p.SetGain(28.0) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("set gain: %v", err)
+		}
+	case <-time.After(stopTimeout + 2*time.Second):
+		t.Fatal("SetGain did not return")
+	}
+	if p.Gain() != 28.0 {
+		t.Fatalf("expected gain 28.0, got %v", p.Gain())
+	}
+	if pid := p.PID(); pid == 0 || pid == before {
+		t.Fatalf("expected a new process, got pid %d (was %d)", pid, before)
+	}
+}
+
+func TestArgsGain(t *testing.T) {
+	hasGain := func(args []string) (string, bool) {
+		for i, arg := range args {
+			if arg == "--gain" && i+1 < len(args) {
+				return args[i+1], true
+			}
+		}
+		return "", false
+	}
+
+	p := New(Options{Port: 30003, FeedFormat: "sbs"})
+	if gain, ok := hasGain(p.args()); ok {
+		t.Fatalf("expected no --gain without a configured gain, got %s", gain)
+	}
+
+	gain := 28.0
+	p = New(Options{Port: 30003, FeedFormat: "sbs", Gain: &gain})
+	if got, ok := hasGain(p.args()); !ok || got != "28.0" {
+		t.Fatalf("expected --gain 28.0, got %q (set=%v)", got, ok)
+	}
+}
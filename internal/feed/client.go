@@ -5,17 +5,23 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"adsb-tracker/internal/beast"
+	"adsb-tracker/internal/logging"
 	"adsb-tracker/internal/sbs"
 	"adsb-tracker/internal/tracker"
+	"adsb-tracker/internal/webhook"
+	"adsb-tracker/pkg/models"
 )
 
+var logger = logging.Component("feed")
+
+const feedDownGrace = 30 * time.Second
+
 type MessageTypeStats struct {
 	MSG1 uint64 `json:"msg1_id"`
 	MSG2 uint64 `json:"msg2_surface"`
@@ -42,6 +48,7 @@ type FeedStats struct {
 	PositionMessages uint64           `json:"position_messages"`
 	VelocityMessages uint64           `json:"velocity_messages"`
 	MessageTypes     MessageTypeStats `json:"message_types"`
+	Signal           *SignalStats     `json:"signal,omitempty"`
 }
 
 type Client struct {
@@ -49,44 +56,83 @@ type Client struct {
 	port       int
 	feedFormat string
 	tracker    *tracker.Tracker
-	rxLat      float64
-	rxLon      float64
-
-	mu              sync.RWMutex
-	connected       bool
-	connectionTime  time.Time
-	lastMessage     time.Time
-	messagesTotal   uint64
-	messageCount    uint64
-	messagesPerSec  float64
-	reconnects      int
+
+	mu             sync.RWMutex
+	hasRx          bool
+	rxLat          float64
+	rxLon          float64
+	connected      bool
+	connectionTime time.Time
+	lastMessage    time.Time
+	messagesTotal  uint64
+	messageCount   uint64
+	messagesPerSec float64
+	reconnects     int
 
 	validMessages    uint64
 	invalidMessages  uint64
 	positionMessages uint64
 	velocityMessages uint64
 	msgTypeCounts    [9]uint64
+	signal           *signalHistogram
+	maxTimestampAge  time.Duration
+
+	webhooks       *webhook.Dispatcher
+	disconnectedAt time.Time
+	downNotified   bool
 }
 
-func NewClient(host string, port int, feedFormat string, rxLat, rxLon float64, t *tracker.Tracker) *Client {
+func NewClient(host string, port int, feedFormat string, t *tracker.Tracker) *Client {
 	if feedFormat == "" {
 		feedFormat = "sbs"
 	}
 	return &Client{
-		host:       host,
-		port:       port,
-		feedFormat: feedFormat,
-		tracker:    t,
-		rxLat:      rxLat,
-		rxLon:      rxLon,
+		host:            host,
+		port:            port,
+		feedFormat:      feedFormat,
+		tracker:         t,
+		signal:          newSignalHistogram(),
+		maxTimestampAge: sbs.DefaultMaxTimestampAge,
 	}
 }
 
+// SetMaxTimestampAge sets how old an SBS message timestamp may be before the
+// time of receipt is used instead; 0 keeps timestamps of any age. Call it
+// before Run.
+func (c *Client) SetMaxTimestampAge(d time.Duration) {
+	c.maxTimestampAge = d
+}
+
+// SetReceiverLocation moves the CPR reference used to decode Beast positions.
+// A connected feed picks it up with its next read.
+func (c *Client) SetReceiverLocation(lat, lon float64) {
+	c.mu.Lock()
+	c.rxLat, c.rxLon, c.hasRx = lat, lon, true
+	c.mu.Unlock()
+}
+
+func (c *Client) receiverLocation() (lat, lon float64, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rxLat, c.rxLon, c.hasRx
+}
+
+func (c *Client) SetWebhooks(w *webhook.Dispatcher) {
+	c.webhooks = w
+}
+
 func (c *Client) Run(ctx context.Context) {
 	addr := fmt.Sprintf("%s:%d", c.host, c.port)
 	backoff := time.Second
 
+	c.mu.Lock()
+	c.disconnectedAt = time.Now()
+	c.mu.Unlock()
+
 	go c.calculateMessageRate(ctx)
+	if c.webhooks != nil {
+		go c.watchStatus(ctx)
+	}
 
 	for {
 		select {
@@ -97,7 +143,7 @@ func (c *Client) Run(ctx context.Context) {
 
 		if err := c.connect(ctx, addr); err != nil {
 			c.setConnected(false)
-			log.Printf("[FEED] Connection error: %v, reconnecting in %v", err, backoff)
+			logger.Warn("connection error, reconnecting", "error", err, "retry_in", backoff)
 			select {
 			case <-ctx.Done():
 				return
@@ -121,11 +167,12 @@ func (c *Client) calculateMessageRate(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case now := <-ticker.C:
 			count := atomic.SwapUint64(&c.messageCount, 0)
 			c.mu.Lock()
 			c.messagesPerSec = float64(count)
 			c.mu.Unlock()
+			c.signal.rotate(now)
 		}
 	}
 }
@@ -140,13 +187,68 @@ func (c *Client) recordMessage() {
 
 func (c *Client) setConnected(connected bool) {
 	c.mu.Lock()
-	c.connected = connected
 	if connected {
 		c.connectionTime = time.Now()
+	} else if c.connected {
+		c.disconnectedAt = time.Now()
 	}
+	c.connected = connected
 	c.mu.Unlock()
 }
 
+func (c *Client) watchStatus(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkStatus()
+		}
+	}
+}
+
+func (c *Client) checkStatus() {
+	c.mu.Lock()
+	data := &webhook.FeedStatusData{
+		Host:       c.host,
+		Port:       c.port,
+		Format:     c.feedFormat,
+		Reconnects: c.reconnects,
+	}
+
+	var sendDown, sendUp bool
+	switch {
+	case !c.connected && !c.downNotified && time.Since(c.disconnectedAt) > feedDownGrace:
+		data.Downtime = time.Since(c.disconnectedAt)
+		sendDown = true
+	case c.connected && c.downNotified:
+		data.Downtime = c.connectionTime.Sub(c.disconnectedAt)
+		c.downNotified = false
+		sendUp = true
+	}
+	c.mu.Unlock()
+
+	if sendDown && c.webhooks.SendFeedDown(data) {
+		logger.Warn("feed down, sent notification", "downtime", data.Downtime.Round(time.Second))
+		c.mu.Lock()
+		c.downNotified = true
+		c.mu.Unlock()
+	}
+	if sendUp {
+		logger.Info("feed recovered", "downtime", data.Downtime.Round(time.Second))
+		c.webhooks.SendFeedUp(data)
+	}
+}
+
+func (c *Client) MessageRate() (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.messagesPerSec, c.connected
+}
+
 func (c *Client) GetStats() FeedStats {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -175,11 +277,12 @@ func (c *Client) GetStats() FeedStats {
 			MSG7: atomic.LoadUint64(&c.msgTypeCounts[7]),
 			MSG8: atomic.LoadUint64(&c.msgTypeCounts[8]),
 		},
+		Signal: c.signal.stats(),
 	}
 }
 
 func (c *Client) connect(ctx context.Context, addr string) error {
-	log.Printf("[FEED] Connecting to %s (format: %s)", addr, c.feedFormat)
+	logger.Info("connecting", "addr", addr, "format", c.feedFormat)
 
 	dialer := net.Dialer{Timeout: 10 * time.Second}
 	conn, err := dialer.DialContext(ctx, "tcp", addr)
@@ -188,7 +291,7 @@ func (c *Client) connect(ctx context.Context, addr string) error {
 	}
 	defer conn.Close()
 
-	log.Printf("[FEED] Connected to %s", addr)
+	logger.Info("connected", "addr", addr)
 	c.setConnected(true)
 
 	done := make(chan struct{})
@@ -207,13 +310,17 @@ func (c *Client) connect(ctx context.Context, addr string) error {
 	return c.readSBS(conn)
 }
 
+func (c *Client) origin() models.Origin {
+	return models.Origin{Feed: fmt.Sprintf("%s:%d", c.host, c.port), Format: c.feedFormat}
+}
+
 func (c *Client) readSBS(conn net.Conn) error {
 	scanner := bufio.NewScanner(conn)
 	for scanner.Scan() {
 		line := scanner.Text()
 		c.recordMessage()
 
-		result := sbs.ParseMessageWithType(line)
+		result := sbs.ParseMessageWithMaxAge(line, c.maxTimestampAge)
 
 		if result.MessageType >= 1 && result.MessageType <= 8 {
 			atomic.AddUint64(&c.msgTypeCounts[result.MessageType], 1)
@@ -228,8 +335,10 @@ func (c *Client) readSBS(conn net.Conn) error {
 				atomic.AddUint64(&c.velocityMessages, 1)
 			}
 
-			if result.Aircraft != nil {
-				c.tracker.Update(result.Aircraft)
+			if result.Remove {
+				c.tracker.Expire(result.Aircraft.ICAO, result.Aircraft.LastSeen)
+			} else if result.Aircraft != nil {
+				c.tracker.UpdateFrom(result.Aircraft, c.origin())
 			}
 		} else {
 			atomic.AddUint64(&c.invalidMessages, 1)
@@ -241,7 +350,7 @@ func (c *Client) readSBS(conn net.Conn) error {
 		return fmt.Errorf("read error: %w", err)
 	}
 
-	log.Printf("[FEED] Connection closed")
+	logger.Info("connection closed")
 	c.setConnected(false)
 	return nil
 }
@@ -250,16 +359,22 @@ func (c *Client) readBeast(conn net.Conn) error {
 	buf := make([]byte, 4096)
 	data := make([]byte, 0, 8192)
 	parser := beast.NewParser()
-	if c.rxLat != 0 || c.rxLon != 0 {
-		parser.SetReceiverLocation(c.rxLat, c.rxLon)
-	}
+	origin := c.origin()
+	var refLat, refLon float64
+	var hasRef bool
+	lastCleanup := time.Now()
 
 	for {
+		if lat, lon, ok := c.receiverLocation(); ok && (!hasRef || lat != refLat || lon != refLon) {
+			parser.SetReceiverLocation(lat, lon)
+			refLat, refLon, hasRef = lat, lon, true
+		}
+
 		n, err := conn.Read(buf)
 		if err != nil {
 			c.setConnected(false)
 			if err == io.EOF {
-				log.Printf("[FEED] Connection closed")
+				logger.Info("connection closed")
 				return nil
 			}
 			return fmt.Errorf("read error: %w", err)
@@ -276,8 +391,11 @@ func (c *Client) readBeast(conn net.Conn) error {
 
 			if msg != nil {
 				c.recordMessage()
+				if msg.Type != beast.TypeModeAC {
+					c.signal.record(msg.RSSI)
+				}
 				if ac := parser.Decode(msg); ac != nil {
-					c.tracker.Update(ac)
+					c.tracker.UpdateFrom(ac, origin)
 				}
 			}
 		}
@@ -285,5 +403,10 @@ func (c *Client) readBeast(conn net.Conn) error {
 		if len(data) > 16384 {
 			data = data[len(data)-8192:]
 		}
+
+		if time.Since(lastCleanup) > time.Minute {
+			parser.Cleanup()
+			lastCleanup = time.Now()
+		}
 	}
 }
@@ -0,0 +1,139 @@
+package feed
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	signalMinDBFS    = -50.0
+	signalBucketDB   = 5.0
+	signalBuckets    = 7
+	signalWindowSize = time.Minute
+)
+
+type SignalBucket struct {
+	LowDBFS        float64 `json:"low_dbfs"`
+	HighDBFS       float64 `json:"high_dbfs"`
+	Messages       uint64  `json:"messages"`
+	MessagesPerSec float64 `json:"messages_per_sec"`
+}
+
+// SignalStats summarises message RSSI over the last complete window.
+type SignalStats struct {
+	Window         string         `json:"window"`
+	Messages       uint64         `json:"messages"`
+	MinDBFS        float64        `json:"min_dbfs"`
+	MaxDBFS        float64        `json:"max_dbfs"`
+	MeanDBFS       float64        `json:"mean_dbfs"`
+	NoiseFloorDBFS float64        `json:"noise_floor_dbfs"`
+	Buckets        []SignalBucket `json:"buckets"`
+}
+
+type signalWindow struct {
+	counts [signalBuckets]uint64
+	total  uint64
+	sum    float64
+	min    float64
+	max    float64
+}
+
+// signalHistogram keeps bucketed RSSI counters for the current window and
+// a snapshot of the previous one, so memory stays constant regardless of
+// message rate.
+type signalHistogram struct {
+	mu      sync.Mutex
+	current signalWindow
+	last    *SignalStats
+	started time.Time
+}
+
+func newSignalHistogram() *signalHistogram {
+	return &signalHistogram{started: time.Now()}
+}
+
+func (h *signalHistogram) record(rssi float64) {
+	idx := int((rssi - signalMinDBFS) / signalBucketDB)
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= signalBuckets {
+		idx = signalBuckets - 1
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w := &h.current
+	if w.total == 0 || rssi < w.min {
+		w.min = rssi
+	}
+	if w.total == 0 || rssi > w.max {
+		w.max = rssi
+	}
+	w.counts[idx]++
+	w.total++
+	w.sum += rssi
+}
+
+// rotate closes the current window if it has run its full length.
+func (h *signalHistogram) rotate(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	elapsed := now.Sub(h.started)
+	if elapsed < signalWindowSize {
+		return
+	}
+
+	w := h.current
+	h.current = signalWindow{}
+	h.started = now
+
+	if w.total == 0 {
+		h.last = nil
+		return
+	}
+
+	stats := &SignalStats{
+		Window:   elapsed.Round(time.Second).String(),
+		Messages: w.total,
+		MinDBFS:  round1(w.min),
+		MaxDBFS:  round1(w.max),
+		MeanDBFS: round1(w.sum / float64(w.total)),
+		Buckets:  make([]SignalBucket, signalBuckets),
+	}
+
+	// The noise floor is approximated by the 10th percentile: most weak
+	// messages are decoded just above it.
+	threshold := w.total / 10
+	var cumulative uint64
+	floorSet := false
+	for i, count := range w.counts {
+		low := signalMinDBFS + float64(i)*signalBucketDB
+		stats.Buckets[i] = SignalBucket{
+			LowDBFS:        low,
+			HighDBFS:       low + signalBucketDB,
+			Messages:       count,
+			MessagesPerSec: round1(float64(count) / elapsed.Seconds()),
+		}
+		cumulative += count
+		if !floorSet && cumulative > threshold {
+			stats.NoiseFloorDBFS = low
+			floorSet = true
+		}
+	}
+
+	h.last = stats
+}
+
+func (h *signalHistogram) stats() *SignalStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignalHistogramBuckets(t *testing.T) {
+	cases := []struct {
+		rssi   float64
+		bucket int
+	}{
+		{-60, 0},
+		{-50, 0},
+		{-45.1, 0},
+		{-45, 1},
+		{-32.5, 3},
+		{-20.1, 5},
+		{-20, 6},
+		{-15, 6},
+		{0, 6},
+	}
+	for _, tc := range cases {
+		h := newSignalHistogram()
+		h.record(tc.rssi)
+		for i, count := range h.current.counts {
+			want := uint64(0)
+			if i == tc.bucket {
+				want = 1
+			}
+			if count != want {
+				t.Fatalf("%v dBFS: expected bucket %d, got counts %v", tc.rssi, tc.bucket, h.current.counts)
+			}
+		}
+	}
+}
+
+func TestSignalHistogramRotate(t *testing.T) {
+	cases := []struct {
+		name     string
+		rssi     []float64
+		elapsed  time.Duration
+		nilStats bool
+		want     SignalStats
+		perSec   []float64
+	}{
+		{
+			name:     "window still open",
+			rssi:     []float64{-30},
+			elapsed:  30 * time.Second,
+			nilStats: true,
+		},
+		{
+			name:     "empty window",
+			elapsed:  time.Minute,
+			nilStats: true,
+		},
+		{
+			name:    "single message",
+			rssi:    []float64{-25},
+			elapsed: time.Minute,
+			want: SignalStats{
+				Window: "1m0s", Messages: 1,
+				MinDBFS: -25, MaxDBFS: -25, MeanDBFS: -25, NoiseFloorDBFS: -25,
+			},
+			perSec: []float64{0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:    "noise floor at tenth percentile",
+			rssi:    []float64{-48, -32, -32, -32, -32, -32, -32, -32, -32, -32},
+			elapsed: time.Minute,
+			want: SignalStats{
+				Window: "1m0s", Messages: 10,
+				MinDBFS: -48, MaxDBFS: -32, MeanDBFS: -33.6, NoiseFloorDBFS: -35,
+			},
+			perSec: []float64{0, 0, 0, 0.2, 0, 0, 0},
+		},
+		{
+			name:    "late window",
+			rssi:    []float64{-10, -49.96, -30.04},
+			elapsed: 2 * time.Minute,
+			want: SignalStats{
+				Window: "2m0s", Messages: 3,
+				MinDBFS: -50, MaxDBFS: -10, MeanDBFS: -30, NoiseFloorDBFS: -50,
+			},
+			perSec: []float64{0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+	for _, tc := range cases {
+		h := newSignalHistogram()
+		for _, rssi := range tc.rssi {
+			h.record(rssi)
+		}
+		h.rotate(h.started.Add(tc.elapsed))
+
+		got := h.stats()
+		if tc.nilStats {
+			if got != nil {
+				t.Fatalf("%s: expected no stats, got %+v", tc.name, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Fatalf("%s: expected stats", tc.name)
+		}
+		if got.Window != tc.want.Window || got.Messages != tc.want.Messages ||
+			got.MinDBFS != tc.want.MinDBFS || got.MaxDBFS != tc.want.MaxDBFS ||
+			got.MeanDBFS != tc.want.MeanDBFS || got.NoiseFloorDBFS != tc.want.NoiseFloorDBFS {
+			t.Fatalf("%s: expected %+v, got %+v", tc.name, tc.want, *got)
+		}
+		if len(got.Buckets) != signalBuckets {
+			t.Fatalf("%s: expected %d buckets, got %d", tc.name, signalBuckets, len(got.Buckets))
+		}
+		var total uint64
+		for i, b := range got.Buckets {
+			low := signalMinDBFS + float64(i)*signalBucketDB
+			if b.LowDBFS != low || b.HighDBFS != low+signalBucketDB {
+				t.Fatalf("%s: bucket %d: expected %v to %v, got %v to %v", tc.name, i, low, low+signalBucketDB, b.LowDBFS, b.HighDBFS)
+			}
+			if b.MessagesPerSec != tc.perSec[i] {
+				t.Fatalf("%s: bucket %d: expected %v msg/s, got %v", tc.name, i, tc.perSec[i], b.MessagesPerSec)
+			}
+			total += b.Messages
+		}
+		if total != got.Messages {
+			t.Fatalf("%s: bucket counts sum to %d, expected %d", tc.name, total, got.Messages)
+		}
+	}
+}
+
+func TestSignalHistogramStartsNewWindow(t *testing.T) {
+	h := newSignalHistogram()
+	h.record(-30)
+	h.rotate(h.started.Add(time.Minute))
+	first := h.stats()
+
+	h.record(-40)
+	h.rotate(h.started.Add(time.Minute))
+	second := h.stats()
+
+	if first == second || second.Messages != 1 || second.MaxDBFS != -40 {
+		t.Fatalf("expected a fresh window, got %+v", second)
+	}
+
+	h.rotate(h.started.Add(time.Minute))
+	if h.stats() != nil {
+		t.Fatal("expected an idle window to clear the stats")
+	}
+}
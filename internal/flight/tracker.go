@@ -1,45 +1,255 @@
 package flight
 
 import (
+	"context"
 	"math"
 	"sync"
 	"time"
 
 	"adsb-tracker/internal/database"
+	"adsb-tracker/internal/logging"
 	"adsb-tracker/pkg/models"
 )
 
+var logger = logging.Component("flight")
+
 type ActiveFlight struct {
-	ID          int64
-	ICAO        string
-	Callsign    string
-	Registration string
-	AircraftType string
-	FirstSeen   time.Time
-	LastSeen    time.Time
-	FirstLat    *float64
-	FirstLon    *float64
-	LastLat     *float64
-	LastLon     *float64
-	MaxAltFt    int
-	TotalDistNM float64
-	PrevLat     *float64
-	PrevLon     *float64
+	ID           int64     `json:"id,omitempty"`
+	ICAO         string    `json:"icao"`
+	Callsign     string    `json:"callsign,omitempty"`
+	Registration string    `json:"registration,omitempty"`
+	AircraftType string    `json:"aircraft_type,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	FirstLat     *float64  `json:"first_lat,omitempty"`
+	FirstLon     *float64  `json:"first_lon,omitempty"`
+	LastLat      *float64  `json:"last_lat,omitempty"`
+	LastLon      *float64  `json:"last_lon,omitempty"`
+	MaxAltFt     int       `json:"max_alt_ft"`
+	TotalDistNM  float64   `json:"total_dist_nm"`
+	PrevLat      *float64  `json:"-"`
+	PrevLon      *float64  `json:"-"`
+	// LastPositionAt and OnGround drive the decision to split a flight.
+	LastPositionAt time.Time `json:"last_position_at"`
+	OnGround       bool      `json:"on_ground"`
+	// Profile samples altitude over the flight for a vertical profile.
+	Profile []database.ProfilePoint `json:"-"`
+
+	// flushedAt is the LastSeen written by the last periodic flush.
+	flushedAt    time.Time
+	profileStep  time.Duration
+	lastSampleAt time.Time
+}
+
+const (
+	profileInterval  = 30 * time.Second
+	profileMaxPoints = 240
+)
+
+// minMoveNM is the smallest change in position counted as movement.
+// Snapshots repeat the last known position on every update, so anything
+// closer is treated as the same fix.
+const minMoveNM = 0.01
+
+// movedTo reports whether a position differs from the last one recorded.
+func (f *ActiveFlight) movedTo(lat, lon float64) bool {
+	if f.PrevLat == nil || f.PrevLon == nil {
+		return true
+	}
+	return haversineNM(*f.PrevLat, *f.PrevLon, lat, lon) > minMoveNM
+}
+
+func (f *ActiveFlight) Duration() time.Duration {
+	return f.LastSeen.Sub(f.FirstSeen)
+}
+
+// AvgSpeedKt is the distance flown over the flight's duration. It is not
+// defined for flights without distance or duration.
+func (f *ActiveFlight) AvgSpeedKt() (float64, bool) {
+	hours := f.Duration().Hours()
+	if hours <= 0 || f.TotalDistNM <= 0 {
+		return 0, false
+	}
+	return f.TotalDistNM / hours, true
+}
+
+// sampleAltitude adds a profile point every profileStep. When the profile
+// outgrows profileMaxPoints, every other point is dropped and the step
+// doubles, so long flights keep an evenly spaced, bounded profile.
+func (f *ActiveFlight) sampleAltitude(at time.Time, altFt int) {
+	if f.profileStep == 0 {
+		f.profileStep = profileInterval
+	}
+	if len(f.Profile) > 0 && at.Sub(f.lastSampleAt) < f.profileStep {
+		return
+	}
+	f.Profile = append(f.Profile, database.ProfilePoint{
+		T:     int64(at.Sub(f.FirstSeen).Seconds()),
+		AltFt: altFt,
+	})
+	f.lastSampleAt = at
+
+	if len(f.Profile) > profileMaxPoints {
+		thinned := f.Profile[:0]
+		for i := 0; i < len(f.Profile); i += 2 {
+			thinned = append(thinned, f.Profile[i])
+		}
+		f.Profile = thinned
+		f.profileStep *= 2
+	}
+}
+
+// DefaultSplitGap is how long an aircraft may go without a position before
+// its next position starts a new flight.
+const DefaultSplitGap = 5 * time.Minute
+
+// flushInterval is how often in-progress flights are written to the
+// database, bounding how much progress a crash can lose.
+const flushInterval = 30 * time.Second
+
+type CompletionHandler func(flight ActiveFlight)
+
+// Repository is the subset of database.Repository the flight tracker uses.
+type Repository interface {
+	CreateFlight(flight *database.FlightRecord) (int64, error)
+	UpdateFlight(flight *database.FlightRecord) error
+	CompleteOpenFlights() (int64, error)
+	GetRecentFlights(limit int) ([]database.FlightRecord, error)
+	EachRecentFlight(limit int, fn func(*database.FlightRecord) error) error
+	GetFlightByID(id int64) (*database.FlightRecord, error)
 }
 
 type Tracker struct {
-	mu      sync.RWMutex
-	flights map[string]*ActiveFlight
-	repo    *database.Repository
+	mu           sync.RWMutex
+	flights      map[string]*ActiveFlight
+	repo         Repository
 	staleTimeout time.Duration
+	splitGap     time.Duration
+	onComplete   CompletionHandler
 }
 
 func New(repo *database.Repository, staleTimeout time.Duration) *Tracker {
-	return &Tracker{
+	t := &Tracker{
 		flights:      make(map[string]*ActiveFlight),
-		repo:         repo,
 		staleTimeout: staleTimeout,
+		splitGap:     DefaultSplitGap,
 	}
+	// Only assign a non-nil pointer, so t.repo == nil keeps meaning "no
+	// database".
+	if repo != nil {
+		t.repo = repo
+		t.reconcile()
+	}
+	return t
+}
+
+// reconcile closes flights a previous run left incomplete. Their in-memory
+// state is gone, so the last flushed progress is the best record of them.
+func (t *Tracker) reconcile() {
+	n, err := t.repo.CompleteOpenFlights()
+	if err != nil {
+		logger.Error("failed to close flights left open by a previous run", "error", err)
+		return
+	}
+	if n > 0 {
+		logger.Info("closed flights left open by a previous run", "count", n)
+	}
+}
+
+// Run periodically writes the progress of active flights to the database
+// until ctx is cancelled. Call CompleteAll afterwards to close them.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+// flush writes every active flight that has changed since the last flush.
+func (t *Tracker) flush() {
+	if t.repo == nil {
+		return
+	}
+
+	t.mu.Lock()
+	records := make([]*database.FlightRecord, 0, len(t.flights))
+	for _, flight := range t.flights {
+		if flight.ID == 0 || !flight.LastSeen.After(flight.flushedAt) {
+			continue
+		}
+		flight.flushedAt = flight.LastSeen
+		records = append(records, flight.record(false))
+	}
+	t.mu.Unlock()
+
+	for _, record := range records {
+		if err := t.repo.UpdateFlight(record); err != nil {
+			logger.Error("failed to save flight progress", "flight_id", record.ID, "error", err)
+		}
+	}
+}
+
+func (f *ActiveFlight) record(completed bool) *database.FlightRecord {
+	var maxAlt *int
+	if f.MaxAltFt > 0 {
+		alt := f.MaxAltFt
+		maxAlt = &alt
+	}
+	record := &database.FlightRecord{
+		ID:              f.ID,
+		Callsign:        f.Callsign,
+		LastSeen:        f.LastSeen,
+		FirstLat:        f.FirstLat,
+		FirstLon:        f.FirstLon,
+		LastLat:         f.LastLat,
+		LastLon:         f.LastLon,
+		MaxAltFt:        maxAlt,
+		TotalDistNM:     f.TotalDistNM,
+		Completed:       completed,
+		DurationSeconds: int64(f.Duration().Seconds()),
+		// Copied because the flight keeps sampling after a flush takes it.
+		AltitudeProfile: append([]database.ProfilePoint(nil), f.Profile...),
+	}
+	if speed, ok := f.AvgSpeedKt(); ok {
+		record.AvgSpeedKt = &speed
+	}
+	return record
+}
+
+// SetSplitGap sets the position gap after which a flight is split in two.
+func (t *Tracker) SetSplitGap(d time.Duration) {
+	if d <= 0 {
+		d = DefaultSplitGap
+	}
+	t.mu.Lock()
+	t.splitGap = d
+	t.mu.Unlock()
+}
+
+// shouldSplit reports whether an update belongs to a new flight rather than
+// the active one: after a long gap between positions, as when the aircraft
+// left coverage and came back, or when it takes off again after landing.
+func shouldSplit(flight *ActiveFlight, ac *models.Aircraft, gap time.Duration) bool {
+	if flight.OnGround && ac.OnGround != nil && !*ac.OnGround {
+		return true
+	}
+	if ac.Lat != nil && ac.Lon != nil && !flight.LastPositionAt.IsZero() && flight.movedTo(*ac.Lat, *ac.Lon) {
+		return ac.LastSeen.Sub(flight.LastPositionAt) > gap
+	}
+	return false
+}
+
+func (t *Tracker) SetCompletionHandler(fn CompletionHandler) {
+	t.mu.Lock()
+	t.onComplete = fn
+	t.mu.Unlock()
 }
 
 func (t *Tracker) Update(ac *models.Aircraft) {
@@ -48,9 +258,14 @@ func (t *Tracker) Update(ac *models.Aircraft) {
 	}
 
 	t.mu.Lock()
-	defer t.mu.Unlock()
 
 	flight, exists := t.flights[ac.ICAO]
+	var finished *ActiveFlight
+	if exists && shouldSplit(flight, ac, t.splitGap) {
+		finished = flight
+		delete(t.flights, ac.ICAO)
+		exists = false
+	}
 	if !exists {
 		flight = &ActiveFlight{
 			ICAO:      ac.ICAO,
@@ -92,27 +307,55 @@ func (t *Tracker) Update(ac *models.Aircraft) {
 		flight.AircraftType = ac.AircraftType
 	}
 
+	if ac.OnGround != nil {
+		flight.OnGround = *ac.OnGround
+	}
+
 	if ac.AltitudeFt != nil && *ac.AltitudeFt > flight.MaxAltFt && *ac.AltitudeFt < 60000 {
 		flight.MaxAltFt = *ac.AltitudeFt
 	}
+	if ac.AltitudeFt != nil && *ac.AltitudeFt >= -1000 && *ac.AltitudeFt < 60000 {
+		flight.sampleAltitude(ac.LastSeen, *ac.AltitudeFt)
+	}
 
+	var backfill *database.FlightRecord
 	if ac.Lat != nil && ac.Lon != nil {
 		if flight.FirstLat == nil {
 			flight.FirstLat = ac.Lat
 			flight.FirstLon = ac.Lon
+			// The row was created before the first fix, e.g. from an
+			// ident-only message, so write the first position now.
+			if t.repo != nil && flight.ID > 0 && exists {
+				backfill = flight.record(false)
+			}
 		}
 
-		if flight.PrevLat != nil && flight.PrevLon != nil {
-			dist := haversineNM(*flight.PrevLat, *flight.PrevLon, *ac.Lat, *ac.Lon)
-			if dist < 50 {
-				flight.TotalDistNM += dist
+		if flight.movedTo(*ac.Lat, *ac.Lon) {
+			if flight.PrevLat != nil && flight.PrevLon != nil {
+				dist := haversineNM(*flight.PrevLat, *flight.PrevLon, *ac.Lat, *ac.Lon)
+				if dist < 50 {
+					flight.TotalDistNM += dist
+				}
 			}
+
+			flight.LastLat = ac.Lat
+			flight.LastLon = ac.Lon
+			flight.PrevLat = ac.Lat
+			flight.PrevLon = ac.Lon
+			flight.LastPositionAt = ac.LastSeen
 		}
+	}
+
+	onComplete := t.onComplete
+	t.mu.Unlock()
 
-		flight.LastLat = ac.Lat
-		flight.LastLon = ac.Lon
-		flight.PrevLat = ac.Lat
-		flight.PrevLon = ac.Lon
+	if backfill != nil {
+		if err := t.repo.UpdateFlight(backfill); err != nil {
+			logger.Error("failed to save first position of flight", "flight_id", backfill.ID, "error", err)
+		}
+	}
+	if finished != nil {
+		t.complete(finished, onComplete)
 	}
 }
 
@@ -124,34 +367,59 @@ func (t *Tracker) CompleteStaleFlight(icao string) {
 		return
 	}
 	delete(t.flights, icao)
+	onComplete := t.onComplete
 	t.mu.Unlock()
 
+	t.complete(flight, onComplete)
+}
+
+// complete persists a finished flight and hands it to the completion
+// handler. It must be called without t.mu held.
+func (t *Tracker) complete(flight *ActiveFlight, onComplete CompletionHandler) {
 	if t.repo != nil && flight.ID > 0 {
-		var maxAlt *int
-		if flight.MaxAltFt > 0 {
-			maxAlt = &flight.MaxAltFt
-		}
+		t.repo.UpdateFlight(flight.record(true))
+	}
 
-		record := &database.FlightRecord{
-			ID:          flight.ID,
-			Callsign:    flight.Callsign,
-			LastSeen:    flight.LastSeen,
-			LastLat:     flight.LastLat,
-			LastLon:     flight.LastLon,
-			MaxAltFt:    maxAlt,
-			TotalDistNM: flight.TotalDistNM,
-			Completed:   true,
-		}
-		t.repo.UpdateFlight(record)
+	if onComplete != nil {
+		onComplete(*flight)
 	}
 }
 
+// CompleteAll marks every active flight completed and persists it, for use
+// on shutdown once updates have stopped. The completion handler is not
+// called, so stopping the service doesn't send a burst of notifications.
+func (t *Tracker) CompleteAll() int {
+	t.mu.Lock()
+	flights := t.flights
+	t.flights = make(map[string]*ActiveFlight)
+	t.mu.Unlock()
+
+	for _, flight := range flights {
+		t.complete(flight, nil)
+	}
+	return len(flights)
+}
+
 func (t *Tracker) GetActiveCount() int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	return len(t.flights)
 }
 
+// GetActiveFlights returns a snapshot of the flights in progress.
+func (t *Tracker) GetActiveFlights() []ActiveFlight {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	flights := make([]ActiveFlight, 0, len(t.flights))
+	for _, f := range t.flights {
+		cpy := *f
+		cpy.Profile = append([]database.ProfilePoint(nil), f.Profile...)
+		flights = append(flights, cpy)
+	}
+	return flights
+}
+
 func (t *Tracker) GetRecentFlights(limit int) ([]database.FlightRecord, error) {
 	if t.repo == nil {
 		return []database.FlightRecord{}, nil
@@ -159,6 +427,13 @@ func (t *Tracker) GetRecentFlights(limit int) ([]database.FlightRecord, error) {
 	return t.repo.GetRecentFlights(limit)
 }
 
+func (t *Tracker) EachRecentFlight(limit int, fn func(*database.FlightRecord) error) error {
+	if t.repo == nil {
+		return nil
+	}
+	return t.repo.EachRecentFlight(limit, fn)
+}
+
 func (t *Tracker) GetFlightByID(id int64) (*database.FlightRecord, error) {
 	if t.repo == nil {
 		return nil, nil
@@ -182,4 +457,3 @@ func haversineNM(lat1, lon1, lat2, lon2 float64) float64 {
 func toRad(deg float64) float64 {
 	return deg * math.Pi / 180
 }
-
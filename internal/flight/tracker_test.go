@@ -0,0 +1,213 @@
+package flight
+
+import (
+	"testing"
+	"time"
+
+	"adsb-tracker/internal/database"
+	"adsb-tracker/pkg/models"
+)
+
+func position(at time.Time, lat float64, onGround *bool) *models.Aircraft {
+	lon := 4.0
+	return &models.Aircraft{ICAO: "484175", Lat: &lat, Lon: &lon, OnGround: onGround, LastSeen: at}
+}
+
+func TestShouldSplit(t *testing.T) {
+	now := time.Now()
+	ground, airborne := true, false
+	lat, lon := 52.0, 4.0
+
+	tests := []struct {
+		name   string
+		flight ActiveFlight
+		update *models.Aircraft
+		want   bool
+	}{
+		{
+			name:   "continuous positions",
+			flight: ActiveFlight{LastPositionAt: now.Add(-10 * time.Second)},
+			update: position(now, lat, nil),
+		},
+		{
+			name:   "long position gap",
+			flight: ActiveFlight{LastPositionAt: now.Add(-DefaultSplitGap - time.Second)},
+			update: position(now, lat, nil),
+			want:   true,
+		},
+		{
+			name: "last position repeated after a gap",
+			flight: ActiveFlight{
+				LastPositionAt: now.Add(-DefaultSplitGap - time.Second),
+				PrevLat:        &lat,
+				PrevLon:        &lon,
+			},
+			update: position(now, lat, nil),
+		},
+		{
+			name:   "gap without a new position",
+			flight: ActiveFlight{LastPositionAt: now.Add(-DefaultSplitGap - time.Second)},
+			update: &models.Aircraft{ICAO: "484175", LastSeen: now},
+		},
+		{
+			name:   "first position of the flight",
+			flight: ActiveFlight{},
+			update: position(now, lat, nil),
+		},
+		{
+			name:   "takeoff after landing",
+			flight: ActiveFlight{OnGround: true, LastPositionAt: now.Add(-time.Second)},
+			update: position(now, lat, &airborne),
+			want:   true,
+		},
+		{
+			name:   "still taxiing",
+			flight: ActiveFlight{OnGround: true, LastPositionAt: now.Add(-time.Second)},
+			update: position(now, lat, &ground),
+		},
+		{
+			name:   "landing",
+			flight: ActiveFlight{LastPositionAt: now.Add(-time.Second)},
+			update: position(now, lat, &ground),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldSplit(&tt.flight, tt.update, DefaultSplitGap); got != tt.want {
+				t.Fatalf("shouldSplit = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSplitsAfterGap(t *testing.T) {
+	trk := New(nil, time.Minute)
+	trk.SetSplitGap(time.Minute)
+
+	var completed []ActiveFlight
+	trk.SetCompletionHandler(func(f ActiveFlight) {
+		completed = append(completed, f)
+	})
+
+	start := time.Now().Add(-10 * time.Minute)
+	trk.Update(position(start, 52.0, nil))
+	trk.Update(position(start.Add(30*time.Second), 52.1, nil))
+	trk.Update(position(start.Add(5*time.Minute), 53.0, nil))
+
+	if len(completed) != 1 {
+		t.Fatalf("expected the first flight to be completed, got %d completions", len(completed))
+	}
+	first := completed[0]
+	if first.TotalDistNM < 5.9 || first.TotalDistNM > 6.1 {
+		t.Fatalf("expected about 6 NM in the first flight, got %.2f", first.TotalDistNM)
+	}
+
+	trk.mu.RLock()
+	current := trk.flights["484175"]
+	trk.mu.RUnlock()
+	if current == nil || !current.FirstSeen.Equal(start.Add(5*time.Minute)) || current.TotalDistNM != 0 {
+		t.Fatalf("expected a fresh flight starting at the gap, got %+v", current)
+	}
+}
+
+func TestAltitudeProfileStaysBounded(t *testing.T) {
+	start := time.Now()
+	f := &ActiveFlight{FirstSeen: start}
+	for i := 0; i < 4*profileMaxPoints; i++ {
+		f.sampleAltitude(start.Add(time.Duration(i)*profileInterval), i)
+	}
+
+	if len(f.Profile) > profileMaxPoints {
+		t.Fatalf("expected at most %d points, got %d", profileMaxPoints, len(f.Profile))
+	}
+	step := f.Profile[1].T - f.Profile[0].T
+	for i := 1; i < len(f.Profile); i++ {
+		if got := f.Profile[i].T - f.Profile[i-1].T; got != step {
+			t.Fatalf("uneven spacing at %d: %ds vs %ds", i, got, step)
+		}
+	}
+}
+
+func TestAvgSpeed(t *testing.T) {
+	start := time.Now()
+	f := &ActiveFlight{FirstSeen: start, LastSeen: start.Add(30 * time.Minute), TotalDistNM: 120}
+	if speed, ok := f.AvgSpeedKt(); !ok || speed != 240 {
+		t.Fatalf("expected 240 kt, got %v (ok=%v)", speed, ok)
+	}
+	if _, ok := (&ActiveFlight{FirstSeen: start, LastSeen: start}).AvgSpeedKt(); ok {
+		t.Fatal("expected no average speed for a zero-length flight")
+	}
+}
+
+func TestRepeatedPositionAddsNoDistance(t *testing.T) {
+	trk := New(nil, time.Minute)
+	start := time.Now()
+
+	trk.Update(position(start, 52.0, nil))
+	trk.Update(position(start.Add(time.Second), 52.1, nil))
+	for i := 2; i < 50; i++ {
+		// Non-positional updates carry the last known position.
+		trk.Update(position(start.Add(time.Duration(i)*time.Second), 52.1, nil))
+	}
+
+	active := trk.GetActiveFlights()
+	if len(active) != 1 {
+		t.Fatalf("expected one active flight, got %d", len(active))
+	}
+	if got := active[0].TotalDistNM; got < 5.9 || got > 6.1 {
+		t.Fatalf("expected about 6 NM, got %.3f", got)
+	}
+	if !active[0].LastPositionAt.Equal(start.Add(time.Second)) {
+		t.Fatalf("repeated positions should not refresh LastPositionAt, got %v", active[0].LastPositionAt)
+	}
+}
+
+type fakeRepo struct {
+	created []database.FlightRecord
+	updated []database.FlightRecord
+}
+
+func (r *fakeRepo) CreateFlight(f *database.FlightRecord) (int64, error) {
+	r.created = append(r.created, *f)
+	return int64(len(r.created)), nil
+}
+
+func (r *fakeRepo) UpdateFlight(f *database.FlightRecord) error {
+	r.updated = append(r.updated, *f)
+	return nil
+}
+
+func (r *fakeRepo) CompleteOpenFlights() (int64, error) { return 0, nil }
+
+func (r *fakeRepo) GetRecentFlights(int) ([]database.FlightRecord, error) { return nil, nil }
+
+func (r *fakeRepo) EachRecentFlight(int, func(*database.FlightRecord) error) error { return nil }
+
+func (r *fakeRepo) GetFlightByID(int64) (*database.FlightRecord, error) { return nil, nil }
+
+func TestFirstPositionBackfilledAfterIdent(t *testing.T) {
+	repo := &fakeRepo{}
+	trk := New(nil, time.Minute)
+	trk.repo = repo
+
+	start := time.Now()
+	trk.Update(&models.Aircraft{ICAO: "484175", Callsign: "KLM1023", LastSeen: start})
+	if len(repo.created) != 1 || repo.created[0].FirstLat != nil {
+		t.Fatalf("expected a flight row without a first position, got %+v", repo.created)
+	}
+
+	trk.Update(position(start.Add(time.Second), 52.0, nil))
+	if len(repo.updated) != 1 {
+		t.Fatalf("expected the first fix to be written immediately, got %d updates", len(repo.updated))
+	}
+	got := repo.updated[0]
+	if got.ID != 1 || got.FirstLat == nil || *got.FirstLat != 52.0 || got.FirstLon == nil || *got.FirstLon != 4.0 {
+		t.Fatalf("expected first position 52.0, 4.0 on flight 1, got %+v", got)
+	}
+
+	trk.Update(position(start.Add(2*time.Second), 52.1, nil))
+	if len(repo.updated) != 1 {
+		t.Fatalf("expected no further writes for later positions, got %d", len(repo.updated))
+	}
+}
@@ -0,0 +1,136 @@
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"adsb-tracker/internal/logging"
+)
+
+var logger = logging.Component("gpsd")
+
+const (
+	watchCommand = `?WATCH={"enable":true,"json":true}` + "\n"
+	dialTimeout  = 10 * time.Second
+	// readTimeout is generous because gpsd only reports while the receiver
+	// has something to say; a healthy GPS sends a TPV report every second.
+	readTimeout = time.Minute
+	backoffMax  = time.Minute
+)
+
+// LocationHandler receives each accepted receiver position.
+type LocationHandler func(lat, lon float64)
+
+// Client follows the position reports of a gpsd daemon so a mobile
+// receiver's location stays current.
+type Client struct {
+	addr       string
+	interval   time.Duration
+	onLocation LocationHandler
+	lastSent   time.Time
+}
+
+// New returns a client for the gpsd at addr that passes a position to fn at
+// most once per interval.
+func New(addr string, interval time.Duration, fn LocationHandler) *Client {
+	return &Client{addr: addr, interval: interval, onLocation: fn}
+}
+
+// tpv is the subset of a gpsd TPV (time-position-velocity) report Skywatch
+// needs. Mode 2 is a 2D fix and mode 3 a 3D fix.
+type tpv struct {
+	Class string   `json:"class"`
+	Mode  int      `json:"mode"`
+	Lat   *float64 `json:"lat"`
+	Lon   *float64 `json:"lon"`
+}
+
+func (c *Client) Run(ctx context.Context) {
+	backoff := time.Second
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		err := c.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("gpsd connection lost, reconnecting", "addr", c.addr, "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, backoffMax)
+	}
+}
+
+func (c *Client) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("dial failed: %w", err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	defer close(done)
+
+	if _, err := io.WriteString(conn, watchCommand); err != nil {
+		return fmt.Errorf("watch failed: %w", err)
+	}
+	logger.Info("connected", "addr", c.addr)
+
+	scanner := bufio.NewScanner(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return errors.New("connection closed")
+		}
+		c.handle(scanner.Bytes(), time.Now())
+	}
+}
+
+// handle passes on the position from a TPV report with a fix, rate limited
+// to one per interval. It reports whether the position was passed on.
+func (c *Client) handle(line []byte, now time.Time) bool {
+	var report tpv
+	if err := json.Unmarshal(line, &report); err != nil {
+		return false
+	}
+	if report.Class != "TPV" || report.Mode < 2 || report.Lat == nil || report.Lon == nil {
+		return false
+	}
+	if !(*report.Lat >= -90 && *report.Lat <= 90 && *report.Lon >= -180 && *report.Lon <= 180) {
+		logger.Debug("ignoring out of range position", "lat", *report.Lat, "lon", *report.Lon)
+		return false
+	}
+	if !c.lastSent.IsZero() && now.Sub(c.lastSent) < c.interval {
+		return false
+	}
+
+	c.lastSent = now
+	logger.Debug("receiver position", "lat", *report.Lat, "lon", *report.Lon, "mode", report.Mode)
+	c.onLocation(*report.Lat, *report.Lon)
+	return true
+}
@@ -0,0 +1,78 @@
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandleAcceptsFixesAtInterval(t *testing.T) {
+	var got [][2]float64
+	c := New("", 30*time.Second, func(lat, lon float64) {
+		got = append(got, [2]float64{lat, lon})
+	})
+	start := time.Now()
+
+	if c.handle([]byte(`{"class":"SKY","satellites":[]}`), start) {
+		t.Error("expected non-TPV report to be ignored")
+	}
+	if c.handle([]byte(`{"class":"TPV","mode":1}`), start) {
+		t.Error("expected report without a fix to be ignored")
+	}
+	if !c.handle([]byte(`{"class":"TPV","mode":2,"lat":51.47,"lon":-0.45}`), start) {
+		t.Fatal("expected 2D fix to be accepted")
+	}
+	if c.handle([]byte(`{"class":"TPV","mode":3,"lat":51.48,"lon":-0.46}`), start.Add(10*time.Second)) {
+		t.Error("expected fix within the interval to be skipped")
+	}
+	if !c.handle([]byte(`{"class":"TPV","mode":3,"lat":51.49,"lon":-0.47}`), start.Add(30*time.Second)) {
+		t.Error("expected fix after the interval to be accepted")
+	}
+
+	want := [][2]float64{{51.47, -0.45}, {51.49, -0.47}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRunStreamsFromGPSD(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		cmd, _ := bufio.NewReader(conn).ReadString('\n')
+		if cmd != watchCommand {
+			t.Errorf("unexpected command %q", cmd)
+		}
+		conn.Write([]byte(`{"class":"VERSION","release":"3.25"}` + "\n"))
+		conn.Write([]byte(`{"class":"TPV","mode":3,"lat":-33.94,"lon":151.18}` + "\n"))
+		time.Sleep(time.Second)
+	}()
+
+	received := make(chan [2]float64, 1)
+	c := New(ln.Addr().String(), time.Minute, func(lat, lon float64) {
+		received <- [2]float64{lat, lon}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	select {
+	case pos := <-received:
+		if pos != [2]float64{-33.94, 151.18} {
+			t.Fatalf("unexpected position %v", pos)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a position")
+	}
+}
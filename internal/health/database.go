@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DatabaseComponent is the readiness component updated by DatabaseCheck.
+const DatabaseComponent = "database"
+
+const (
+	defaultDatabaseCheckInterval = 30 * time.Second
+	databasePingTimeout          = 5 * time.Second
+)
+
+// Pinger is satisfied by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// DatabaseStatus is the outcome of the most recent database ping.
+type DatabaseStatus struct {
+	Connected bool      `json:"connected"`
+	LatencyMS float64   `json:"latency_ms"`
+	LastCheck time.Time `json:"last_check"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DatabaseCheck pings the database periodically, keeping the database
+// readiness component current and raising a health alert when the
+// connection is lost.
+type DatabaseCheck struct {
+	db        Pinger
+	readiness *Readiness
+	monitor   *Monitor
+	interval  time.Duration
+
+	mu     sync.RWMutex
+	status DatabaseStatus
+	failed bool
+}
+
+// NewDatabaseCheck returns a check that alerts through monitor, which may be
+// nil.
+func NewDatabaseCheck(db Pinger, readiness *Readiness, monitor *Monitor, interval time.Duration) *DatabaseCheck {
+	if interval <= 0 {
+		interval = defaultDatabaseCheckInterval
+	}
+	return &DatabaseCheck{
+		db:        db,
+		readiness: readiness,
+		monitor:   monitor,
+		interval:  interval,
+	}
+}
+
+// Run checks the database immediately and then every interval until ctx is
+// cancelled.
+func (c *DatabaseCheck) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.check(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx)
+		}
+	}
+}
+
+// Status returns the result of the last check.
+func (c *DatabaseCheck) Status() DatabaseStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+func (c *DatabaseCheck) check(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, databasePingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.db.PingContext(pingCtx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	status := DatabaseStatus{
+		Connected: err == nil,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		LastCheck: start,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	c.status = status
+	wasFailed := c.failed
+	c.failed = err != nil
+	c.mu.Unlock()
+
+	if err != nil {
+		if c.readiness != nil {
+			c.readiness.MarkNotReady(DatabaseComponent, "ping failed: "+err.Error())
+		}
+		if !wasFailed {
+			logger.Error("database ping failed", "error", err)
+			if c.monitor != nil {
+				c.monitor.Alert("Database unreachable: " + err.Error())
+			}
+		}
+		return
+	}
+
+	if c.readiness != nil {
+		c.readiness.MarkReady(DatabaseComponent)
+	}
+	if wasFailed {
+		logger.Info("database connection restored")
+	}
+}
@@ -0,0 +1,42 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) PingContext(context.Context) error {
+	return p.err
+}
+
+func TestDatabaseCheckUpdatesReadiness(t *testing.T) {
+	db := &fakePinger{}
+	readiness := NewReadiness()
+	check := NewDatabaseCheck(db, readiness, nil, 0)
+
+	check.check(context.Background())
+	if !readiness.Ready() || !check.Status().Connected {
+		t.Fatalf("expected ready after a successful ping, got %+v", readiness.Snapshot())
+	}
+
+	db.err = errors.New("connection refused")
+	check.check(context.Background())
+	state := readiness.Snapshot()[DatabaseComponent]
+	if readiness.Ready() || state.Message != "ping failed: connection refused" {
+		t.Fatalf("expected not ready after a failed ping, got %+v", state)
+	}
+	if status := check.Status(); status.Connected || status.Error != "connection refused" {
+		t.Fatalf("expected failed status, got %+v", status)
+	}
+
+	db.err = nil
+	check.check(context.Background())
+	if !readiness.Ready() {
+		t.Fatalf("expected ready after recovery, got %+v", readiness.Snapshot())
+	}
+}
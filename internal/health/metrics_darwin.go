@@ -3,8 +3,10 @@
 package health
 
 import (
+	"math"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"strings"
 )
@@ -21,17 +23,32 @@ func newPlatformMetrics() metricsProvider {
 	return &darwinMetrics{pageSize: pageSize}
 }
 
+// ps reports %cpu per core, so both readings are divided by the core count
+// to match the whole-machine percentages of the other platforms.
 func (m *darwinMetrics) CPUPercent(*Monitor) float64 {
-	pid := os.Getpid()
-	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "%cpu=").Output()
+	return psCPU("-A")
+}
+
+func (m *darwinMetrics) ProcessCPUPercent(*Monitor) float64 {
+	return psCPU("-p", strconv.Itoa(os.Getpid()))
+}
+
+func psCPU(args ...string) float64 {
+	out, err := exec.Command("ps", append(args, "-o", "%cpu=")...).Output()
 	if err != nil {
 		return 0
 	}
-	val, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
-	if err != nil {
-		return 0
+	var sum float64
+	for _, line := range strings.Split(string(out), "\n") {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(line), 64); err == nil {
+			sum += val
+		}
 	}
-	return val
+	return math.Min(100, sum/float64(runtime.NumCPU()))
+}
+
+func (m *darwinMetrics) CoreCPUPercent(*Monitor) []float64 {
+	return nil
 }
 
 func (m *darwinMetrics) MemoryUsage() (float64, uint64, uint64) {
@@ -60,6 +77,10 @@ func (m *darwinMetrics) Temperature() float64 {
 	return 0
 }
 
+func (m *darwinMetrics) ThermalZones() map[string]float64 {
+	return nil
+}
+
 func runSysctlUint64(name string) (uint64, error) {
 	out, err := exec.Command("sysctl", "-n", name).Output()
 	if err != nil {
@@ -86,3 +107,15 @@ func parseVMStatValue(out, key string) uint64 {
 	}
 	return 0
 }
+
+func (m *darwinMetrics) Disk(path string) (float64, uint64, uint64) {
+	return statfsUsage(path)
+}
+
+func (m *darwinMetrics) Network(string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
+
+func (m *darwinMetrics) ProcessUsage(int) (processTimes, uint64, bool) {
+	return processTimes{}, 0, false
+}
@@ -4,7 +4,10 @@ package health
 
 import (
 	"bufio"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -16,51 +19,153 @@ func newPlatformMetrics() metricsProvider {
 }
 
 func (m *linuxMetrics) CPUPercent(mon *Monitor) float64 {
-	file, err := os.Open("/proc/stat")
-	if err != nil {
+	total, _, ok := readProcStat()
+	if !ok {
+		return 0
+	}
+	return cpuUsage(&mon.prevCPU, total)
+}
+
+func (m *linuxMetrics) ProcessCPUPercent(mon *Monitor) float64 {
+	total, _, ok := readProcStat()
+	if !ok {
+		return 0
+	}
+	ticks, ok := readPidTicks("self")
+	if !ok {
 		return 0
 	}
+	return processUsage(&mon.prevProc, processTimes{proc: ticks, total: total.total})
+}
+
+func (m *linuxMetrics) ProcessUsage(pid int) (processTimes, uint64, bool) {
+	total, _, ok := readProcStat()
+	if !ok {
+		return processTimes{}, 0, false
+	}
+	ticks, ok := readPidTicks(strconv.Itoa(pid))
+	if !ok {
+		return processTimes{}, 0, false
+	}
+	rss, _ := readPidRSS(pid)
+	return processTimes{proc: ticks, total: total.total}, rss, true
+}
+
+// readPidRSS returns the resident set size from /proc/<pid>/status in bytes.
+func readPidRSS(pid int) (uint64, bool) {
+	file, err := os.Open("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, false
+	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "cpu ") {
-			fields := strings.Fields(line)
-			if len(fields) < 5 {
-				return 0
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "VmRSS:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, false
 			}
+			return kb * 1024, true
+		}
+	}
+	return 0, false
+}
 
-			var total, idle uint64
-			for i, v := range fields[1:] {
-				val, _ := strconv.ParseUint(v, 10, 64)
-				total += val
-				if i == 3 {
-					idle = val
-				}
-			}
+// readPidTicks returns the user plus system time of a process in clock
+// ticks, the same unit as /proc/stat.
+func readPidTicks(pid string) (uint64, bool) {
+	data, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return 0, false
+	}
+	return parsePidStat(string(data))
+}
 
-			if mon.prevTotalTime == 0 {
-				mon.prevTotalTime = total
-				mon.prevIdleTime = idle
-				return 0
-			}
+// parsePidStat reads utime and stime from a /proc/<pid>/stat line. The
+// command name can contain spaces, so fields are counted from its closing
+// parenthesis.
+func parsePidStat(line string) (uint64, bool) {
+	end := strings.LastIndexByte(line, ')')
+	if end < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 13 {
+		return 0, false
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return utime + stime, true
+}
+
+// CoreCPUPercent returns the busy percentage of each core, indexed by core
+// number.
+func (m *linuxMetrics) CoreCPUPercent(mon *Monitor) []float64 {
+	_, cores, ok := readProcStat()
+	if !ok || len(cores) == 0 {
+		return nil
+	}
+	if len(mon.prevCores) != len(cores) {
+		mon.prevCores = make([]cpuTimes, len(cores))
+	}
+	percents := make([]float64, len(cores))
+	for i, c := range cores {
+		percents[i] = cpuUsage(&mon.prevCores[i], c)
+	}
+	return percents
+}
 
-			totalDelta := total - mon.prevTotalTime
-			idleDelta := idle - mon.prevIdleTime
+func readProcStat() (cpuTimes, []cpuTimes, bool) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, nil, false
+	}
+	defer file.Close()
+	return parseProcStat(file)
+}
 
-			mon.prevTotalTime = total
-			mon.prevIdleTime = idle
+// parseProcStat reads the aggregate "cpu" line and the per-core "cpuN"
+// lines of /proc/stat. Cores missing from the file, such as offline ones,
+// are left zero.
+func parseProcStat(r io.Reader) (total cpuTimes, cores []cpuTimes, ok bool) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
 
-			if totalDelta == 0 {
-				return 0
+		var times cpuTimes
+		for i, v := range fields[1:] {
+			val, _ := strconv.ParseUint(v, 10, 64)
+			times.total += val
+			if i == 3 {
+				times.idle = val
 			}
+		}
 
-			return (1 - float64(idleDelta)/float64(totalDelta)) * 100
+		if fields[0] == "cpu" {
+			total, ok = times, true
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil || n < 0 {
+			continue
 		}
+		for len(cores) <= n {
+			cores = append(cores, cpuTimes{})
+		}
+		cores[n] = times
 	}
-
-	return 0
+	return total, cores, ok
 }
 
 func (m *linuxMetrics) MemoryUsage() (percent float64, usedMB, totalMB uint64) {
@@ -101,29 +206,154 @@ func (m *linuxMetrics) MemoryUsage() (percent float64, usedMB, totalMB uint64) {
 	return percent, usedMB, totalMB
 }
 
+const thermalRoot = "/sys/class/thermal"
+
+// cpuThermalTypes are substrings of thermal zone types that identify the CPU
+// or SoC sensor, most specific first.
+var cpuThermalTypes = []string{"cpu", "soc", "x86_pkg_temp", "coretemp", "k10temp"}
+
+type thermalZone struct {
+	index int
+	kind  string
+	temp  float64
+}
+
 func (m *linuxMetrics) Temperature() float64 {
+	if temp, ok := cpuTemperature(readThermalZones(thermalRoot)); ok {
+		return temp
+	}
+
 	paths := []string{
 		"/sys/class/thermal/thermal_zone0/temp",
 		"/sys/class/hwmon/hwmon0/temp1_input",
 	}
 
 	for _, path := range paths {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			continue
+		if temp, ok := readTemp(path); ok {
+			return temp
+		}
+	}
+
+	return 0
+}
+
+// ThermalZones returns every readable thermal zone keyed by its type. Zones
+// sharing a type are told apart by their zone number.
+func (m *linuxMetrics) ThermalZones() map[string]float64 {
+	zones := readThermalZones(thermalRoot)
+	if len(zones) == 0 {
+		return nil
+	}
+	result := make(map[string]float64, len(zones))
+	for _, z := range zones {
+		name := z.kind
+		if name == "" {
+			name = "thermal_zone" + strconv.Itoa(z.index)
+		} else if _, dup := result[name]; dup {
+			name += "_" + strconv.Itoa(z.index)
 		}
+		result[name] = z.temp
+	}
+	return result
+}
 
-		temp, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+// readThermalZones lists the readable zones under root in zone order.
+func readThermalZones(root string) []thermalZone {
+	dirs, err := filepath.Glob(filepath.Join(root, "thermal_zone*"))
+	if err != nil {
+		return nil
+	}
+
+	var zones []thermalZone
+	for _, dir := range dirs {
+		index, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "thermal_zone"))
 		if err != nil {
 			continue
 		}
+		temp, ok := readTemp(filepath.Join(dir, "temp"))
+		if !ok {
+			continue
+		}
+		kind, _ := os.ReadFile(filepath.Join(dir, "type"))
+		zones = append(zones, thermalZone{index: index, kind: strings.TrimSpace(string(kind)), temp: temp})
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].index < zones[j].index })
+	return zones
+}
 
-		if temp > 1000 {
-			temp = temp / 1000
+// cpuTemperature picks the CPU or SoC zone by type.
+func cpuTemperature(zones []thermalZone) (float64, bool) {
+	for _, want := range cpuThermalTypes {
+		for _, z := range zones {
+			if strings.Contains(strings.ToLower(z.kind), want) {
+				return z.temp, true
+			}
 		}
+	}
+	return 0, false
+}
 
-		return temp
+func readTemp(path string) (float64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
 	}
 
-	return 0
+	temp, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if temp > 1000 {
+		temp = temp / 1000
+	}
+
+	return temp, true
+}
+
+func (m *linuxMetrics) Disk(path string) (float64, uint64, uint64) {
+	return statfsUsage(path)
+}
+
+func (m *linuxMetrics) Network(iface string) (uint64, uint64, bool) {
+	file, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+	return parseNetDev(file, iface)
+}
+
+// parseNetDev sums the received and transmitted byte counters in
+// /proc/net/dev for iface, or for every interface except loopback when iface
+// is empty.
+func parseNetDev(r io.Reader, iface string) (rx, tx uint64, ok bool) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if iface != "" && name != iface || iface == "" && name == "lo" {
+			continue
+		}
+
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		r, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		t, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			continue
+		}
+		rx += r
+		tx += t
+		ok = true
+	}
+	return rx, tx, ok
 }
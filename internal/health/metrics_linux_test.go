@@ -0,0 +1,121 @@
+//go:build linux
+
+package health
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	const stat = `cpu  100 0 50 800 50 0 0 0 0 0
+cpu0 60 0 30 400 10 0 0 0 0 0
+cpu2 40 0 20 400 40 0 0 0 0 0
+intr 12345 0 0
+ctxt 67890
+`
+	total, cores, ok := parseProcStat(strings.NewReader(stat))
+	if !ok {
+		t.Fatal("expected aggregate cpu line")
+	}
+	if total != (cpuTimes{idle: 800, total: 1000}) {
+		t.Errorf("unexpected aggregate %+v", total)
+	}
+	if len(cores) != 3 {
+		t.Fatalf("expected 3 core slots, got %d", len(cores))
+	}
+	if cores[0] != (cpuTimes{idle: 400, total: 500}) {
+		t.Errorf("unexpected cpu0 %+v", cores[0])
+	}
+	if cores[1] != (cpuTimes{}) {
+		t.Errorf("expected missing cpu1 to be zero, got %+v", cores[1])
+	}
+	if cores[2] != (cpuTimes{idle: 400, total: 500}) {
+		t.Errorf("unexpected cpu2 %+v", cores[2])
+	}
+}
+
+func TestCPUUsage(t *testing.T) {
+	var prev cpuTimes
+	if got := cpuUsage(&prev, cpuTimes{idle: 400, total: 500}); got != 0 {
+		t.Errorf("expected 0 on first reading, got %v", got)
+	}
+	if got := cpuUsage(&prev, cpuTimes{idle: 475, total: 600}); got != 25 {
+		t.Errorf("expected 25%%, got %v", got)
+	}
+	if got := cpuUsage(&prev, cpuTimes{idle: 10, total: 20}); got != 0 {
+		t.Errorf("expected 0 after counter reset, got %v", got)
+	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	const dev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 5000      50    0    0    0     0          0         0     5000      50    0    0    0     0       0          0
+  eth0: 1000      10    0    0    0     0          0         0      200       2    0    0    0     0       0          0
+ wlan0:  300       3    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+`
+	rx, tx, ok := parseNetDev(strings.NewReader(dev), "")
+	if !ok || rx != 1300 || tx != 300 {
+		t.Errorf("expected all but loopback to sum to 1300/300, got %d/%d ok=%v", rx, tx, ok)
+	}
+
+	rx, tx, ok = parseNetDev(strings.NewReader(dev), "eth0")
+	if !ok || rx != 1000 || tx != 200 {
+		t.Errorf("expected eth0 1000/200, got %d/%d ok=%v", rx, tx, ok)
+	}
+
+	if _, _, ok := parseNetDev(strings.NewReader(dev), "eth1"); ok {
+		t.Error("expected unknown interface to report no counters")
+	}
+}
+
+func writeZone(t *testing.T, root string, index int, kind, temp string) {
+	t.Helper()
+	dir := filepath.Join(root, "thermal_zone"+strconv.Itoa(index))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "type"), []byte(kind+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "temp"), []byte(temp+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThermalZonesPickCPUByType(t *testing.T) {
+	root := t.TempDir()
+	writeZone(t, root, 0, "pmic", "41000")
+	writeZone(t, root, 2, "acpitz", "30000")
+	writeZone(t, root, 10, "cpu-thermal", "52300")
+	writeZone(t, root, 11, "acpitz", "31000")
+
+	zones := readThermalZones(root)
+	if len(zones) != 4 || zones[0].index != 0 || zones[3].index != 11 {
+		t.Fatalf("expected zones in numeric order, got %+v", zones)
+	}
+
+	temp, ok := cpuTemperature(zones)
+	if !ok || temp != 52.3 {
+		t.Errorf("expected cpu-thermal at 52.3, got %v ok=%v", temp, ok)
+	}
+
+	if _, ok := cpuTemperature(zones[:2]); ok {
+		t.Error("expected no CPU zone without a matching type")
+	}
+}
+
+func TestParsePidStat(t *testing.T) {
+	const stat = "1234 (dump 1090) S 1 1234 1234 0 -1 4194560 500 0 0 0 150 25 0 0 20 0 4 0 100 200000 300 18446744073709551615"
+	ticks, ok := parsePidStat(stat)
+	if !ok || ticks != 175 {
+		t.Errorf("expected 175 ticks, got %d ok=%v", ticks, ok)
+	}
+	if _, ok := parsePidStat("garbage"); ok {
+		t.Error("expected malformed line to fail")
+	}
+}
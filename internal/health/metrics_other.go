@@ -1,4 +1,4 @@
-//go:build !linux && !darwin
+//go:build !linux && !darwin && !windows
 
 package health
 
@@ -14,6 +14,14 @@ func (m *fallbackMetrics) CPUPercent(*Monitor) float64 {
 	return 0
 }
 
+func (m *fallbackMetrics) ProcessCPUPercent(*Monitor) float64 {
+	return 0
+}
+
+func (m *fallbackMetrics) CoreCPUPercent(*Monitor) []float64 {
+	return nil
+}
+
 func (m *fallbackMetrics) MemoryUsage() (float64, uint64, uint64) {
 	var mem runtime.MemStats
 	runtime.ReadMemStats(&mem)
@@ -24,3 +32,19 @@ func (m *fallbackMetrics) MemoryUsage() (float64, uint64, uint64) {
 func (m *fallbackMetrics) Temperature() float64 {
 	return 0
 }
+
+func (m *fallbackMetrics) ThermalZones() map[string]float64 {
+	return nil
+}
+
+func (m *fallbackMetrics) Disk(string) (float64, uint64, uint64) {
+	return 0, 0, 0
+}
+
+func (m *fallbackMetrics) Network(string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
+
+func (m *fallbackMetrics) ProcessUsage(int) (processTimes, uint64, bool) {
+	return processTimes{}, 0, false
+}
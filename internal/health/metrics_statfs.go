@@ -0,0 +1,25 @@
+//go:build linux || darwin
+
+package health
+
+import "syscall"
+
+func statfsUsage(path string) (float64, uint64, uint64) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0
+	}
+
+	bsize := uint64(st.Bsize)
+	total := st.Blocks * bsize
+	free := st.Bfree * bsize
+	avail := st.Bavail * bsize
+	if total == 0 || free > total {
+		return 0, 0, 0
+	}
+
+	used := total - free
+	// Match df: blocks reserved for root count as neither used nor available.
+	percent := float64(used) / float64(used+avail) * 100
+	return percent, used / 1024 / 1024, total / 1024 / 1024
+}
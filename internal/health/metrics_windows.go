@@ -0,0 +1,137 @@
+//go:build windows
+
+package health
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procGetSystemTimes       = kernel32.NewProc("GetSystemTimes")
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+	procGetDiskFreeSpaceExW  = kernel32.NewProc("GetDiskFreeSpaceExW")
+)
+
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+type windowsMetrics struct{}
+
+func newPlatformMetrics() metricsProvider {
+	return &windowsMetrics{}
+}
+
+func (m *windowsMetrics) CPUPercent(mon *Monitor) float64 {
+	idle, total, ok := systemTimes()
+	if !ok {
+		return 0
+	}
+	return cpuUsage(&mon.prevCPU, cpuTimes{idle: idle, total: total})
+}
+
+func (m *windowsMetrics) ProcessCPUPercent(mon *Monitor) float64 {
+	_, total, ok := systemTimes()
+	if !ok {
+		return 0
+	}
+	proc, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(proc, &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	used := filetimeToUint64(kernel) + filetimeToUint64(user)
+	return processUsage(&mon.prevProc, processTimes{proc: used, total: total})
+}
+
+// systemTimes returns idle and total CPU time summed over every core.
+func systemTimes() (idle, total uint64, ok bool) {
+	var idleTime, kernel, user syscall.Filetime
+	ret, _, _ := procGetSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idleTime)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)),
+	)
+	if ret == 0 {
+		return 0, 0, false
+	}
+
+	// Kernel time already includes idle time.
+	return filetimeToUint64(idleTime), filetimeToUint64(kernel) + filetimeToUint64(user), true
+}
+
+func (m *windowsMetrics) CoreCPUPercent(*Monitor) []float64 {
+	return nil
+}
+
+func (m *windowsMetrics) MemoryUsage() (float64, uint64, uint64) {
+	var status memoryStatusEx
+	status.Length = uint32(unsafe.Sizeof(status))
+
+	ret, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status)))
+	if ret == 0 || status.TotalPhys == 0 {
+		return 0, 0, 0
+	}
+
+	used := status.TotalPhys - status.AvailPhys
+	percent := float64(used) / float64(status.TotalPhys) * 100
+	return percent, used / 1024 / 1024, status.TotalPhys / 1024 / 1024
+}
+
+func (m *windowsMetrics) Temperature() float64 {
+	return 0
+}
+
+func (m *windowsMetrics) ThermalZones() map[string]float64 {
+	return nil
+}
+
+func (m *windowsMetrics) Disk(path string) (float64, uint64, uint64) {
+	if path == "/" {
+		path = `C:\`
+	}
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, 0
+	}
+
+	var freeAvail, total, totalFree uint64
+	ret, _, _ := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&freeAvail)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if ret == 0 || total == 0 {
+		return 0, 0, 0
+	}
+
+	used := total - totalFree
+	percent := float64(used) / float64(total) * 100
+	return percent, used / 1024 / 1024, total / 1024 / 1024
+}
+
+func filetimeToUint64(ft syscall.Filetime) uint64 {
+	return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+}
+
+func (m *windowsMetrics) Network(string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
+
+func (m *windowsMetrics) ProcessUsage(int) (processTimes, uint64, bool) {
+	return processTimes{}, 0, false
+}
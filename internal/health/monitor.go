@@ -2,61 +2,180 @@ package health
 
 import (
 	"context"
-	"log"
+	"math"
 	"runtime"
 	"strconv"
 	"sync"
 	"time"
 
 	"adsb-tracker/internal/config"
+	"adsb-tracker/internal/logging"
 	"adsb-tracker/internal/webhook"
 )
 
+var logger = logging.Component("health")
+
 type Stats struct {
-	CPUPercent    float64       `json:"cpu_percent"`
-	MemoryPercent float64       `json:"memory_percent"`
-	MemoryUsedMB  uint64        `json:"memory_used_mb"`
-	MemoryTotalMB uint64        `json:"memory_total_mb"`
-	TempCelsius   float64       `json:"temp_celsius"`
-	Uptime        time.Duration `json:"uptime"`
-	UptimeString  string        `json:"uptime_string"`
-	GoRoutines    int           `json:"goroutines"`
-	Platform      string        `json:"platform"`
+	CPUPercent        float64            `json:"cpu_percent"`
+	ProcessCPUPercent float64            `json:"process_cpu_percent"`
+	CPUPerCore        []float64          `json:"cpu_per_core,omitempty"`
+	MemoryPercent     float64            `json:"memory_percent"`
+	MemoryUsedMB      uint64             `json:"memory_used_mb"`
+	MemoryTotalMB     uint64             `json:"memory_total_mb"`
+	TempCelsius       float64            `json:"temp_celsius"`
+	TempZones         map[string]float64 `json:"temp_zones,omitempty"`
+	DiskPercent       float64            `json:"disk_percent"`
+	DiskUsedMB        uint64             `json:"disk_used_mb"`
+	DiskTotalMB       uint64             `json:"disk_total_mb"`
+	NetRxBytesSec     float64            `json:"net_rx_bytes_per_sec"`
+	NetTxBytesSec     float64            `json:"net_tx_bytes_per_sec"`
+	Uptime            time.Duration      `json:"uptime"`
+	UptimeString      string             `json:"uptime_string"`
+	GoRoutines        int                `json:"goroutines"`
+	Platform          string             `json:"platform"`
+	Dump1090          *ProcessStats      `json:"dump1090,omitempty"`
+}
+
+// ProcessStats is the resource usage of a child process such as dump1090.
+// CPUPercent is its share of total CPU capacity, like ProcessCPUPercent.
+type ProcessStats struct {
+	PID        int     `json:"pid"`
+	CPUPercent float64 `json:"cpu_percent"`
+	RSSMB      uint64  `json:"rss_mb"`
 }
 
+// Sample is a single point in the health history ring buffer.
+type Sample struct {
+	Timestamp     time.Time `json:"timestamp"`
+	CPUPercent    float64   `json:"cpu_percent"`
+	MemoryPercent float64   `json:"memory_percent"`
+	TempCelsius   float64   `json:"temp_celsius"`
+	DiskPercent   float64   `json:"disk_percent"`
+}
+
+// historyWindow is how far back the in-memory history reaches.
+const historyWindow = time.Hour
+
+const maxHistorySamples = 3600
+
 type Monitor struct {
 	startTime  time.Time
 	mu         sync.RWMutex
 	lastStats  Stats
 	thresholds config.HealthThresholdsConfig
+	interval   time.Duration
 	dispatcher *webhook.Dispatcher
 
-	prevIdleTime  uint64
-	prevTotalTime uint64
+	history     []Sample
+	historyNext int
+	historyFull bool
+
+	prevCPU   cpuTimes
+	prevCores []cpuTimes
+	prevProc  processTimes
+	prevNet   netCounters
+
+	dump1090PID  func() int
+	prevChild    processTimes
+	prevChildPID int
+
+	rateSource     RateSource
+	lowRateSince   time.Time
+	lowRateAlerted bool
+}
+
+// RateSource reports the feed's message rate and whether it is connected.
+type RateSource interface {
+	MessageRate() (float64, bool)
+}
+
+// netCounters is a cumulative byte count for the monitored interfaces.
+type netCounters struct {
+	rx, tx uint64
+	at     time.Time
 }
 
 type metricsProvider interface {
+	// CPUPercent is whole-system usage and ProcessCPUPercent is this
+	// process's share of the same capacity, both 0-100 on every platform.
 	CPUPercent(*Monitor) float64
+	ProcessCPUPercent(*Monitor) float64
+	CoreCPUPercent(*Monitor) []float64
 	MemoryUsage() (float64, uint64, uint64)
 	Temperature() float64
+	ThermalZones() map[string]float64
+	Disk(path string) (float64, uint64, uint64)
+	Network(iface string) (rx, tx uint64, ok bool)
+	ProcessUsage(pid int) (times processTimes, rssBytes uint64, ok bool)
 }
 
 var provider metricsProvider = newPlatformMetrics()
 
+// cpuTimes is a cumulative CPU time reading in platform units.
+type cpuTimes struct {
+	idle, total uint64
+}
+
+// cpuUsage returns the busy percentage between the reading in prev and cur,
+// then stores cur in prev. The first reading, or one after a counter reset,
+// returns 0.
+func cpuUsage(prev *cpuTimes, cur cpuTimes) float64 {
+	last := *prev
+	*prev = cur
+	if last.total == 0 || cur.total < last.total || cur.idle < last.idle {
+		return 0
+	}
+
+	totalDelta := cur.total - last.total
+	if totalDelta == 0 {
+		return 0
+	}
+	return (1 - float64(cur.idle-last.idle)/float64(totalDelta)) * 100
+}
+
+// processTimes is a cumulative reading of one process's CPU time alongside
+// the whole system's, in the same platform units.
+type processTimes struct {
+	proc, total uint64
+}
+
+// processUsage returns the process's share of total CPU capacity between the
+// reading in prev and cur, then stores cur in prev.
+func processUsage(prev *processTimes, cur processTimes) float64 {
+	last := *prev
+	*prev = cur
+	if last.total == 0 || cur.total <= last.total || cur.proc < last.proc {
+		return 0
+	}
+	return math.Min(100, float64(cur.proc-last.proc)/float64(cur.total-last.total)*100)
+}
+
 func setMetricsProvider(p metricsProvider) {
 	provider = p
 }
 
-func NewMonitor(thresholds config.HealthThresholdsConfig, dispatcher *webhook.Dispatcher) *Monitor {
+func NewMonitor(thresholds config.HealthThresholdsConfig, dispatcher *webhook.Dispatcher, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	size := int(historyWindow / interval)
+	if size < 1 {
+		size = 1
+	}
+	if size > maxHistorySamples {
+		size = maxHistorySamples
+	}
 	return &Monitor{
 		startTime:  time.Now(),
 		thresholds: thresholds,
+		interval:   interval,
+		history:    make([]Sample, size),
 		dispatcher: dispatcher,
 	}
 }
 
 func (m *Monitor) Run(ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
 	for {
@@ -81,6 +200,27 @@ func (m *Monitor) GetStats() Stats {
 	return stats
 }
 
+// History returns the retained samples, oldest first, that were collected
+// after since. A zero since returns everything in the buffer.
+func (m *Monitor) History(since time.Time) []Sample {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ordered []Sample
+	if m.historyFull {
+		ordered = append(ordered, m.history[m.historyNext:]...)
+	}
+	ordered = append(ordered, m.history[:m.historyNext]...)
+
+	result := make([]Sample, 0, len(ordered))
+	for _, s := range ordered {
+		if s.Timestamp.After(since) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
 func (m *Monitor) collect() {
 	stats := Stats{
 		Uptime:       time.Since(m.startTime),
@@ -90,39 +230,201 @@ func (m *Monitor) collect() {
 	}
 
 	stats.CPUPercent = provider.CPUPercent(m)
+	stats.ProcessCPUPercent = provider.ProcessCPUPercent(m)
+	stats.CPUPerCore = provider.CoreCPUPercent(m)
 	stats.MemoryPercent, stats.MemoryUsedMB, stats.MemoryTotalMB = provider.MemoryUsage()
 	stats.TempCelsius = provider.Temperature()
+	stats.TempZones = provider.ThermalZones()
+	stats.DiskPercent, stats.DiskUsedMB, stats.DiskTotalMB = provider.Disk(m.diskPath())
+	if rx, tx, ok := provider.Network(m.getThresholds().NetworkInterface); ok {
+		stats.NetRxBytesSec, stats.NetTxBytesSec = m.netRates(netCounters{rx: rx, tx: tx, at: time.Now()})
+	}
+
+	if pid := m.getDump1090PID(); pid > 0 {
+		stats.Dump1090 = m.childStats(pid)
+	}
 
 	m.mu.Lock()
 	m.lastStats = stats
+	m.history[m.historyNext] = Sample{
+		Timestamp:     time.Now(),
+		CPUPercent:    stats.CPUPercent,
+		MemoryPercent: stats.MemoryPercent,
+		TempCelsius:   stats.TempCelsius,
+		DiskPercent:   stats.DiskPercent,
+	}
+	m.historyNext = (m.historyNext + 1) % len(m.history)
+	if m.historyNext == 0 {
+		m.historyFull = true
+	}
 	m.mu.Unlock()
 
 	m.checkThresholds(stats)
+	m.checkMessageRate(stats, time.Now())
 }
 
-func (m *Monitor) checkThresholds(stats Stats) {
+// SetDump1090PID supplies the process ID of a dump1090 that Skywatch started,
+// so its usage is reported separately. fn returns 0 while it is not running.
+func (m *Monitor) SetDump1090PID(fn func() int) {
+	m.mu.Lock()
+	m.dump1090PID = fn
+	m.mu.Unlock()
+}
+
+// SetRateSource supplies the feed whose message rate is checked against
+// min_messages_per_sec.
+func (m *Monitor) SetRateSource(src RateSource) {
+	m.mu.Lock()
+	m.rateSource = src
+	m.mu.Unlock()
+}
+
+func (m *Monitor) getDump1090PID() int {
+	m.mu.RLock()
+	fn := m.dump1090PID
+	m.mu.RUnlock()
+	if fn == nil {
+		return 0
+	}
+	return fn()
+}
+
+// childStats returns nil when the platform cannot read the process. A new
+// PID, such as after a restart, starts a fresh CPU baseline.
+func (m *Monitor) childStats(pid int) *ProcessStats {
+	times, rss, ok := provider.ProcessUsage(pid)
+	if !ok {
+		return nil
+	}
+	if pid != m.prevChildPID {
+		m.prevChild = processTimes{}
+		m.prevChildPID = pid
+	}
+	return &ProcessStats{
+		PID:        pid,
+		CPUPercent: processUsage(&m.prevChild, times),
+		RSSMB:      rss / 1024 / 1024,
+	}
+}
+
+// SetThresholds replaces the alert thresholds and monitored disk path.
+func (m *Monitor) SetThresholds(thresholds config.HealthThresholdsConfig) {
+	m.mu.Lock()
+	m.thresholds = thresholds
+	m.mu.Unlock()
+}
+
+func (m *Monitor) getThresholds() config.HealthThresholdsConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.thresholds
+}
+
+// Alert sends a health alert webhook carrying the latest stats.
+func (m *Monitor) Alert(alertType string) {
 	if m.dispatcher == nil {
 		return
 	}
+	m.dispatcher.SendHealthAlert(healthData(m.GetStats()), alertType)
+}
 
-	healthData := &webhook.HealthData{
+func healthData(stats Stats) *webhook.HealthData {
+	return &webhook.HealthData{
 		CPUPercent:    stats.CPUPercent,
 		MemoryPercent: stats.MemoryPercent,
 		TempCelsius:   stats.TempCelsius,
+		DiskPercent:   stats.DiskPercent,
 		Uptime:        stats.Uptime,
 	}
+}
+
+func (m *Monitor) checkThresholds(stats Stats) {
+	if m.dispatcher == nil {
+		return
+	}
+	thresholds := m.getThresholds()
+
+	healthData := healthData(stats)
 
-	if m.thresholds.CPUPercent > 0 && stats.CPUPercent > float64(m.thresholds.CPUPercent) {
+	if thresholds.CPUPercent > 0 && stats.CPUPercent > float64(thresholds.CPUPercent) {
 		m.dispatcher.SendHealthAlert(healthData, "High CPU usage: "+strconv.FormatFloat(stats.CPUPercent, 'f', 1, 64)+"%")
 	}
 
-	if m.thresholds.MemoryPercent > 0 && stats.MemoryPercent > float64(m.thresholds.MemoryPercent) {
+	if thresholds.MemoryPercent > 0 && stats.MemoryPercent > float64(thresholds.MemoryPercent) {
 		m.dispatcher.SendHealthAlert(healthData, "High memory usage: "+strconv.FormatFloat(stats.MemoryPercent, 'f', 1, 64)+"%")
 	}
 
-	if m.thresholds.TempCelsius > 0 && stats.TempCelsius > float64(m.thresholds.TempCelsius) {
+	if thresholds.TempCelsius > 0 && stats.TempCelsius > float64(thresholds.TempCelsius) {
 		m.dispatcher.SendHealthAlert(healthData, "High temperature: "+strconv.FormatFloat(stats.TempCelsius, 'f', 1, 64)+"°C")
 	}
+
+	if thresholds.DiskPercent > 0 && stats.DiskPercent > float64(thresholds.DiskPercent) {
+		m.dispatcher.SendHealthAlert(healthData, "Low disk space: "+strconv.FormatFloat(stats.DiskPercent, 'f', 1, 64)+"% used on "+m.diskPath())
+	}
+}
+
+// netRates returns the receive and transmit rates in bytes per second since
+// the previous reading. The first reading, or one after the counters go
+// backwards, returns zero.
+func (m *Monitor) netRates(cur netCounters) (float64, float64) {
+	last := m.prevNet
+	m.prevNet = cur
+	if last.at.IsZero() || cur.rx < last.rx || cur.tx < last.tx {
+		return 0, 0
+	}
+	secs := cur.at.Sub(last.at).Seconds()
+	if secs <= 0 {
+		return 0, 0
+	}
+	return float64(cur.rx-last.rx) / secs, float64(cur.tx-last.tx) / secs
+}
+
+// checkMessageRate alerts once when a connected feed's rate has stayed below
+// min_messages_per_sec for min_messages_duration, catching a failing antenna
+// or SDR that still delivers some messages. A disconnected feed is left to
+// the feed-down alert.
+func (m *Monitor) checkMessageRate(stats Stats, now time.Time) {
+	thresholds := m.getThresholds()
+	m.mu.RLock()
+	src := m.rateSource
+	m.mu.RUnlock()
+
+	if src == nil || thresholds.MinMessagesPerSec <= 0 {
+		m.lowRateSince, m.lowRateAlerted = time.Time{}, false
+		return
+	}
+
+	rate, connected := src.MessageRate()
+	if !connected || rate >= float64(thresholds.MinMessagesPerSec) {
+		if m.lowRateAlerted && connected {
+			logger.Info("message rate recovered", "messages_per_sec", rate)
+		}
+		m.lowRateSince, m.lowRateAlerted = time.Time{}, false
+		return
+	}
+
+	if m.lowRateSince.IsZero() {
+		m.lowRateSince = now
+	}
+	lowFor := now.Sub(m.lowRateSince)
+	if m.lowRateAlerted || lowFor < thresholds.MinMessagesDuration {
+		return
+	}
+
+	m.lowRateAlerted = true
+	logger.Warn("message rate below threshold", "messages_per_sec", rate,
+		"min_messages_per_sec", thresholds.MinMessagesPerSec, "for", lowFor.Round(time.Second))
+	if m.dispatcher != nil {
+		m.dispatcher.SendHealthAlert(healthData(stats), "Low message rate: "+strconv.FormatFloat(rate, 'f', 1, 64)+
+			" msg/s for "+lowFor.Round(time.Second).String())
+	}
+}
+
+func (m *Monitor) diskPath() string {
+	if path := m.getThresholds().DiskPath; path != "" {
+		return path
+	}
+	return "/"
 }
 
 func (m *Monitor) GetUptime() time.Duration {
@@ -131,7 +433,14 @@ func (m *Monitor) GetUptime() time.Duration {
 
 func (m *Monitor) LogStats() {
 	stats := m.GetStats()
-	log.Printf("[HEALTH] CPU: %.1f%%, Memory: %.1f%% (%dMB/%dMB), Temp: %.1f°C, Uptime: %s, Goroutines: %d",
-		stats.CPUPercent, stats.MemoryPercent, stats.MemoryUsedMB, stats.MemoryTotalMB,
-		stats.TempCelsius, stats.UptimeString, stats.GoRoutines)
+	logger.Info("health stats",
+		"cpu_percent", stats.CPUPercent, "process_cpu_percent", stats.ProcessCPUPercent,
+		"memory_percent", stats.MemoryPercent, "memory_used_mb", stats.MemoryUsedMB, "memory_total_mb", stats.MemoryTotalMB,
+		"disk_percent", stats.DiskPercent, "disk_used_mb", stats.DiskUsedMB, "disk_total_mb", stats.DiskTotalMB,
+		"net_rx_bytes_per_sec", stats.NetRxBytesSec, "net_tx_bytes_per_sec", stats.NetTxBytesSec,
+		"temp_celsius", stats.TempCelsius, "uptime", stats.UptimeString, "goroutines", stats.GoRoutines)
+	if stats.Dump1090 != nil {
+		logger.Info("dump1090 stats", "pid", stats.Dump1090.PID,
+			"cpu_percent", stats.Dump1090.CPUPercent, "rss_mb", stats.Dump1090.RSSMB)
+	}
 }
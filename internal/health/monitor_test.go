@@ -13,9 +13,14 @@ type mockMetrics struct {
 	usedMB    uint64
 	totalMB   uint64
 	tempC     float64
+	diskPct   float64
+	diskUsed  uint64
+	diskTotal uint64
 	cpuCalls  int
 	memCalls  int
 	tempCalls int
+	child     *processTimes
+	childRSS  uint64
 }
 
 func (m *mockMetrics) CPUPercent(*Monitor) float64 {
@@ -23,6 +28,25 @@ func (m *mockMetrics) CPUPercent(*Monitor) float64 {
 	return m.cpu
 }
 
+func (m *mockMetrics) ProcessUsage(int) (processTimes, uint64, bool) {
+	if m.child == nil {
+		return processTimes{}, 0, false
+	}
+	return *m.child, m.childRSS, true
+}
+
+func (m *mockMetrics) ProcessCPUPercent(*Monitor) float64 {
+	return 0
+}
+
+func (m *mockMetrics) CoreCPUPercent(*Monitor) []float64 {
+	return nil
+}
+
+func (m *mockMetrics) Network(string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
+
 func (m *mockMetrics) MemoryUsage() (float64, uint64, uint64) {
 	m.memCalls++
 	return m.memPct, m.usedMB, m.totalMB
@@ -33,13 +57,24 @@ func (m *mockMetrics) Temperature() float64 {
 	return m.tempC
 }
 
+func (m *mockMetrics) ThermalZones() map[string]float64 {
+	return nil
+}
+
+func (m *mockMetrics) Disk(string) (float64, uint64, uint64) {
+	return m.diskPct, m.diskUsed, m.diskTotal
+}
+
 func TestMonitorCollectUsesMetricsProvider(t *testing.T) {
 	mock := &mockMetrics{
-		cpu:     42.5,
-		memPct:  73.2,
-		usedMB:  512,
-		totalMB: 1024,
-		tempC:   55.1,
+		cpu:       42.5,
+		memPct:    73.2,
+		usedMB:    512,
+		totalMB:   1024,
+		tempC:     55.1,
+		diskPct:   61.0,
+		diskUsed:  6100,
+		diskTotal: 10000,
 	}
 	prevProvider := provider
 	setMetricsProvider(mock)
@@ -47,7 +82,7 @@ func TestMonitorCollectUsesMetricsProvider(t *testing.T) {
 		setMetricsProvider(prevProvider)
 	})
 
-	m := NewMonitor(config.HealthThresholdsConfig{}, nil)
+	m := NewMonitor(config.HealthThresholdsConfig{}, nil, 0)
 	m.collect()
 
 	stats := m.GetStats()
@@ -60,6 +95,9 @@ func TestMonitorCollectUsesMetricsProvider(t *testing.T) {
 	if stats.TempCelsius != mock.tempC {
 		t.Fatalf("expected temp %v got %v", mock.tempC, stats.TempCelsius)
 	}
+	if stats.DiskPercent != mock.diskPct || stats.DiskUsedMB != mock.diskUsed || stats.DiskTotalMB != mock.diskTotal {
+		t.Fatalf("unexpected disk stats %+v", stats)
+	}
 	if mock.cpuCalls == 0 || mock.memCalls == 0 || mock.tempCalls == 0 {
 		t.Fatal("metrics provider not invoked")
 	}
@@ -73,10 +111,141 @@ func TestMonitorGetStatsUpdatesUptime(t *testing.T) {
 		setMetricsProvider(prevProvider)
 	})
 
-	m := NewMonitor(config.HealthThresholdsConfig{}, nil)
+	m := NewMonitor(config.HealthThresholdsConfig{}, nil, 0)
 	time.Sleep(10 * time.Millisecond)
 	stats := m.GetStats()
 	if stats.Uptime <= 0 {
 		t.Fatal("expected uptime to increase")
 	}
 }
+
+func TestMonitorHistoryIsBoundedAndOrdered(t *testing.T) {
+	mock := &mockMetrics{}
+	prevProvider := provider
+	setMetricsProvider(mock)
+	t.Cleanup(func() {
+		setMetricsProvider(prevProvider)
+	})
+
+	m := NewMonitor(config.HealthThresholdsConfig{}, nil, 20*time.Minute)
+	if len(m.history) != 3 {
+		t.Fatalf("expected 3 history slots, got %d", len(m.history))
+	}
+
+	for i := 1; i <= 5; i++ {
+		mock.cpu = float64(i)
+		m.collect()
+	}
+
+	history := m.History(time.Time{})
+	if len(history) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(history))
+	}
+	for i, want := range []float64{3, 4, 5} {
+		if history[i].CPUPercent != want {
+			t.Fatalf("sample %d: expected cpu %v got %v", i, want, history[i].CPUPercent)
+		}
+	}
+
+	if got := m.History(time.Now().Add(time.Minute)); len(got) != 0 {
+		t.Fatalf("expected no samples after future cutoff, got %d", len(got))
+	}
+}
+
+func TestMonitorNetRates(t *testing.T) {
+	m := NewMonitor(config.HealthThresholdsConfig{}, nil, time.Second)
+	start := time.Now()
+
+	if rx, tx := m.netRates(netCounters{rx: 1000, tx: 500, at: start}); rx != 0 || tx != 0 {
+		t.Errorf("expected zero rates on first reading, got %v/%v", rx, tx)
+	}
+	if rx, tx := m.netRates(netCounters{rx: 21000, tx: 2500, at: start.Add(10 * time.Second)}); rx != 2000 || tx != 200 {
+		t.Errorf("expected 2000/200 bytes per second, got %v/%v", rx, tx)
+	}
+	if rx, tx := m.netRates(netCounters{rx: 10, tx: 10, at: start.Add(20 * time.Second)}); rx != 0 || tx != 0 {
+		t.Errorf("expected zero rates after a counter reset, got %v/%v", rx, tx)
+	}
+}
+
+func TestProcessUsage(t *testing.T) {
+	var prev processTimes
+	if got := processUsage(&prev, processTimes{proc: 100, total: 10000}); got != 0 {
+		t.Errorf("expected 0 on first reading, got %v", got)
+	}
+	if got := processUsage(&prev, processTimes{proc: 150, total: 11000}); got != 5 {
+		t.Errorf("expected 5%%, got %v", got)
+	}
+}
+
+func TestMonitorReportsDump1090Usage(t *testing.T) {
+	mock := &mockMetrics{child: &processTimes{proc: 100, total: 10000}, childRSS: 64 << 20}
+	prevProvider := provider
+	setMetricsProvider(mock)
+	t.Cleanup(func() {
+		setMetricsProvider(prevProvider)
+	})
+
+	m := NewMonitor(config.HealthThresholdsConfig{}, nil, 0)
+	m.collect()
+	if m.GetStats().Dump1090 != nil {
+		t.Fatal("expected no dump1090 stats without a PID source")
+	}
+
+	pid := 1234
+	m.SetDump1090PID(func() int { return pid })
+	m.collect()
+	mock.child = &processTimes{proc: 300, total: 11000}
+	m.collect()
+
+	got := m.GetStats().Dump1090
+	if got == nil || got.PID != 1234 || got.CPUPercent != 20 || got.RSSMB != 64 {
+		t.Fatalf("unexpected dump1090 stats %+v", got)
+	}
+
+	pid = 0
+	m.collect()
+	if m.GetStats().Dump1090 != nil {
+		t.Fatal("expected no dump1090 stats while it is stopped")
+	}
+}
+
+type fakeRate struct {
+	rate      float64
+	connected bool
+}
+
+func (f *fakeRate) MessageRate() (float64, bool) {
+	return f.rate, f.connected
+}
+
+func TestMonitorAlertsOnSustainedLowMessageRate(t *testing.T) {
+	m := NewMonitor(config.HealthThresholdsConfig{MinMessagesPerSec: 10, MinMessagesDuration: 5 * time.Minute}, nil, 0)
+	feed := &fakeRate{rate: 2, connected: true}
+	m.SetRateSource(feed)
+	start := time.Now()
+
+	m.checkMessageRate(Stats{}, start)
+	m.checkMessageRate(Stats{}, start.Add(4*time.Minute))
+	if m.lowRateAlerted {
+		t.Fatal("expected no alert before the duration elapses")
+	}
+	m.checkMessageRate(Stats{}, start.Add(5*time.Minute))
+	if !m.lowRateAlerted {
+		t.Fatal("expected an alert after a sustained low rate")
+	}
+
+	feed.connected = false
+	m.checkMessageRate(Stats{}, start.Add(6*time.Minute))
+	feed.connected = true
+	m.checkMessageRate(Stats{}, start.Add(7*time.Minute))
+	m.checkMessageRate(Stats{}, start.Add(11*time.Minute))
+	if m.lowRateAlerted {
+		t.Fatal("expected a reconnect to restart the low rate period")
+	}
+
+	feed.rate = 50
+	m.checkMessageRate(Stats{}, start.Add(20*time.Minute))
+	if !m.lowRateSince.IsZero() {
+		t.Fatal("expected a healthy rate to clear the low rate period")
+	}
+}
@@ -0,0 +1,47 @@
+// Package logging gives each package a slog logger tagged with its component
+// name.
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Component returns a logger that adds component=name to every record and
+// writes through whatever slog.Default is when it logs. Packages can
+// therefore create theirs in a package-level var, before main has
+// configured logging.
+func Component(name string) *slog.Logger {
+	attrs := []slog.Attr{slog.String("component", name)}
+	return slog.New(&defaultHandler{wrap: func(h slog.Handler) slog.Handler {
+		return h.WithAttrs(attrs)
+	}})
+}
+
+// defaultHandler forwards records to the current default handler, after
+// applying the attributes and groups added to it.
+type defaultHandler struct {
+	wrap func(slog.Handler) slog.Handler
+}
+
+func (h *defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h *defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.wrap(slog.Default().Handler()).Handle(ctx, r)
+}
+
+func (h *defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	wrap := h.wrap
+	return &defaultHandler{wrap: func(base slog.Handler) slog.Handler {
+		return wrap(base).WithAttrs(attrs)
+	}}
+}
+
+func (h *defaultHandler) WithGroup(name string) slog.Handler {
+	wrap := h.wrap
+	return &defaultHandler{wrap: func(base slog.Handler) slog.Handler {
+		return wrap(base).WithGroup(name)
+	}}
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestComponentFollowsDefault(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	// Created before the default is replaced, as a package-level var would be.
+	logger := Component("tracker")
+
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Debug("position jump rejected")
+	if buf.Len() != 0 {
+		t.Fatalf("expected debug to be filtered at info level, got %q", buf.String())
+	}
+
+	logger.With("icao", "ABC123").Info("aircraft added")
+	out := buf.String()
+	for _, want := range []string{"component=tracker", "icao=ABC123", `msg="aircraft added"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in %q", want, out)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package lookup
+
+import "testing"
+
+func TestCountryForICAO(t *testing.T) {
+	cases := []struct {
+		icao string
+		code string
+	}{
+		{"3C0000", "DE"},
+		{"3FFFFF", "DE"},
+		{"400000", "GB"},
+		{"43FFFF", "GB"},
+		{"A00000", "US"},
+		{"AFFFFF", "US"},
+		{"a1b2c3", "US"},
+		{" 7C1234 ", "AU"},
+		{"004000", "ZW"},
+		{"0043FF", "ZW"},
+		{"E94FFF", "BO"},
+		// Unallocated.
+		{"000000", ""},
+		{"004400", ""},
+		{"E95000", ""},
+		{"FFFFFF", ""},
+		// Malformed.
+		{"", ""},
+		{"XYZ123", ""},
+		{"1000000", ""},
+		{"-00001", ""},
+	}
+	for _, tc := range cases {
+		country, ok := CountryForICAO(tc.icao)
+		if ok != (tc.code != "") || country.Code != tc.code {
+			t.Fatalf("%q: expected %q, got %q (ok=%v)", tc.icao, tc.code, country.Code, ok)
+		}
+		if ok && country.Name == "" {
+			t.Fatalf("%q: expected a country name", tc.icao)
+		}
+	}
+}
+
+func TestAddressBlocksSorted(t *testing.T) {
+	for i, b := range addressBlocks {
+		if b.start > b.end {
+			t.Fatalf("%s: start %06X after end %06X", b.code, b.start, b.end)
+		}
+		if i > 0 && b.start <= addressBlocks[i-1].end {
+			t.Fatalf("%s overlaps or is out of order with %s", b.code, addressBlocks[i-1].code)
+		}
+	}
+}
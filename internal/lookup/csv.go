@@ -0,0 +1,109 @@
+package lookup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"adsb-tracker/pkg/models"
+)
+
+// CSVProvider serves registration data from a local CSV file loaded into
+// memory at startup. The file needs a header row with an icao column;
+// registration, type, manufacturer, model, operator and owner columns are
+// used when present.
+type CSVProvider struct {
+	records map[string]*models.FAAInfo
+}
+
+func NewCSVProvider(path string) (*CSVProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no csv_path configured")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := ReadRegistryCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &CSVProvider{records: records}, nil
+}
+
+// ReadRegistryCSV reads registry records keyed by upper-case ICAO address
+// from CSV with a header row, in the format described on CSVProvider. Rows
+// without an address are skipped.
+func ReadRegistryCSV(r io.Reader) (map[string]*models.FAAInfo, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	icaoCol, ok := cols["icao"]
+	if !ok {
+		return nil, fmt.Errorf("missing icao column")
+	}
+
+	field := func(row []string, name string) string {
+		i, ok := cols[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	records := make(map[string]*models.FAAInfo)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if icaoCol >= len(row) {
+			continue
+		}
+
+		icao := strings.ToUpper(strings.TrimSpace(row[icaoCol]))
+		if icao == "" {
+			continue
+		}
+
+		records[icao] = &models.FAAInfo{
+			Registration: field(row, "registration"),
+			AircraftType: field(row, "type"),
+			Manufacturer: field(row, "manufacturer"),
+			Model:        field(row, "model"),
+			Operator:     field(row, "operator"),
+			Owner:        field(row, "owner"),
+		}
+	}
+
+	return records, nil
+}
+
+func (p *CSVProvider) Name() string { return "csv" }
+
+func (p *CSVProvider) Fetch(icao string) (*models.FAAInfo, error) {
+	info, ok := p.records[strings.ToUpper(icao)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *info
+	return &copied, nil
+}
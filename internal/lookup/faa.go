@@ -1,118 +1,290 @@
 package lookup
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"adsb-tracker/internal/database"
+	"adsb-tracker/internal/logging"
 	"adsb-tracker/pkg/models"
 )
 
+var logger = logging.Component("lookup")
+
+const (
+	cacheTTL           = 24 * time.Hour
+	defaultNotFoundTTL = time.Hour
+	// failedRetryAfter is how long to wait before asking again when every
+	// provider failed, e.g. during an upstream outage. Unlike a miss it is
+	// not persisted.
+	failedRetryAfter = time.Minute
+
+	// refreshAge is how old a database record from a network provider may
+	// get before it is fetched again.
+	refreshAge = 30 * 24 * time.Hour
+
+	defaultRequestsPerSecond = 1
+	rateLimitBurst           = 5
+)
+
+// Sources recorded against registry rows that do not come from a provider.
+// Rows from providers carry the provider name.
+const (
+	SourceFAARegistry = "faa"
+	SourceImport      = "import"
+)
+
+// registryStore is the registry storage FAALookup reads and writes,
+// satisfied by *database.Repository.
+type registryStore interface {
+	GetFAARecord(icao string) (*database.FAARecord, error)
+	GetRecentFAAInfo(since time.Time) (map[string]*models.FAAInfo, error)
+	GetFAAMisses(since time.Time) (map[string]time.Time, error)
+	SaveFAAInfo(icao string, info *models.FAAInfo, source string) error
+	SaveFAAMiss(icao string) error
+	DeleteFAAMiss(icao string) error
+}
+
 type FAALookup struct {
-	repo   *database.Repository
-	cache  map[string]*cacheEntry
-	mu     sync.RWMutex
-	client *http.Client
+	repo        registryStore
+	providers   []Provider
+	cache       map[string]*cacheEntry
+	inflight    map[string]*inflightLookup
+	mu          sync.RWMutex
+	notFoundTTL time.Duration
+	limiter     *rateLimiter
 }
 
 type cacheEntry struct {
 	info      *models.FAAInfo
 	timestamp time.Time
 	notFound  bool
+	failed    bool
 }
 
-func NewFAALookup(repo *database.Repository) *FAALookup {
-	return &FAALookup{
-		repo:  repo,
-		cache: make(map[string]*cacheEntry),
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+type inflightLookup struct {
+	done chan struct{}
+	info *models.FAAInfo
+}
+
+// NewFAALookup creates a lookup that queries providers in order and caches
+// the first hit. With no providers it falls back to hexdb.io.
+func NewFAALookup(repo *database.Repository, providers []Provider) *FAALookup {
+	if len(providers) == 0 {
+		providers = []Provider{&hexDBProvider{client: NewHTTPClient()}}
+	}
+	f := &FAALookup{
+		providers:   providers,
+		cache:       make(map[string]*cacheEntry),
+		inflight:    make(map[string]*inflightLookup),
+		notFoundTTL: defaultNotFoundTTL,
+		limiter:     newRateLimiter(defaultRequestsPerSecond, rateLimitBurst),
 	}
+	// Only set when non-nil, so a nil repository isn't stored as a non-nil
+	// interface.
+	if repo != nil {
+		f.repo = repo
+	}
+	return f
 }
 
-func (f *FAALookup) Lookup(icao string) *models.FAAInfo {
-	f.mu.RLock()
-	entry, ok := f.cache[icao]
-	f.mu.RUnlock()
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+	}
+}
 
-	if ok && time.Since(entry.timestamp) < 24*time.Hour {
-		if entry.notFound {
-			return nil
-		}
-		return entry.info
+// SetNotFoundTTL sets how long a miss from every provider is remembered
+// before the aircraft is looked up again.
+func (f *FAALookup) SetNotFoundTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	f.mu.Lock()
+	f.notFoundTTL = ttl
+	f.mu.Unlock()
+}
+
+// SetRateLimit caps outbound provider requests across all lookups. A
+// non-positive rate disables the limit.
+func (f *FAALookup) SetRateLimit(perSecond float64) {
+	f.mu.Lock()
+	f.limiter = newRateLimiter(perSecond, rateLimitBurst)
+	f.mu.Unlock()
+}
+
+// Lookup returns registry data for icao from the cache, the database or the
+// provider chain, in that order. It blocks while providers are queried;
+// concurrent lookups for the same address share one request.
+func (f *FAALookup) Lookup(icao string) *models.FAAInfo {
+	if info, ok := f.cached(icao); ok {
+		return info
 	}
 
+	var stale *models.FAAInfo
 	if f.repo != nil {
-		info, err := f.repo.GetFAAInfo(icao)
-		if err == nil && info != nil {
-			f.mu.Lock()
-			f.cache[icao] = &cacheEntry{info: info, timestamp: time.Now()}
-			f.mu.Unlock()
-			return info
+		rec, err := f.repo.GetFAARecord(icao)
+		if err == nil && rec != nil {
+			if !needsRefresh(rec, time.Now()) {
+				f.mu.Lock()
+				f.cache[icao] = &cacheEntry{info: &rec.FAAInfo, timestamp: time.Now()}
+				f.mu.Unlock()
+				return &rec.FAAInfo
+			}
+			stale = &rec.FAAInfo
 		}
 	}
 
-	go f.fetchAndCache(icao)
-	return nil
+	return f.fetchAndCache(icao, stale)
 }
 
-func (f *FAALookup) fetchAndCache(icao string) {
-	info := f.fetchFromHexDB(icao)
-	
+// needsRefresh reports whether a database record should be fetched again.
+// Imported records are only replaced by another import.
+func needsRefresh(rec *database.FAARecord, now time.Time) bool {
+	switch rec.Source {
+	case SourceFAARegistry, SourceImport:
+		return false
+	}
+	return now.Sub(rec.UpdatedAt) > refreshAge
+}
+
+// Warm preloads the cache from the database: registry rows for aircraft
+// seen within the last day, and persisted misses that have not yet expired.
+func (f *FAALookup) Warm() {
+	if f.repo == nil {
+		return
+	}
+
+	f.mu.RLock()
+	notFoundTTL := f.notFoundTTL
+	f.mu.RUnlock()
+
+	infos, err := f.repo.GetRecentFAAInfo(time.Now().Add(-cacheTTL))
+	if err != nil {
+		logger.Error("cache warm failed", "error", err)
+		return
+	}
+	misses, err := f.repo.GetFAAMisses(time.Now().Add(-notFoundTTL))
+	if err != nil {
+		logger.Error("cache warm failed", "error", err)
+		return
+	}
+
+	now := time.Now()
 	f.mu.Lock()
-	if info != nil {
-		f.cache[icao] = &cacheEntry{info: info, timestamp: time.Now()}
-		if f.repo != nil {
-			f.repo.SaveFAAInfo(icao, info)
+	for icao, info := range infos {
+		f.cache[icao] = &cacheEntry{info: info, timestamp: now}
+	}
+	for icao, checkedAt := range misses {
+		if _, ok := f.cache[icao]; !ok {
+			f.cache[icao] = &cacheEntry{notFound: true, timestamp: checkedAt}
 		}
-	} else {
-		f.cache[icao] = &cacheEntry{notFound: true, timestamp: time.Now()}
 	}
 	f.mu.Unlock()
+
+	logger.Info("warmed cache", "records", len(infos), "misses", len(misses))
 }
 
-func (f *FAALookup) fetchFromHexDB(icao string) *models.FAAInfo {
-	url := fmt.Sprintf("https://hexdb.io/api/v1/aircraft/%s", icao)
-	
-	resp, err := f.client.Get(url)
-	if err != nil {
-		log.Printf("[FAA] Lookup failed for %s: %v", icao, err)
-		return nil
+// Store caches records written to the registry by an import, replacing any
+// remembered misses for them.
+func (f *FAALookup) Store(records map[string]*models.FAAInfo) {
+	now := time.Now()
+	f.mu.Lock()
+	for icao, info := range records {
+		f.cache[icao] = &cacheEntry{info: info, timestamp: now}
 	}
-	defer resp.Body.Close()
+	f.mu.Unlock()
+}
+
+func (f *FAALookup) cached(icao string) (*models.FAAInfo, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil
+	entry, ok := f.cache[icao]
+	if !ok {
+		return nil, false
 	}
+	if entry.failed {
+		return nil, time.Since(entry.timestamp) < failedRetryAfter
+	}
+	if entry.notFound {
+		return nil, time.Since(entry.timestamp) < f.notFoundTTL
+	}
+	return entry.info, time.Since(entry.timestamp) < cacheTTL
+}
 
-	var data struct {
-		Registration string `json:"Registration"`
-		Type         string `json:"Type"`
-		ICAOType     string `json:"ICAOTypeCode"`
-		Manufacturer string `json:"Manufacturer"`
-		RegisteredOwner string `json:"RegisteredOwners"`
+// fetchAndCache queries the providers for icao. When none has it, stale is
+// kept in use if set; otherwise the miss is remembered, but only if every
+// provider answered that it has no record. When a provider failed the
+// lookup is retried after failedRetryAfter.
+func (f *FAALookup) fetchAndCache(icao string, stale *models.FAAInfo) *models.FAAInfo {
+	f.mu.Lock()
+	if call, ok := f.inflight[icao]; ok {
+		f.mu.Unlock()
+		<-call.done
+		return call.info
 	}
+	call := &inflightLookup{done: make(chan struct{})}
+	f.inflight[icao] = call
+	limiter := f.limiter
+	f.mu.Unlock()
 
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		log.Printf("[FAA] Decode failed for %s: %v", icao, err)
-		return nil
+	info, source, answered := f.fetch(icao, limiter)
+	refreshed := info != nil
+	if !refreshed {
+		info = stale
 	}
 
-	if data.Registration == "" && data.Type == "" {
-		return nil
+	f.mu.Lock()
+	switch {
+	case info != nil:
+		f.cache[icao] = &cacheEntry{info: info, timestamp: time.Now()}
+	case answered:
+		f.cache[icao] = &cacheEntry{notFound: true, timestamp: time.Now()}
+	default:
+		f.cache[icao] = &cacheEntry{failed: true, timestamp: time.Now()}
 	}
+	call.info = info
+	delete(f.inflight, icao)
+	f.mu.Unlock()
+	close(call.done)
 
-	return &models.FAAInfo{
-		Registration: data.Registration,
-		AircraftType: data.ICAOType,
-		Manufacturer: data.Manufacturer,
-		Model:        data.Type,
-		Owner:        data.RegisteredOwner,
+	if f.repo != nil {
+		if refreshed {
+			if err := f.repo.SaveFAAInfo(icao, info, source); err != nil {
+				logger.Error("failed to save registry record", "icao", icao, "error", err)
+			}
+			f.repo.DeleteFAAMiss(icao)
+		} else if info == nil && answered {
+			if err := f.repo.SaveFAAMiss(icao); err != nil {
+				logger.Error("failed to save lookup miss", "icao", icao, "error", err)
+			}
+		}
 	}
+
+	return info
 }
 
+// fetch returns the first hit from the providers and the name of the one
+// that supplied it. Without a hit, answered reports whether every provider
+// said it has no record, rather than failing, so a miss can be told apart
+// from an outage.
+func (f *FAALookup) fetch(icao string, limiter *rateLimiter) (info *models.FAAInfo, source string, answered bool) {
+	answered = true
+	for _, p := range f.providers {
+		if _, local := p.(*CSVProvider); !local {
+			limiter.Wait()
+		}
+		info, err := p.Fetch(icao)
+		if err != nil {
+			logger.Warn("lookup failed", "source", p.Name(), "icao", icao, "error", err)
+			answered = false
+			continue
+		}
+		if info != nil {
+			return info, p.Name(), true
+		}
+	}
+	return nil, "", answered
+}
@@ -0,0 +1,166 @@
+package lookup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"adsb-tracker/internal/database"
+	"adsb-tracker/pkg/models"
+)
+
+const faaImportBatchSize = 5000
+
+// ImportFAARegistry loads the FAA releasable aircraft database (MASTER.txt
+// and ACFTREF.txt from https://registry.faa.gov/database/ReleasableAircraft.zip)
+// in dir into faa_registry. Rows are upserted, so re-importing a newer
+// download updates existing records in place. It returns the number of
+// records written.
+func ImportFAARegistry(repo *database.Repository, dir string) (int, error) {
+	refs, err := readFAAAircraftRef(filepath.Join(dir, "ACFTREF.txt"))
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Open(filepath.Join(dir, "MASTER.txt"))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, cols, err := newFAAReader(file)
+	if err != nil {
+		return 0, fmt.Errorf("MASTER.txt: %w", err)
+	}
+
+	batch := make(map[string]*models.FAAInfo, faaImportBatchSize)
+	total, skipped := 0, 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := repo.SaveFAAInfoBatch(batch, SourceFAARegistry); err != nil {
+			return err
+		}
+		total += len(batch)
+		batch = make(map[string]*models.FAAInfo, faaImportBatchSize)
+		return nil
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, fmt.Errorf("MASTER.txt: %w", err)
+		}
+
+		nnumber := cols.get(row, "N-NUMBER")
+		icao, err := NNumberToICAO(nnumber)
+		if err != nil {
+			icao = strings.ToUpper(cols.get(row, "MODE S CODE HEX"))
+		}
+		if len(icao) != 6 {
+			skipped++
+			continue
+		}
+
+		info := &models.FAAInfo{
+			Registration: "N" + strings.TrimPrefix(strings.ToUpper(nnumber), "N"),
+			Owner:        cols.get(row, "NAME"),
+		}
+		if ref, ok := refs[cols.get(row, "MFR MDL CODE")]; ok {
+			info.Manufacturer = ref.manufacturer
+			info.Model = ref.model
+		}
+		batch[icao] = info
+
+		if len(batch) >= faaImportBatchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return total, err
+	}
+
+	if skipped > 0 {
+		logger.Warn("skipped registry rows without a usable ICAO address", "count", skipped)
+	}
+	return total, nil
+}
+
+type faaModelRef struct {
+	manufacturer string
+	model        string
+}
+
+func readFAAAircraftRef(path string) (map[string]faaModelRef, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, cols, err := newFAAReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("ACFTREF.txt: %w", err)
+	}
+
+	refs := make(map[string]faaModelRef)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ACFTREF.txt: %w", err)
+		}
+		code := cols.get(row, "CODE")
+		if code == "" {
+			continue
+		}
+		refs[code] = faaModelRef{
+			manufacturer: cols.get(row, "MFR"),
+			model:        cols.get(row, "MODEL"),
+		}
+	}
+	return refs, nil
+}
+
+// faaColumns maps the FAA's header names to column indexes. The files are
+// fixed-width padded CSV, so every value is trimmed.
+type faaColumns map[string]int
+
+func (c faaColumns) get(row []string, name string) string {
+	i, ok := c[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+func newFAAReader(r io.Reader) (*csv.Reader, faaColumns, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+
+	cols := make(faaColumns, len(header))
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		cols[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	return reader, cols, nil
+}
@@ -0,0 +1,112 @@
+package lookup
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"adsb-tracker/internal/database"
+	"adsb-tracker/pkg/models"
+)
+
+func TestNeedsRefresh(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-refreshAge - time.Hour)
+	recent := now.Add(-time.Hour)
+
+	cases := []struct {
+		source  string
+		updated time.Time
+		want    bool
+	}{
+		{"hexdb", recent, false},
+		{"hexdb", old, true},
+		{"", old, true},
+		{SourceFAARegistry, old, false},
+		{SourceImport, old, false},
+	}
+	for _, c := range cases {
+		rec := &database.FAARecord{Source: c.source, UpdatedAt: c.updated}
+		if got := needsRefresh(rec, now); got != c.want {
+			t.Errorf("source %q updated %v ago: expected %v, got %v", c.source, now.Sub(c.updated).Round(time.Hour), c.want, got)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return fn(r) }
+
+// statusClient returns a client whose requests all get the given status.
+func statusClient(status int) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader("")),
+			Header:     make(http.Header),
+			Request:    r,
+		}, nil
+	})}
+}
+
+func TestLookupDoesNotCacheProviderFailureAsMiss(t *testing.T) {
+	f := NewFAALookup(nil, []Provider{&hexDBProvider{client: statusClient(http.StatusInternalServerError)}})
+	f.SetRateLimit(0)
+
+	if info := f.Lookup("A12345"); info != nil {
+		t.Fatalf("expected no info, got %+v", info)
+	}
+	entry := f.cache["A12345"]
+	if entry == nil || entry.notFound || !entry.failed {
+		t.Fatalf("expected a failed lookup, not a miss, got %+v", entry)
+	}
+
+	f.providers = []Provider{&hexDBProvider{client: statusClient(http.StatusNotFound)}}
+	f.cache["A12345"].timestamp = time.Now().Add(-failedRetryAfter - time.Second)
+	f.Lookup("A12345")
+	if entry := f.cache["A12345"]; entry == nil || !entry.notFound {
+		t.Fatalf("expected a 404 to be remembered as a miss, got %+v", entry)
+	}
+}
+
+// missStore records the misses a lookup persists.
+type missStore struct {
+	misses []string
+}
+
+func (s *missStore) GetFAARecord(string) (*database.FAARecord, error) { return nil, nil }
+func (s *missStore) GetRecentFAAInfo(time.Time) (map[string]*models.FAAInfo, error) {
+	return nil, nil
+}
+func (s *missStore) GetFAAMisses(time.Time) (map[string]time.Time, error) { return nil, nil }
+func (s *missStore) SaveFAAInfo(string, *models.FAAInfo, string) error    { return nil }
+func (s *missStore) SaveFAAMiss(icao string) error {
+	s.misses = append(s.misses, icao)
+	return nil
+}
+func (s *missStore) DeleteFAAMiss(string) error { return nil }
+
+func TestLookupPersistsOnlyRealMisses(t *testing.T) {
+	cases := []struct {
+		status int
+		miss   bool
+	}{
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusNotFound, true},
+	}
+	for _, tc := range cases {
+		store := &missStore{}
+		f := NewFAALookup(nil, []Provider{&hexDBProvider{client: statusClient(tc.status)}})
+		f.repo = store
+		f.SetRateLimit(0)
+
+		f.Lookup("A12345")
+		if got := len(store.misses) == 1; got != tc.miss {
+			t.Errorf("status %d: expected miss saved=%v, got %v", tc.status, tc.miss, store.misses)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package lookup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// US civil aircraft are assigned ICAO addresses A00001 (N1) through ADF7C7
+// (N99999) in N-number order. Each position in the N-number partitions the
+// remaining address space into buckets, so the address can be computed
+// without a lookup table.
+const (
+	nnumberLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ" // I and O are not used
+
+	nnumberSuffixSize  = 1 + len(nnumberLetters)*(1+len(nnumberLetters))
+	nnumberBucket4Size = 1 + len(nnumberLetters) + 10
+	nnumberBucket3Size = 10*nnumberBucket4Size + nnumberSuffixSize
+	nnumberBucket2Size = 10*nnumberBucket3Size + nnumberSuffixSize
+	nnumberBucket1Size = 10*nnumberBucket2Size + nnumberSuffixSize
+
+	nnumberBase = 0xA00001
+)
+
+// NNumberToICAO converts a US registration such as "N12345" or "N1AB" to
+// its six character hex ICAO address.
+func NNumberToICAO(nnumber string) (string, error) {
+	n := strings.ToUpper(strings.TrimSpace(nnumber))
+	n = strings.TrimPrefix(n, "N")
+	if len(n) == 0 || len(n) > 5 || n[0] < '1' || n[0] > '9' {
+		return "", fmt.Errorf("invalid N-number %q", nnumber)
+	}
+
+	out := nnumberBase + int(n[0]-'1')*nnumberBucket1Size
+	buckets := []int{nnumberBucket2Size, nnumberBucket3Size, nnumberBucket4Size}
+
+	for i := 1; i < len(n); i++ {
+		c := n[i]
+
+		if i == 4 {
+			// The last position holds either one letter or one digit.
+			if isDigit(c) {
+				out += 1 + len(nnumberLetters) + int(c-'0')
+			} else if idx := strings.IndexByte(nnumberLetters, c); idx >= 0 {
+				out += 1 + idx
+			} else {
+				return "", fmt.Errorf("invalid N-number %q", nnumber)
+			}
+			break
+		}
+
+		if isDigit(c) {
+			out += nnumberSuffixSize + int(c-'0')*buckets[i-1]
+			continue
+		}
+
+		offset, ok := nnumberSuffixOffset(n[i:])
+		if !ok {
+			return "", fmt.Errorf("invalid N-number %q", nnumber)
+		}
+		out += offset
+		break
+	}
+
+	return fmt.Sprintf("%06X", out), nil
+}
+
+// nnumberSuffixOffset returns the offset of a one or two letter suffix.
+func nnumberSuffixOffset(s string) (int, bool) {
+	if len(s) == 0 || len(s) > 2 {
+		return 0, false
+	}
+	first := strings.IndexByte(nnumberLetters, s[0])
+	if first < 0 {
+		return 0, false
+	}
+	offset := first*(len(nnumberLetters)+1) + 1
+	if len(s) == 2 {
+		second := strings.IndexByte(nnumberLetters, s[1])
+		if second < 0 {
+			return 0, false
+		}
+		offset += second + 1
+	}
+	return offset, true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
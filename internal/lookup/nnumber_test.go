@@ -0,0 +1,31 @@
+package lookup
+
+import "testing"
+
+func TestNNumberToICAO(t *testing.T) {
+	cases := map[string]string{
+		"N1":     "A00001",
+		"N1A":    "A00002",
+		"N1AA":   "A00003",
+		"N1Z":    "A00241",
+		"N10":    "A0025A",
+		"n12345": "A061D9",
+		"N99999": "ADF7C7",
+		"N9999Z": "ADF7BD",
+	}
+	for in, want := range cases {
+		got, err := NNumberToICAO(in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %s got %s", in, want, got)
+		}
+	}
+
+	for _, in := range []string{"", "N", "N0", "N123456", "N1ABC", "N1I", "N12A3"} {
+		if _, err := NNumberToICAO(in); err == nil {
+			t.Fatalf("%q: expected error", in)
+		}
+	}
+}
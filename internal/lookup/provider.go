@@ -0,0 +1,138 @@
+package lookup
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"adsb-tracker/internal/config"
+	"adsb-tracker/pkg/models"
+)
+
+// Provider is a source of aircraft registration data. Fetch returns nil
+// info and a nil error when the source has no record for the address.
+type Provider interface {
+	Name() string
+	Fetch(icao string) (*models.FAAInfo, error)
+}
+
+// NewProviders builds the provider chain from config in the configured
+// order. Unknown or unusable sources are logged and skipped.
+func NewProviders(cfg config.LookupConfig, client *http.Client) []Provider {
+	var providers []Provider
+	for _, name := range cfg.Sources {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "hexdb":
+			providers = append(providers, &hexDBProvider{client: client})
+		case "opensky":
+			providers = append(providers, &openSkyProvider{client: client})
+		case "csv":
+			p, err := NewCSVProvider(cfg.CSVPath)
+			if err != nil {
+				logger.Warn("CSV source disabled", "error", err)
+				continue
+			}
+			providers = append(providers, p)
+		default:
+			logger.Warn("unknown lookup source ignored", "source", name)
+		}
+	}
+	return providers
+}
+
+type hexDBProvider struct {
+	client *http.Client
+}
+
+func (p *hexDBProvider) Name() string { return "hexdb" }
+
+func (p *hexDBProvider) Fetch(icao string) (*models.FAAInfo, error) {
+	url := fmt.Sprintf("https://hexdb.io/api/v1/aircraft/%s", icao)
+
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Registration    string `json:"Registration"`
+		Type            string `json:"Type"`
+		ICAOType        string `json:"ICAOTypeCode"`
+		Manufacturer    string `json:"Manufacturer"`
+		RegisteredOwner string `json:"RegisteredOwners"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	if data.Registration == "" && data.Type == "" {
+		return nil, nil
+	}
+
+	return &models.FAAInfo{
+		Registration: data.Registration,
+		AircraftType: data.ICAOType,
+		Manufacturer: data.Manufacturer,
+		Model:        data.Type,
+		Owner:        data.RegisteredOwner,
+	}, nil
+}
+
+type openSkyProvider struct {
+	client *http.Client
+}
+
+func (p *openSkyProvider) Name() string { return "opensky" }
+
+func (p *openSkyProvider) Fetch(icao string) (*models.FAAInfo, error) {
+	url := fmt.Sprintf("https://opensky-network.org/api/metadata/aircraft/icao/%s", strings.ToLower(icao))
+
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Registration     string `json:"registration"`
+		ManufacturerName string `json:"manufacturerName"`
+		Model            string `json:"model"`
+		TypeCode         string `json:"typecode"`
+		Operator         string `json:"operator"`
+		Owner            string `json:"owner"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	if data.Registration == "" && data.Model == "" && data.TypeCode == "" {
+		return nil, nil
+	}
+
+	return &models.FAAInfo{
+		Registration: data.Registration,
+		AircraftType: data.TypeCode,
+		Manufacturer: data.ManufacturerName,
+		Model:        data.Model,
+		Operator:     data.Operator,
+		Owner:        data.Owner,
+	}, nil
+}
@@ -5,6 +5,46 @@ import (
 	"time"
 )
 
+// BandAll is the altitude band name for the ring covering every altitude.
+const BandAll = "all"
+
+// DefaultBucketCount splits the compass into ten-degree buckets.
+const DefaultBucketCount = 36
+
+// WarmUp is how long Record stays quiet about new maximums after starting
+// without stored history, or after a reset. With empty rings nearly every
+// early contact is a new maximum, which would otherwise alert in a burst.
+const WarmUp = 15 * time.Minute
+
+// AltitudeBand is a range of altitudes tracked as its own ring. MaxFt of 0
+// means no upper limit.
+type AltitudeBand struct {
+	Name  string
+	MinFt int
+	MaxFt int
+}
+
+// altitudeBands split contacts by altitude, since terrain limits range far
+// more at low level than at cruise.
+var altitudeBands = []AltitudeBand{
+	{Name: "0-10k", MinFt: 0, MaxFt: 10000},
+	{Name: "10-25k", MinFt: 10000, MaxFt: 25000},
+	{Name: "25k+", MinFt: 25000},
+}
+
+func bandFor(altFt int) int {
+	for i, b := range altitudeBands {
+		if altFt < b.MinFt {
+			continue
+		}
+		if b.MaxFt == 0 || altFt < b.MaxFt {
+			return i
+		}
+	}
+	// Reported altitudes below zero count as the lowest band.
+	return 0
+}
+
 type BucketStats struct {
 	Bearing      int     `json:"bearing"`
 	MaxRangeNM   float64 `json:"max_range_nm"`
@@ -12,41 +52,145 @@ type BucketStats struct {
 	ContactCount int64   `json:"contact_count"`
 }
 
+// StoredBucket is a bucket as persisted, tagged with its altitude band.
+type StoredBucket struct {
+	Band string
+	BucketStats
+}
+
+type BandStats struct {
+	Band          string        `json:"band"`
+	MinAltFt      int           `json:"min_alt_ft"`
+	MaxAltFt      *int          `json:"max_alt_ft,omitempty"`
+	MaxRangeNM    float64       `json:"max_range_nm"`
+	MaxRangeICAO  string        `json:"max_range_icao,omitempty"`
+	TotalContacts int64         `json:"total_contacts"`
+	Buckets       []BucketStats `json:"buckets"`
+}
+
 type RangeStats struct {
 	Buckets        []BucketStats `json:"buckets"`
 	AllTimeMaxNM   float64       `json:"all_time_max_nm"`
 	AllTimeMaxICAO string        `json:"all_time_max_icao,omitempty"`
 	TotalContacts  int64         `json:"total_contacts"`
+	Bands          []BandStats   `json:"bands,omitempty"`
+	Window         string        `json:"window,omitempty"`
 	UpdatedAt      time.Time     `json:"updated_at"`
 }
 
+// ring holds the per-bearing maxima for one altitude band.
+type ring struct {
+	maxByBearing   []float64
+	icaoByBearing  []string
+	countByBearing []int64
+	maxNM          float64
+	maxICAO        string
+}
+
+func newRing(bucketCount int) ring {
+	return ring{
+		maxByBearing:   make([]float64, bucketCount),
+		icaoByBearing:  make([]string, bucketCount),
+		countByBearing: make([]int64, bucketCount),
+	}
+}
+
+// record counts a contact and reports whether it set a new maximum for the
+// bucket.
+func (r *ring) record(bucket int, distanceNM float64, icao string) bool {
+	r.countByBearing[bucket]++
+	if distanceNM > r.maxNM {
+		r.maxNM = distanceNM
+		r.maxICAO = icao
+	}
+	if distanceNM > r.maxByBearing[bucket] {
+		r.maxByBearing[bucket] = distanceNM
+		r.icaoByBearing[bucket] = icao
+		return true
+	}
+	return false
+}
+
+func (r *ring) load(s BucketStats) {
+	r.maxByBearing[s.Bearing] = s.MaxRangeNM
+	r.icaoByBearing[s.Bearing] = s.MaxRangeICAO
+	r.countByBearing[s.Bearing] = s.ContactCount
+	if s.MaxRangeNM > r.maxNM {
+		r.maxNM = s.MaxRangeNM
+		r.maxICAO = s.MaxRangeICAO
+	}
+}
+
+func (r *ring) buckets() ([]BucketStats, int64) {
+	width := 360 / len(r.maxByBearing)
+	buckets := make([]BucketStats, len(r.maxByBearing))
+	var total int64
+	for i := range r.maxByBearing {
+		buckets[i] = BucketStats{
+			Bearing:      i * width,
+			MaxRangeNM:   r.maxByBearing[i],
+			MaxRangeICAO: r.icaoByBearing[i],
+			ContactCount: r.countByBearing[i],
+		}
+		total += r.countByBearing[i]
+	}
+	return buckets, total
+}
+
 type Tracker struct {
-	mu             sync.RWMutex
-	maxByBearing   [36]float64
-	icaoByBearing  [36]string
-	countByBearing [36]int64
-	allTimeMaxNM   float64
-	allTimeMaxICAO string
-	repo           Repository
+	mu          sync.RWMutex
+	bucketCount int
+	all         ring
+	bands       []ring
+	repo        Repository
+	quietUntil  time.Time
 }
 
+// Repository persists range rings. Rows are keyed by bucket count as well
+// as band and bucket, so changing the resolution starts a fresh set of
+// rings rather than misreading the old ones.
 type Repository interface {
-	SaveRangeStats(bucket int, maxNM float64, icao string, count int64) error
-	LoadRangeStats() ([]BucketStats, error)
+	SaveRangeStats(band string, bucketCount, bucket int, maxNM float64, icao string, count int64) error
+	LoadRangeStats(bucketCount int) ([]StoredBucket, error)
+	ResetRangeStats() error
+}
+
+// ValidBucketCount reports whether n bearing buckets evenly divide the
+// compass into whole degrees.
+func ValidBucketCount(n int) bool {
+	return n > 0 && 360%n == 0
 }
 
-func New(repo Repository) *Tracker {
+// New creates a tracker with bucketCount bearing buckets, falling back to
+// DefaultBucketCount when it does not divide 360.
+func New(repo Repository, bucketCount int) *Tracker {
+	if !ValidBucketCount(bucketCount) {
+		bucketCount = DefaultBucketCount
+	}
 	t := &Tracker{
-		repo: repo,
+		bucketCount: bucketCount,
+		repo:        repo,
 	}
+	t.clear()
 	if repo != nil {
 		t.loadFromDB()
 	}
+	if t.all.maxNM == 0 {
+		t.quietUntil = time.Now().Add(WarmUp)
+	}
 	return t
 }
 
+func (t *Tracker) clear() {
+	t.all = newRing(t.bucketCount)
+	t.bands = make([]ring, len(altitudeBands))
+	for i := range t.bands {
+		t.bands[i] = newRing(t.bucketCount)
+	}
+}
+
 func (t *Tracker) loadFromDB() {
-	stats, err := t.repo.LoadRangeStats()
+	stats, err := t.repo.LoadRangeStats(t.bucketCount)
 	if err != nil {
 		return
 	}
@@ -55,46 +199,63 @@ func (t *Tracker) loadFromDB() {
 	defer t.mu.Unlock()
 
 	for _, s := range stats {
-		if s.Bearing >= 0 && s.Bearing < 36 {
-			t.maxByBearing[s.Bearing] = s.MaxRangeNM
-			t.icaoByBearing[s.Bearing] = s.MaxRangeICAO
-			t.countByBearing[s.Bearing] = s.ContactCount
-
-			if s.MaxRangeNM > t.allTimeMaxNM {
-				t.allTimeMaxNM = s.MaxRangeNM
-				t.allTimeMaxICAO = s.MaxRangeICAO
-			}
+		if s.Bearing < 0 || s.Bearing >= t.bucketCount {
+			continue
+		}
+		if r := t.ringFor(s.Band); r != nil {
+			r.load(s.BucketStats)
 		}
 	}
 }
 
-func (t *Tracker) Record(bearing, distanceNM float64, icao string) {
+func (t *Tracker) ringFor(band string) *ring {
+	if band == BandAll {
+		return &t.all
+	}
+	for i, b := range altitudeBands {
+		if b.Name == band {
+			return &t.bands[i]
+		}
+	}
+	return nil
+}
+
+// Record counts a contact at the given bearing and distance. altFt places
+// it in an altitude band as well; contacts without an altitude only count
+// towards the combined ring. It reports whether the contact set a new
+// all-time maximum range, except during WarmUp.
+func (t *Tracker) Record(bearing, distanceNM float64, altFt *int, icao string) bool {
 	if bearing < 0 || bearing >= 360 || distanceNM <= 0 {
-		return
+		return false
 	}
 
-	bucket := int(bearing / 10)
-	if bucket >= 36 {
-		bucket = 35
+	bucket := int(bearing / (360 / float64(t.bucketCount)))
+	if bucket >= t.bucketCount {
+		bucket = t.bucketCount - 1
 	}
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	t.countByBearing[bucket]++
-
-	if distanceNM > t.maxByBearing[bucket] {
-		t.maxByBearing[bucket] = distanceNM
-		t.icaoByBearing[bucket] = icao
+	prevMax := t.all.maxNM
+	if t.all.record(bucket, distanceNM, icao) {
+		t.save(BandAll, bucket, distanceNM, icao, t.all.countByBearing[bucket])
+	}
 
-		if t.repo != nil {
-			go t.repo.SaveRangeStats(bucket, distanceNM, icao, t.countByBearing[bucket])
+	if altFt != nil {
+		i := bandFor(*altFt)
+		band := &t.bands[i]
+		if band.record(bucket, distanceNM, icao) {
+			t.save(altitudeBands[i].Name, bucket, distanceNM, icao, band.countByBearing[bucket])
 		}
 	}
 
-	if distanceNM > t.allTimeMaxNM {
-		t.allTimeMaxNM = distanceNM
-		t.allTimeMaxICAO = icao
+	return distanceNM > prevMax && !time.Now().Before(t.quietUntil)
+}
+
+func (t *Tracker) save(band string, bucket int, maxNM float64, icao string, count int64) {
+	if t.repo != nil {
+		go t.repo.SaveRangeStats(band, t.bucketCount, bucket, maxNM, icao, count)
 	}
 }
 
@@ -103,28 +264,74 @@ func (t *Tracker) GetStats() RangeStats {
 	defer t.mu.RUnlock()
 
 	stats := RangeStats{
-		Buckets:        make([]BucketStats, 36),
-		AllTimeMaxNM:   t.allTimeMaxNM,
-		AllTimeMaxICAO: t.allTimeMaxICAO,
+		AllTimeMaxNM:   t.all.maxNM,
+		AllTimeMaxICAO: t.all.maxICAO,
+		Bands:          make([]BandStats, len(altitudeBands)),
 		UpdatedAt:      time.Now(),
 	}
+	stats.Buckets, stats.TotalContacts = t.all.buckets()
+
+	for i, b := range altitudeBands {
+		r := &t.bands[i]
+		band := BandStats{
+			Band:         b.Name,
+			MinAltFt:     b.MinFt,
+			MaxRangeNM:   r.maxNM,
+			MaxRangeICAO: r.maxICAO,
+		}
+		if b.MaxFt > 0 {
+			maxFt := b.MaxFt
+			band.MaxAltFt = &maxFt
+		}
+		band.Buckets, band.TotalContacts = r.buckets()
+		stats.Bands[i] = band
+	}
+
+	return stats
+}
 
-	for i := 0; i < 36; i++ {
-		stats.Buckets[i] = BucketStats{
-			Bearing:      i * 10,
-			MaxRangeNM:   t.maxByBearing[i],
-			MaxRangeICAO: t.icaoByBearing[i],
-			ContactCount: t.countByBearing[i],
+// Reset clears the accumulated range statistics, in memory and in the
+// database, and returns the cleared state.
+func (t *Tracker) Reset() (RangeStats, error) {
+	t.mu.Lock()
+	t.clear()
+	t.quietUntil = time.Now().Add(WarmUp)
+	t.mu.Unlock()
+
+	if t.repo != nil {
+		if err := t.repo.ResetRangeStats(); err != nil {
+			return t.GetStats(), err
 		}
-		stats.TotalContacts += t.countByBearing[i]
 	}
+	return t.GetStats(), nil
+}
 
+// BucketCount returns the number of bearing buckets per ring.
+func (t *Tracker) BucketCount() int {
+	return t.bucketCount
+}
+
+// StatsFromBuckets builds a single ring from buckets computed elsewhere, such
+// as a query over a time window. Bearing holds the bucket index.
+func StatsFromBuckets(bucketCount int, buckets []BucketStats) RangeStats {
+	r := newRing(bucketCount)
+	for _, b := range buckets {
+		if b.Bearing >= 0 && b.Bearing < bucketCount {
+			r.load(b)
+		}
+	}
+
+	stats := RangeStats{
+		AllTimeMaxNM:   r.maxNM,
+		AllTimeMaxICAO: r.maxICAO,
+		UpdatedAt:      time.Now(),
+	}
+	stats.Buckets, stats.TotalContacts = r.buckets()
 	return stats
 }
 
 func (t *Tracker) GetMaxRange() (float64, string) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.allTimeMaxNM, t.allTimeMaxICAO
+	return t.all.maxNM, t.all.maxICAO
 }
-
@@ -0,0 +1,55 @@
+package rangetracker
+
+import (
+	"testing"
+	"time"
+)
+
+type storedRepo struct {
+	buckets []StoredBucket
+}
+
+func (r *storedRepo) SaveRangeStats(string, int, int, float64, string, int64) error { return nil }
+func (r *storedRepo) LoadRangeStats(int) ([]StoredBucket, error)                    { return r.buckets, nil }
+func (r *storedRepo) ResetRangeStats() error                                        { return nil }
+
+func TestRecordQuietDuringWarmUp(t *testing.T) {
+	trk := New(nil, DefaultBucketCount)
+	for _, nm := range []float64{20, 40, 60} {
+		if trk.Record(90, nm, nil, "ABC123") {
+			t.Fatalf("%v nm: expected no new-maximum report during warm-up", nm)
+		}
+	}
+	if stats := trk.GetStats(); stats.AllTimeMaxNM != 60 {
+		t.Fatalf("expected contacts to be recorded during warm-up, got max %v", stats.AllTimeMaxNM)
+	}
+
+	trk.quietUntil = time.Now().Add(-time.Second)
+	if trk.Record(90, 50, nil, "DEF456") {
+		t.Fatal("expected a shorter contact not to be a new maximum")
+	}
+	if !trk.Record(90, 80, nil, "DEF456") {
+		t.Fatal("expected a new maximum after warm-up")
+	}
+
+	if _, err := trk.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if trk.Record(90, 100, nil, "GHI789") {
+		t.Fatal("expected a reset to start a new warm-up")
+	}
+}
+
+func TestRecordWithStoredHistorySkipsWarmUp(t *testing.T) {
+	repo := &storedRepo{buckets: []StoredBucket{
+		{Band: BandAll, BucketStats: BucketStats{Bearing: 9, MaxRangeNM: 120, MaxRangeICAO: "ABC123", ContactCount: 10}},
+	}}
+	trk := New(repo, DefaultBucketCount)
+
+	if trk.Record(90, 100, nil, "DEF456") {
+		t.Fatal("expected a contact inside the stored maximum not to be reported")
+	}
+	if !trk.Record(90, 150, nil, "DEF456") {
+		t.Fatal("expected a new maximum over stored history to be reported at once")
+	}
+}
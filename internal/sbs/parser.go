@@ -12,7 +12,12 @@ const (
 	idxMessageType    = 0
 	idxMessageSubtype = 1
 	idxICAO           = 4
+	idxDateGenerated  = 6
+	idxTimeGenerated  = 7
+	idxDateLogged     = 8
+	idxTimeLogged     = 9
 	idxCallsign       = 10
+	idxStatus         = 10
 	idxAltitude       = 11
 	idxGroundSpeed    = 12
 	idxHeading        = 13
@@ -20,17 +25,59 @@ const (
 	idxLongitude      = 15
 	idxVertRate       = 16
 	idxSquawk         = 17
+	idxAlert          = 18
+	idxEmergency      = 19
+	idxSPI            = 20
 	idxOnGround       = 21
-	minFields         = 22
+
+	// minFields is the fewest columns that still reach the ICAO address.
+	// Feeds trim trailing empty columns or emit narrower records, so any
+	// later column may be missing and is read through field.
+	minFields = idxICAO + 1
 )
 
+// Record types. MSG lines carry decoded transmissions; the others are
+// BaseStation bookkeeping records.
+const (
+	RecordMSG = "MSG"
+	RecordSEL = "SEL"
+	RecordID  = "ID"
+	RecordAIR = "AIR"
+	RecordSTA = "STA"
+)
+
+// STA statuses meaning the sender has dropped the aircraft. PL (position
+// lost), SL (signal lost) and OK need no action.
+const (
+	statusRemove = "RM"
+	statusDelete = "AD"
+)
+
+// ParseResult is a parsed SBS line. Record is the record type and
+// MessageType the MSG transmission type (1-8), or 0 for other records.
 type ParseResult struct {
 	Aircraft    *models.Aircraft
+	Record      string
 	MessageType int
 	Valid       bool
+	// Remove is set for STA records reporting the aircraft gone. Aircraft
+	// then carries only the ICAO and record time and is not an update.
+	Remove bool
 }
 
+// ParseMessageWithType parses one SBS line. Valid is set when the line is a
+// recognized record with an ICAO address; Aircraft then holds the update,
+// if the record carries one. Timestamps older than DefaultMaxTimestampAge
+// are replaced with the current time.
 func ParseMessageWithType(line string) ParseResult {
+	return ParseMessageWithMaxAge(line, DefaultMaxTimestampAge)
+}
+
+// ParseMessageWithMaxAge is ParseMessageWithType with a limit on how old a
+// message timestamp may be before the current time is used instead. A
+// maxAge of 0 keeps past timestamps of any age, for replayed or buffered
+// data.
+func ParseMessageWithMaxAge(line string, maxAge time.Duration) ParseResult {
 	result := ParseResult{}
 
 	fields := strings.Split(line, ",")
@@ -38,58 +85,82 @@ func ParseMessageWithType(line string) ParseResult {
 		return result
 	}
 
-	if fields[idxMessageType] != "MSG" {
+	icao := strings.TrimSpace(fields[idxICAO])
+	if icao == "" {
 		return result
 	}
-
-	if subtype, err := strconv.Atoi(strings.TrimSpace(fields[idxMessageSubtype])); err == nil {
-		result.MessageType = subtype
+	ac := &models.Aircraft{
+		ICAO:     strings.ToUpper(icao),
+		LastSeen: messageTime(fields, maxAge),
 	}
 
-	icao := strings.TrimSpace(fields[idxICAO])
-	if icao == "" {
+	result.Record = strings.TrimSpace(fields[idxMessageType])
+	switch result.Record {
+	case RecordMSG:
+	case RecordSEL, RecordID:
+		ac.Callsign = sanitizeCallsign(field(fields, idxCallsign))
+		result.Aircraft = ac
+		result.Valid = true
+		return result
+	case RecordAIR:
+		result.Aircraft = ac
+		result.Valid = true
+		return result
+	case RecordSTA:
+		status := strings.TrimSpace(field(fields, idxStatus))
+		if status == statusRemove || status == statusDelete {
+			result.Aircraft = ac
+			result.Remove = true
+		}
+		result.Valid = true
+		return result
+	default:
 		return result
 	}
 
-	result.Valid = true
-	ac := &models.Aircraft{
-		ICAO:     strings.ToUpper(icao),
-		LastSeen: time.Now().UTC(),
+	if subtype, err := strconv.Atoi(strings.TrimSpace(fields[idxMessageSubtype])); err == nil {
+		result.MessageType = subtype
 	}
 
-	if cs := strings.TrimSpace(fields[idxCallsign]); cs != "" {
+	result.Valid = true
+
+	if cs := sanitizeCallsign(field(fields, idxCallsign)); cs != "" {
 		ac.Callsign = cs
 	}
 
-	if alt := parseInt(fields[idxAltitude]); alt != nil {
+	if alt := parseInt(field(fields, idxAltitude)); alt != nil {
 		ac.AltitudeFt = alt
 	}
 
-	if spd := parseFloat(fields[idxGroundSpeed]); spd != nil {
+	if spd := parseFloat(field(fields, idxGroundSpeed)); spd != nil {
 		ac.SpeedKt = spd
 	}
 
-	if hdg := parseFloat(fields[idxHeading]); hdg != nil {
+	if hdg := parseFloat(field(fields, idxHeading)); hdg != nil {
 		ac.Heading = hdg
 	}
 
-	if lat := parseFloat(fields[idxLatitude]); lat != nil {
+	if lat := parseFloat(field(fields, idxLatitude)); lat != nil {
 		ac.Lat = lat
 	}
 
-	if lon := parseFloat(fields[idxLongitude]); lon != nil {
+	if lon := parseFloat(field(fields, idxLongitude)); lon != nil {
 		ac.Lon = lon
 	}
 
-	if vr := parseInt(fields[idxVertRate]); vr != nil {
+	if vr := parseInt(field(fields, idxVertRate)); vr != nil {
 		ac.VerticalRate = vr
 	}
 
-	if sq := strings.TrimSpace(fields[idxSquawk]); sq != "" {
+	if sq := strings.TrimSpace(field(fields, idxSquawk)); sq != "" {
 		ac.Squawk = sq
 	}
 
-	if og := parseBool(fields[idxOnGround]); og != nil {
+	ac.Alert = parseBool(field(fields, idxAlert))
+	ac.Emergency = parseBool(field(fields, idxEmergency))
+	ac.SPI = parseBool(field(fields, idxSPI))
+
+	if og := parseBool(field(fields, idxOnGround)); og != nil {
 		ac.OnGround = og
 	}
 
@@ -97,63 +168,79 @@ func ParseMessageWithType(line string) ParseResult {
 	return result
 }
 
+// ParseMessage returns the aircraft update carried by line, or nil when the
+// line is invalid or carries no update.
+//
+// Deprecated: use ParseMessageWithType, which also reports the record and
+// transmission type and STA removals.
 func ParseMessage(line string) *models.Aircraft {
-	fields := strings.Split(line, ",")
-	if len(fields) < minFields {
+	result := ParseMessageWithType(line)
+	if !result.Valid || result.Remove {
 		return nil
 	}
+	return result.Aircraft
+}
 
-	if fields[idxMessageType] != "MSG" {
-		return nil
-	}
-
-	icao := strings.TrimSpace(fields[idxICAO])
-	if icao == "" {
-		return nil
-	}
-
-	ac := &models.Aircraft{
-		ICAO:     strings.ToUpper(icao),
-		LastSeen: time.Now().UTC(),
-	}
-
-	if cs := strings.TrimSpace(fields[idxCallsign]); cs != "" {
-		ac.Callsign = cs
-	}
-
-	if alt := parseInt(fields[idxAltitude]); alt != nil {
-		ac.AltitudeFt = alt
-	}
-
-	if spd := parseFloat(fields[idxGroundSpeed]); spd != nil {
-		ac.SpeedKt = spd
-	}
-
-	if hdg := parseFloat(fields[idxHeading]); hdg != nil {
-		ac.Heading = hdg
-	}
-
-	if lat := parseFloat(fields[idxLatitude]); lat != nil {
-		ac.Lat = lat
-	}
-
-	if lon := parseFloat(fields[idxLongitude]); lon != nil {
-		ac.Lon = lon
-	}
+// timestampLayout matches the SBS date and time columns joined by a space.
+// Feeds vary in how many fractional digits they send, so any are accepted.
+const timestampLayout = "2006/01/02 15:04:05.999999999"
+
+// DefaultMaxTimestampAge is how far behind the current time an SBS
+// timestamp may be, by default, before it is treated as clock skew. It is
+// kept well under the stale timeout so a feeder whose clock or timezone lags
+// can't get aircraft removed as soon as they are seen.
+const DefaultMaxTimestampAge = 10 * time.Second
+
+// messageTime returns when the message was generated, falling back to when
+// it was logged and then to the current time. SBS timestamps are in the
+// sender's local time; times in the future, or more than maxAge in the past
+// when maxAge is positive, are replaced with now so clock skew can't make an
+// aircraft look newer or older than it is.
+func messageTime(fields []string, maxAge time.Duration) time.Time {
+	now := time.Now().UTC()
+	ts, ok := parseTimestamp(field(fields, idxDateGenerated), field(fields, idxTimeGenerated))
+	if !ok {
+		ts, ok = parseTimestamp(field(fields, idxDateLogged), field(fields, idxTimeLogged))
+	}
+	if !ok || ts.After(now) || (maxAge > 0 && now.Sub(ts) > maxAge) {
+		return now
+	}
+	return ts
+}
 
-	if vr := parseInt(fields[idxVertRate]); vr != nil {
-		ac.VerticalRate = vr
+func parseTimestamp(date, clock string) (time.Time, bool) {
+	date = strings.TrimSpace(date)
+	clock = strings.TrimSpace(clock)
+	if date == "" || clock == "" {
+		return time.Time{}, false
 	}
-
-	if sq := strings.TrimSpace(fields[idxSquawk]); sq != "" {
-		ac.Squawk = sq
+	ts, err := time.ParseInLocation(timestampLayout, date+" "+clock, time.Local)
+	if err != nil {
+		return time.Time{}, false
 	}
+	return ts.UTC(), true
+}
 
-	if og := parseBool(fields[idxOnGround]); og != nil {
-		ac.OnGround = og
+// field returns column i, or an empty string when the line is too short to
+// have it.
+func field(fields []string, i int) string {
+	if i >= len(fields) {
+		return ""
 	}
+	return fields[i]
+}
 
-	return ac
+// sanitizeCallsign keeps only A-Z and 0-9, the characters a Beast ident
+// decodes to, so padding and control characters some feeds send don't make
+// the same flight look different between formats.
+func sanitizeCallsign(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
 
 func parseInt(s string) *int {
@@ -0,0 +1,268 @@
+package sbs
+
+import (
+	"testing"
+	"time"
+
+	"adsb-tracker/pkg/models"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	cases := []struct {
+		date, clock string
+		ok          bool
+	}{
+		{"2024/01/15", "10:30:00.123", true},
+		{"2024/01/15", "10:30:00", true},
+		{"", "10:30:00.123", false},
+		{"2024/01/15", "", false},
+		{"2024-01-15", "10:30:00.123", false},
+		{"2024/13/15", "10:30:00.123", false},
+		{"garbage", "garbage", false},
+	}
+	for _, tc := range cases {
+		ts, ok := parseTimestamp(tc.date, tc.clock)
+		if ok != tc.ok {
+			t.Fatalf("%q %q: expected ok=%v, got %v", tc.date, tc.clock, tc.ok, ok)
+		}
+		if ok && ts.Location() != time.UTC {
+			t.Fatalf("%q %q: expected UTC, got %v", tc.date, tc.clock, ts.Location())
+		}
+	}
+}
+
+// sbsTime formats ts as the date and time columns of an SBS line, in local
+// time as feeders send them.
+func sbsTime(ts time.Time) string {
+	return ts.Local().Format("2006/01/02,15:04:05.000")
+}
+
+func TestParseMessageUsesGeneratedTime(t *testing.T) {
+	generated := time.Now().Add(-2 * time.Second).Truncate(time.Millisecond)
+	logged := generated.Add(time.Second)
+	line := "MSG,3,1,1,4CA2D6,1," + sbsTime(generated) + "," + sbsTime(logged) + ",,35000,,,51.5,-0.1,,,0,0,0,0"
+	res := ParseMessageWithType(line)
+	if !res.Valid {
+		t.Fatal("expected valid message")
+	}
+	if want := generated.UTC(); !res.Aircraft.LastSeen.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, res.Aircraft.LastSeen)
+	}
+}
+
+func TestParseMessageFallsBackToLoggedTime(t *testing.T) {
+	logged := time.Now().Add(-2 * time.Second).Truncate(time.Millisecond)
+	line := "MSG,3,1,1,4CA2D6,1,,," + sbsTime(logged) + ",,35000,,,51.5,-0.1,,,0,0,0,0"
+	ac := ParseMessage(line)
+	if ac == nil {
+		t.Fatal("expected aircraft")
+	}
+	if want := logged.UTC(); !ac.LastSeen.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, ac.LastSeen)
+	}
+}
+
+func TestParseMessageFallsBackToNow(t *testing.T) {
+	for _, line := range []string{
+		"MSG,3,1,1,4CA2D6,1,,,,,,35000,,,51.5,-0.1,,,0,0,0,0",
+		"MSG,3,1,1,4CA2D6,1,bad,bad,bad,bad,,35000,,,51.5,-0.1,,,0,0,0,0",
+		"MSG,3,1,1,4CA2D6,1,2999/01/01,00:00:00.000,,,,35000,,,51.5,-0.1,,,0,0,0,0",
+		// Too old: a feeder whose clock or timezone is behind the server's.
+		"MSG,3,1,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:01.000,,35000,,,51.5,-0.1,,,0,0,0,0",
+		"MSG,3,1,1,4CA2D6,1," + sbsTime(time.Now().Add(-time.Hour)) + ",,,,35000,,,51.5,-0.1,,,0,0,0,0",
+	} {
+		before := time.Now().UTC()
+		ac := ParseMessage(line)
+		if ac == nil {
+			t.Fatalf("%s: expected aircraft", line)
+		}
+		if ac.LastSeen.Before(before) || ac.LastSeen.After(time.Now().UTC()) {
+			t.Fatalf("%s: expected current time, got %v", line, ac.LastSeen)
+		}
+	}
+}
+
+func TestParseMessageReplayKeepsOldTimestamps(t *testing.T) {
+	line := "MSG,3,1,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:01.000,,35000,,,51.5,-0.1,,,0,0,0,0"
+	want := time.Date(2024, 1, 15, 10, 30, 0, 123e6, time.Local).UTC()
+
+	res := ParseMessageWithMaxAge(line, 0)
+	if !res.Valid || !res.Aircraft.LastSeen.Equal(want) {
+		t.Fatalf("expected replayed timestamp %v, got %+v", want, res.Aircraft)
+	}
+
+	hourAgo := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	line = "MSG,3,1,1,4CA2D6,1," + sbsTime(hourAgo) + ",,,,35000,,,51.5,-0.1,,,0,0,0,0"
+	if res := ParseMessageWithMaxAge(line, 2*time.Hour); !res.Aircraft.LastSeen.Equal(hourAgo.UTC()) {
+		t.Fatalf("expected a timestamp within the limit to be kept, got %v", res.Aircraft.LastSeen)
+	}
+
+	future := "MSG,3,1,1,4CA2D6,1,2999/01/01,00:00:00.000,,,,35000,,,51.5,-0.1,,,0,0,0,0"
+	if res := ParseMessageWithMaxAge(future, 0); res.Aircraft.LastSeen.After(time.Now().UTC()) {
+		t.Fatalf("expected future timestamps to be clamped in replay mode, got %v", res.Aircraft.LastSeen)
+	}
+}
+
+func TestParseMessageFlags(t *testing.T) {
+	line := "MSG,6,1,1,4CA2D6,1,,,,,,,,,,,,7700,0,-1,1,0"
+	ac := ParseMessage(line)
+	if ac == nil {
+		t.Fatal("expected aircraft")
+	}
+	if ac.Alert == nil || *ac.Alert {
+		t.Fatalf("expected alert false, got %v", ac.Alert)
+	}
+	if !ac.EmergencyFlagged() {
+		t.Fatalf("expected emergency flag, got %v", ac.Emergency)
+	}
+	if ac.SPI == nil || !*ac.SPI {
+		t.Fatalf("expected SPI set, got %v", ac.SPI)
+	}
+
+	ac = ParseMessage("MSG,3,1,1,4CA2D6,1,,,,,,35000,,,51.5,-0.1,,,,,,0")
+	if ac.Alert != nil || ac.Emergency != nil || ac.SPI != nil {
+		t.Fatal("expected flags to be unset when the columns are empty")
+	}
+}
+
+func TestParseShortMessages(t *testing.T) {
+	// Ident with the trailing empty columns trimmed.
+	res := ParseMessageWithType("MSG,1,1,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123,RYR1234 ")
+	if !res.Valid || res.MessageType != 1 {
+		t.Fatalf("expected valid type 1 message, got %+v", res)
+	}
+	if res.Aircraft.Callsign != "RYR1234" {
+		t.Fatalf("expected callsign RYR1234, got %q", res.Aircraft.Callsign)
+	}
+
+	// Airborne position cut off after the longitude.
+	ac := ParseMessage("MSG,3,1,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123,,35000,,,51.50000,-0.10000")
+	if ac == nil {
+		t.Fatal("expected aircraft")
+	}
+	if ac.AltitudeFt == nil || *ac.AltitudeFt != 35000 {
+		t.Fatalf("expected altitude 35000, got %v", ac.AltitudeFt)
+	}
+	if ac.Lat == nil || ac.Lon == nil || *ac.Lat != 51.5 || *ac.Lon != -0.1 {
+		t.Fatalf("expected position 51.5,-0.1, got %v,%v", ac.Lat, ac.Lon)
+	}
+	if ac.OnGround != nil || ac.Squawk != "" {
+		t.Fatal("expected missing columns to be left unset")
+	}
+
+	// All-call reply with nothing past the address.
+	res = ParseMessageWithType("MSG,8,1,1,4CA2D6,1")
+	if !res.Valid || res.MessageType != 8 || res.Aircraft.ICAO != "4CA2D6" {
+		t.Fatalf("expected valid type 8 message for 4CA2D6, got %+v", res)
+	}
+
+	for _, line := range []string{"MSG,3,1,1", "MSG,3,1,1,", ""} {
+		if res := ParseMessageWithType(line); res.Valid {
+			t.Fatalf("%q: expected invalid message", line)
+		}
+		if ac := ParseMessage(line); ac != nil {
+			t.Fatalf("%q: expected nil aircraft", line)
+		}
+	}
+}
+
+func TestSanitizeCallsign(t *testing.T) {
+	cases := map[string]string{
+		"RYR1234 ":      "RYR1234",
+		"  BAW12  ":     "BAW12",
+		"EZY12A__":      "EZY12A",
+		"DLH4\x00\x1bX": "DLH4X",
+		"n123ab":        "N123AB",
+		"____":          "",
+		"":              "",
+	}
+	for in, want := range cases {
+		if got := sanitizeCallsign(in); got != want {
+			t.Fatalf("%q: expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestParseRecordTypes(t *testing.T) {
+	for _, line := range []string{
+		"ID,,5,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123,RYR1234",
+		"SEL,,5,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123,RYR1234",
+	} {
+		res := ParseMessageWithType(line)
+		if !res.Valid || res.Remove || res.Aircraft == nil {
+			t.Fatalf("%s: expected valid update, got %+v", line, res)
+		}
+		if res.Aircraft.Callsign != "RYR1234" {
+			t.Fatalf("%s: expected callsign RYR1234, got %q", line, res.Aircraft.Callsign)
+		}
+	}
+
+	res := ParseMessageWithType("AIR,,5,1,4ca2d6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123")
+	if !res.Valid || res.Record != RecordAIR || res.Aircraft == nil || res.Aircraft.ICAO != "4CA2D6" {
+		t.Fatalf("expected AIR update for 4CA2D6, got %+v", res)
+	}
+
+	for _, status := range []string{"RM", "AD"} {
+		res := ParseMessageWithType("STA,,5,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123," + status)
+		if !res.Valid || !res.Remove || res.Aircraft == nil || res.Aircraft.ICAO != "4CA2D6" {
+			t.Fatalf("%s: expected removal of 4CA2D6, got %+v", status, res)
+		}
+	}
+	for _, status := range []string{"PL", "SL", "OK", ""} {
+		res := ParseMessageWithType("STA,,5,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123," + status)
+		if !res.Valid || res.Remove || res.Aircraft != nil {
+			t.Fatalf("%s: expected no action, got %+v", status, res)
+		}
+	}
+
+	if res := ParseMessageWithType("CLK,,5,1,4CA2D6,1,2024/01/15,10:30:00.123"); res.Valid {
+		t.Fatalf("expected unknown record to be invalid, got %+v", res)
+	}
+}
+
+func TestParseMessageTypes(t *testing.T) {
+	const prefix = ",1,1,4CA2D6,1,2024/01/15,10:30:00.123,2024/01/15,10:30:00.123,"
+	cases := []struct {
+		line  string
+		check func(ac *models.Aircraft) bool
+	}{
+		{"MSG,1" + prefix + "RYR1234 ,,,,,,,,0,0,0,0", func(ac *models.Aircraft) bool {
+			return ac.Callsign == "RYR1234"
+		}},
+		{"MSG,2" + prefix + ",0,12,270.0,51.47000,-0.45000,,,0,0,0,-1", func(ac *models.Aircraft) bool {
+			return ac.Lat != nil && *ac.Lat == 51.47 && ac.SpeedKt != nil && *ac.SpeedKt == 12 &&
+				ac.OnGround != nil && *ac.OnGround
+		}},
+		{"MSG,3" + prefix + ",35000,,,51.50000,-0.10000,,,0,0,0,0", func(ac *models.Aircraft) bool {
+			return ac.AltitudeFt != nil && *ac.AltitudeFt == 35000 && ac.Lon != nil && *ac.Lon == -0.1
+		}},
+		{"MSG,4" + prefix + ",,450,90.0,,,-640,,0,0,0,0", func(ac *models.Aircraft) bool {
+			return ac.SpeedKt != nil && *ac.SpeedKt == 450 && ac.Heading != nil && *ac.Heading == 90 &&
+				ac.VerticalRate != nil && *ac.VerticalRate == -640
+		}},
+		{"MSG,5" + prefix + ",35000,,,,,,,0,,0,0", func(ac *models.Aircraft) bool {
+			return ac.AltitudeFt != nil && *ac.AltitudeFt == 35000
+		}},
+		{"MSG,6" + prefix + ",,,,,,,1234,0,0,0,0", func(ac *models.Aircraft) bool {
+			return ac.Squawk == "1234"
+		}},
+		{"MSG,7" + prefix + ",35000,,,,,,,,,,0", func(ac *models.Aircraft) bool {
+			return ac.AltitudeFt != nil && *ac.AltitudeFt == 35000 && ac.OnGround != nil && !*ac.OnGround
+		}},
+		{"MSG,8" + prefix + ",,,,,,,,,,,0", func(ac *models.Aircraft) bool {
+			return ac.OnGround != nil && !*ac.OnGround
+		}},
+	}
+	for i, tc := range cases {
+		res := ParseMessageWithType(tc.line)
+		if !res.Valid || res.Record != RecordMSG || res.MessageType != i+1 {
+			t.Fatalf("%s: expected valid MSG type %d, got %+v", tc.line, i+1, res)
+		}
+		if res.Aircraft == nil || res.Aircraft.ICAO != "4CA2D6" || !tc.check(res.Aircraft) {
+			t.Fatalf("%s: unexpected aircraft %+v", tc.line, res.Aircraft)
+		}
+		if ac := ParseMessage(tc.line); ac == nil || ac.ICAO != "4CA2D6" {
+			t.Fatalf("%s: expected ParseMessage to match", tc.line)
+		}
+	}
+}
@@ -2,18 +2,26 @@ package tracker
 
 import (
 	"context"
-	"log"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"adsb-tracker/internal/logging"
+	"adsb-tracker/internal/lookup"
+	"adsb-tracker/internal/webhook"
 	"adsb-tracker/pkg/models"
 )
 
+var logger = logging.Component("tracker")
+
 const (
 	defaultPersistenceWorkers  = 4
 	defaultPersistenceQueueLen = 512
+	defaultDrainTimeout        = 10 * time.Second
 	defaultFAAQueueLen         = 256
+	subscriberBufferLen        = 100
+	subscriberMaxStall         = 30 * time.Second
 )
 
 type persistenceKind int
@@ -46,6 +54,23 @@ type AircraftEvent struct {
 	Aircraft models.Aircraft
 }
 
+type subscriber struct {
+	id        uint64
+	ch        chan AircraftEvent
+	created   time.Time
+	dropped   atomic.Uint64
+	fullSince atomic.Int64
+}
+
+type SubscriberStats struct {
+	ID            uint64     `json:"id"`
+	Subscribed    time.Time  `json:"subscribed"`
+	Queued        int        `json:"queued"`
+	Capacity      int        `json:"capacity"`
+	DroppedEvents uint64     `json:"dropped_events"`
+	FullSince     *time.Time `json:"full_since,omitempty"`
+}
+
 type Repository interface {
 	SaveAircraft(ac *models.Aircraft) error
 	SavePosition(ac *models.Aircraft) error
@@ -61,11 +86,18 @@ type Tracker struct {
 	aircraft   map[string]*models.Aircraft
 	staleAfter time.Duration
 	rxLocation *models.ReceiverLocation
+	units      models.DistanceUnit
 
 	maxRangeNM   float64
 	maxRangeICAO string
 	totalSeen    int
 	trailLength  int
+	maxAircraft  int
+	evicted      int
+	// evictedAt remembers when each evicted aircraft was last seen, until
+	// it would have gone stale, so one that is still transmitting isn't
+	// counted or alerted as new when it returns.
+	evictedAt map[string]time.Time
 
 	repo          Repository
 	faaLookup     FAALookup
@@ -75,6 +107,10 @@ type Tracker struct {
 
 	persistCh      chan persistenceTask
 	persistWorkers int
+	drainTimeout   time.Duration
+	// persistMu orders queueing against shutdown, so persistCh is only
+	// closed once nothing can send on it.
+	persistMu sync.RWMutex
 
 	faaLookupCh  chan string
 	faaPending   map[string]struct{}
@@ -82,8 +118,10 @@ type Tracker struct {
 
 	shutdown atomic.Bool
 
-	eventsMu    sync.RWMutex
-	subscribers []chan AircraftEvent
+	eventsMu         sync.RWMutex
+	subscribers      []*subscriber
+	nextSubscriberID uint64
+	evictedSubs      atomic.Uint64
 }
 
 type Stats struct {
@@ -91,12 +129,14 @@ type Stats struct {
 	TotalSeen     int     `json:"total_seen"`
 	MaxRangeNM    float64 `json:"max_range_nm"`
 	MaxRangeICAO  string  `json:"max_range_icao,omitempty"`
+	Evicted       int     `json:"evicted"`
 }
 
 type SearchFilters struct {
 	Callsign     string
 	AircraftType string
 	Registration string
+	Category     string
 	MinLat       float64
 	MinLon       float64
 	MaxLat       float64
@@ -105,15 +145,16 @@ type SearchFilters struct {
 }
 
 type WebhookDispatcher interface {
-	SendEmergency(ac *models.Aircraft)
-	SendWatchlistMatch(ac *models.Aircraft, pattern string)
+	UpdateEmergency(ac *models.Aircraft)
+	SendWatchlistMatch(ac *models.Aircraft, match webhook.WatchlistMatch)
 	SendNewAircraft(ac *models.Aircraft)
-	CheckWatchlist(ac *models.Aircraft) (bool, string)
+	CheckWatchlist(ac *models.Aircraft) (webhook.WatchlistMatch, bool)
 	IsEmergencySquawk(squawk string) bool
+	SendMaxRange(ac *models.Aircraft)
 }
 
 type RangeTracker interface {
-	Record(bearing, distanceNM float64, icao string)
+	Record(bearing, distanceNM float64, altFt *int, icao string) bool
 }
 
 type FlightTracker interface {
@@ -123,9 +164,12 @@ type FlightTracker interface {
 
 type Options struct {
 	StaleAfter           time.Duration
+	HasRxLocation        bool
 	RxLat                float64
 	RxLon                float64
+	Units                models.DistanceUnit
 	TrailLength          int
+	MaxAircraft          int
 	Repo                 Repository
 	FAALookup            FAALookup
 	Webhooks             WebhookDispatcher
@@ -133,6 +177,9 @@ type Options struct {
 	FlightTracker        FlightTracker
 	PersistenceWorkers   int
 	PersistenceQueueSize int
+	// DrainTimeout bounds how long Run spends saving queued aircraft and
+	// positions after its context is cancelled.
+	DrainTimeout time.Duration
 }
 
 func New(opts Options) *Tracker {
@@ -142,6 +189,9 @@ func New(opts Options) *Tracker {
 	if opts.PersistenceQueueSize <= 0 {
 		opts.PersistenceQueueSize = defaultPersistenceQueueLen
 	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = defaultDrainTimeout
+	}
 
 	t := &Tracker{
 		aircraft:       make(map[string]*models.Aircraft),
@@ -153,7 +203,11 @@ func New(opts Options) *Tracker {
 		rangeTracker:   opts.RangeTracker,
 		flightTracker:  opts.FlightTracker,
 		persistWorkers: opts.PersistenceWorkers,
+		drainTimeout:   opts.DrainTimeout,
 		faaPending:     make(map[string]struct{}),
+		evictedAt:      make(map[string]time.Time),
+		units:          opts.Units,
+		maxAircraft:    opts.MaxAircraft,
 	}
 	if t.repo != nil {
 		t.persistCh = make(chan persistenceTask, opts.PersistenceQueueSize)
@@ -164,17 +218,22 @@ func New(opts Options) *Tracker {
 	if t.trailLength == 0 {
 		t.trailLength = 50
 	}
-	if opts.RxLat != 0 || opts.RxLon != 0 {
-		t.rxLocation = &models.ReceiverLocation{Lat: opts.RxLat, Lon: opts.RxLon}
-		log.Printf("[TRACKER] Receiver location: %.4f, %.4f", opts.RxLat, opts.RxLon)
+	if opts.HasRxLocation {
+		t.rxLocation = &models.ReceiverLocation{Lat: opts.RxLat, Lon: opts.RxLon, Units: opts.Units}
+		logger.Info("receiver location", "lat", opts.RxLat, "lon", opts.RxLon)
 	}
 	return t
 }
 
 func (t *Tracker) Subscribe() chan AircraftEvent {
-	ch := make(chan AircraftEvent, 100)
+	ch := make(chan AircraftEvent, subscriberBufferLen)
 	t.eventsMu.Lock()
-	t.subscribers = append(t.subscribers, ch)
+	t.nextSubscriberID++
+	t.subscribers = append(t.subscribers, &subscriber{
+		id:      t.nextSubscriberID,
+		ch:      ch,
+		created: time.Now().UTC(),
+	})
 	t.eventsMu.Unlock()
 	return ch
 }
@@ -182,27 +241,90 @@ func (t *Tracker) Subscribe() chan AircraftEvent {
 func (t *Tracker) Unsubscribe(ch chan AircraftEvent) {
 	t.eventsMu.Lock()
 	defer t.eventsMu.Unlock()
+	t.removeSubscriberLocked(ch)
+}
+
+func (t *Tracker) removeSubscriberLocked(ch chan AircraftEvent) bool {
 	for i, sub := range t.subscribers {
-		if sub == ch {
+		if sub.ch == ch {
 			t.subscribers = append(t.subscribers[:i], t.subscribers[i+1:]...)
 			close(ch)
-			return
+			return true
 		}
 	}
+	return false
 }
 
 func (t *Tracker) broadcast(event AircraftEvent) {
+	now := time.Now()
+	var stalled []*subscriber
+
 	t.eventsMu.RLock()
-	defer t.eventsMu.RUnlock()
-	for _, ch := range t.subscribers {
+	for _, sub := range t.subscribers {
 		select {
-		case ch <- event:
+		case sub.ch <- event:
+			sub.fullSince.Store(0)
 		default:
+			sub.dropped.Add(1)
+			since := sub.fullSince.Load()
+			if since == 0 {
+				sub.fullSince.CompareAndSwap(0, now.UnixNano())
+			} else if now.Sub(time.Unix(0, since)) > subscriberMaxStall {
+				stalled = append(stalled, sub)
+			}
+		}
+	}
+	t.eventsMu.RUnlock()
+
+	if len(stalled) == 0 {
+		return
+	}
+
+	t.eventsMu.Lock()
+	for _, sub := range stalled {
+		if t.removeSubscriberLocked(sub.ch) {
+			t.evictedSubs.Add(1)
+			logger.Warn("subscriber stalled, unsubscribing", "subscriber", sub.id,
+				"stalled_for", subscriberMaxStall, "dropped", sub.dropped.Load())
 		}
 	}
+	t.eventsMu.Unlock()
 }
 
+func (t *Tracker) SubscriberStats() []SubscriberStats {
+	t.eventsMu.RLock()
+	defer t.eventsMu.RUnlock()
+
+	stats := make([]SubscriberStats, 0, len(t.subscribers))
+	for _, sub := range t.subscribers {
+		s := SubscriberStats{
+			ID:            sub.id,
+			Subscribed:    sub.created,
+			Queued:        len(sub.ch),
+			Capacity:      cap(sub.ch),
+			DroppedEvents: sub.dropped.Load(),
+		}
+		if since := sub.fullSince.Load(); since != 0 {
+			fullSince := time.Unix(0, since).UTC()
+			s.FullSince = &fullSince
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func (t *Tracker) EvictedSubscribers() uint64 {
+	return t.evictedSubs.Load()
+}
+
+// Update merges an update whose origin is unknown.
 func (t *Tracker) Update(update *models.Aircraft) {
+	t.UpdateFrom(update, models.Origin{})
+}
+
+// UpdateFrom merges an update and records origin as the provenance of any
+// position or velocity it carries.
+func (t *Tracker) UpdateFrom(update *models.Aircraft, origin models.Origin) {
 	if update == nil || update.ICAO == "" {
 		return
 	}
@@ -223,9 +345,19 @@ func (t *Tracker) Update(update *models.Aircraft) {
 	existing, ok := t.aircraft[update.ICAO]
 	if !ok {
 		ac := update.Copy()
+		ac.RecordProvenance(update, origin)
+		if country, ok := lookup.CountryForICAO(ac.ICAO); ok {
+			ac.Country = country.Name
+			ac.CountryCode = country.Code
+		}
 		ac.CalculateDistance(t.rxLocation)
 		t.aircraft[update.ICAO] = &ac
-		t.totalSeen++
+		_, returning := t.evictedAt[ac.ICAO]
+		delete(t.evictedAt, ac.ICAO)
+		if !returning {
+			t.totalSeen++
+		}
+		t.evictLocked(ac.ICAO)
 		t.updateMaxRange(&ac)
 
 		snapshot := ac.Copy()
@@ -233,13 +365,14 @@ func (t *Tracker) Update(update *models.Aircraft) {
 		flightUpdates = append(flightUpdates, snapshot)
 		saveAircraft = append(saveAircraft, snapshot)
 		events = append(events, AircraftEvent{Type: EventAdd, Aircraft: snapshot})
-		webhookUpdates = append(webhookUpdates, webhookRequest{aircraft: snapshot, isNew: true})
+		webhookUpdates = append(webhookUpdates, webhookRequest{aircraft: snapshot, isNew: !returning})
 		if t.needsFAAEnrichment(&ac) {
 			faaRequests = append(faaRequests, ac.ICAO)
 		}
 		newICAO = ac.ICAO
 	} else {
 		oldSquawk := existing.Squawk
+		oldEmergency := existing.EmergencyFlagged()
 		oldLat := existing.Lat
 		oldLon := existing.Lon
 		oldAlt := existing.AltitudeFt
@@ -252,7 +385,7 @@ func (t *Tracker) Update(update *models.Aircraft) {
 			update.Lon = nil
 		}
 
-		existing.Merge(update)
+		existing.MergeFrom(update, origin)
 		existing.CalculateDistance(t.rxLocation)
 		t.updateMaxRange(existing)
 
@@ -276,15 +409,18 @@ func (t *Tracker) Update(update *models.Aircraft) {
 			savePositions = append(savePositions, getSnapshot())
 		}
 
+		emergencyChanged := existing.EmergencyFlagged() != oldEmergency
+
 		if posChanged ||
 			hasIntChanged(oldAlt, existing.AltitudeFt) ||
 			hasStateChanged(oldSpd, existing.SpeedKt) ||
-			hasStateChanged(oldHdg, existing.Heading) {
+			hasStateChanged(oldHdg, existing.Heading) ||
+			emergencyChanged {
 			saveAircraft = append(saveAircraft, getSnapshot())
 			events = append(events, AircraftEvent{Type: EventUpdate, Aircraft: getSnapshot()})
 		}
 
-		if existing.Squawk != oldSquawk {
+		if existing.Squawk != oldSquawk || emergencyChanged {
 			webhookUpdates = append(webhookUpdates, webhookRequest{aircraft: getSnapshot(), isNew: false})
 		}
 
@@ -327,7 +463,7 @@ func (t *Tracker) Update(update *models.Aircraft) {
 	}
 
 	if newICAO != "" {
-		log.Printf("[TRACKER] Aircraft added: %s", newICAO)
+		logger.Debug("aircraft added", "icao", newICAO)
 	}
 }
 
@@ -343,18 +479,29 @@ func (t *Tracker) checkWebhookEvents(ac *models.Aircraft, isNew bool) {
 	}
 
 	if ac.Squawk != "" && t.webhooks.IsEmergencySquawk(ac.Squawk) {
-		log.Printf("[TRACKER] Emergency squawk detected: %s squawking %s", ac.ICAO, ac.Squawk)
-		go t.webhooks.SendEmergency(&acCopy)
+		logger.Warn("emergency squawk detected", "icao", ac.ICAO, "squawk", ac.Squawk)
+	} else if ac.EmergencyFlagged() {
+		logger.Warn("emergency flag set", "icao", ac.ICAO, "squawk", ac.Squawk)
 	}
+	// Called in order, not in a goroutine, so set and clear are seen in
+	// the sequence they happened.
+	t.webhooks.UpdateEmergency(&acCopy)
+
+	t.checkWatchlist(&acCopy)
+}
 
-	if matched, pattern := t.webhooks.CheckWatchlist(&acCopy); matched {
-		log.Printf("[TRACKER] Watchlist match: %s matched pattern %s", ac.ICAO, pattern)
-		go t.webhooks.SendWatchlistMatch(&acCopy, pattern)
+func (t *Tracker) checkWatchlist(ac *models.Aircraft) {
+	if t.webhooks == nil {
+		return
+	}
+	if match, ok := t.webhooks.CheckWatchlist(ac); ok {
+		logger.Info("watchlist match", "icao", ac.ICAO, "group", match.Group, "pattern", match.Pattern)
+		go t.webhooks.SendWatchlistMatch(ac, match)
 	}
 }
 
 func (t *Tracker) needsFAAEnrichment(ac *models.Aircraft) bool {
-	if t.faaLookup == nil {
+	if t.faaLookup == nil || strings.HasPrefix(ac.ICAO, models.NonICAOPrefix) {
 		return false
 	}
 	return ac.Registration == "" || ac.AircraftType == "" || ac.Operator == ""
@@ -405,7 +552,7 @@ func (t *Tracker) scheduleFAAEnrichment(icao string) {
 		t.faaPendingMu.Lock()
 		delete(t.faaPending, icao)
 		t.faaPendingMu.Unlock()
-		log.Printf("[TRACKER] FAA lookup queue full, dropping request for %s", icao)
+		logger.Debug("FAA lookup queue full, dropping request", "icao", icao)
 	}
 }
 
@@ -445,30 +592,53 @@ func (t *Tracker) applyFAAInfo(icao string, info *models.FAAInfo) {
 		t.queueSaveAircraft(snapshot)
 		t.dispatchFlightUpdate(snapshot)
 		t.broadcast(AircraftEvent{Type: EventUpdate, Aircraft: snapshot})
+
+		acCopy := snapshot.Copy()
+		t.checkWatchlist(&acCopy)
 	}
 }
 
 func (t *Tracker) queueSaveAircraft(ac models.Aircraft) {
-	if t.persistCh == nil || t.shutdown.Load() {
-		return
+	if !t.queuePersistence(persistenceTask{kind: persistAircraft, aircraft: ac}) {
+		logger.Warn("persistence queue full, dropping aircraft save", "icao", ac.ICAO)
+	}
+}
+
+func (t *Tracker) queueSavePosition(ac models.Aircraft) {
+	if !t.queuePersistence(persistenceTask{kind: persistPosition, aircraft: ac}) {
+		logger.Warn("persistence queue full, dropping position save", "icao", ac.ICAO)
+	}
+}
+
+// queuePersistence queues task unless the queue is full. Tasks are silently
+// ignored without a repository or once shutdown has begun.
+func (t *Tracker) queuePersistence(task persistenceTask) bool {
+	if t.persistCh == nil {
+		return true
+	}
+	t.persistMu.RLock()
+	defer t.persistMu.RUnlock()
+	if t.shutdown.Load() {
+		return true
 	}
-	task := persistenceTask{kind: persistAircraft, aircraft: ac}
 	select {
 	case t.persistCh <- task:
+		return true
 	default:
-		log.Printf("[TRACKER] Persistence queue full, dropping aircraft save for %s", ac.ICAO)
+		return false
 	}
 }
 
-func (t *Tracker) queueSavePosition(ac models.Aircraft) {
-	if t.persistCh == nil || t.shutdown.Load() {
+// stopPersistence refuses further tasks and closes the queue, so workers
+// exit once they have saved what is already queued.
+func (t *Tracker) stopPersistence() {
+	t.persistMu.Lock()
+	defer t.persistMu.Unlock()
+	if t.shutdown.Swap(true) {
 		return
 	}
-	task := persistenceTask{kind: persistPosition, aircraft: ac}
-	select {
-	case t.persistCh <- task:
-	default:
-		log.Printf("[TRACKER] Persistence queue full, dropping position save for %s", ac.ICAO)
+	if t.persistCh != nil {
+		close(t.persistCh)
 	}
 }
 
@@ -481,25 +651,32 @@ func (t *Tracker) handlePersistenceTask(task persistenceTask) {
 	switch task.kind {
 	case persistAircraft:
 		if err := t.repo.SaveAircraft(&ac); err != nil {
-			log.Printf("[TRACKER] Failed to save aircraft %s: %v", ac.ICAO, err)
+			logger.Error("failed to save aircraft", "icao", ac.ICAO, "error", err)
 		}
 	case persistPosition:
 		if err := t.repo.SavePosition(&ac); err != nil {
-			log.Printf("[TRACKER] Failed to save position for %s: %v", ac.ICAO, err)
+			logger.Error("failed to save position", "icao", ac.ICAO, "error", err)
 		}
 	}
 }
 
-func (t *Tracker) runPersistenceWorker(ctx context.Context, wg *sync.WaitGroup) {
+// runPersistenceWorker saves queued tasks until the queue is closed and
+// empty, or abandon is closed.
+func (t *Tracker) runPersistenceWorker(abandon <-chan struct{}, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for {
 		select {
-		case <-ctx.Done():
+		case <-abandon:
 			return
 		case task, ok := <-t.persistCh:
 			if !ok {
 				return
 			}
+			select {
+			case <-abandon:
+				return
+			default:
+			}
 			t.handlePersistenceTask(task)
 		}
 	}
@@ -570,8 +747,8 @@ func (t *Tracker) isPositionValid(existing *models.Aircraft, update *models.Airc
 	}
 
 	if dist > maxDistNM {
-		log.Printf("[TRACKER] Position jump rejected for %s: %.1f NM in %.1fs (max %.1f NM)",
-			update.ICAO, dist, elapsed, maxDistNM)
+		logger.Debug("position jump rejected", "icao", update.ICAO, "distance_nm", dist,
+			"elapsed_s", elapsed, "max_nm", maxDistNM)
 		return false
 	}
 
@@ -604,7 +781,7 @@ func (t *Tracker) updateMaxRange(ac *models.Aircraft) {
 	if ac.DistanceNM != nil && *ac.DistanceNM > t.maxRangeNM {
 		t.maxRangeNM = *ac.DistanceNM
 		t.maxRangeICAO = ac.ICAO
-		log.Printf("[TRACKER] New max range: %.1f NM (%s)", t.maxRangeNM, ac.ICAO)
+		logger.Info("new max range", "range_nm", t.maxRangeNM, "icao", ac.ICAO)
 	}
 }
 
@@ -612,8 +789,13 @@ func (t *Tracker) recordRange(ac *models.Aircraft) {
 	if t.rangeTracker == nil {
 		return
 	}
-	if ac.Bearing != nil && ac.DistanceNM != nil {
-		t.rangeTracker.Record(*ac.Bearing, *ac.DistanceNM, ac.ICAO)
+	if ac.Bearing == nil || ac.DistanceNM == nil {
+		return
+	}
+	if t.rangeTracker.Record(*ac.Bearing, *ac.DistanceNM, ac.AltitudeFt, ac.ICAO) && t.webhooks != nil {
+		logger.Info("new all-time max range", "range_nm", *ac.DistanceNM, "icao", ac.ICAO)
+		acCopy := ac.Copy()
+		go t.webhooks.SendMaxRange(&acCopy)
 	}
 }
 
@@ -633,6 +815,7 @@ func (t *Tracker) GetStats() Stats {
 		TotalSeen:     t.totalSeen,
 		MaxRangeNM:    t.maxRangeNM,
 		MaxRangeICAO:  t.maxRangeICAO,
+		Evicted:       t.evicted,
 	}
 }
 
@@ -673,8 +856,36 @@ func (t *Tracker) GetAll() []models.Aircraft {
 	return result
 }
 
+// GetAllSummary is GetAll without trails, which are the bulk of each copy.
+func (t *Tracker) GetAllSummary() []models.Aircraft {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result := make([]models.Aircraft, 0, len(t.aircraft))
+	for _, ac := range t.aircraft {
+		result = append(result, ac.CopyWithoutTrail())
+	}
+	return result
+}
+
+// GetReceiverInfo returns a copy of the receiver location, or nil when none
+// is configured.
 func (t *Tracker) GetReceiverInfo() *models.ReceiverLocation {
-	return t.rxLocation
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.rxLocation == nil {
+		return nil
+	}
+	rx := *t.rxLocation
+	return &rx
+}
+
+// SetReceiverLocation moves the receiver, for a moving receiver or a config
+// reload. Distances are recalculated as each aircraft next updates. Callers
+// decoding Beast positions should move the feed's CPR reference too.
+func (t *Tracker) SetReceiverLocation(lat, lon float64) {
+	t.mu.Lock()
+	t.rxLocation = &models.ReceiverLocation{Lat: lat, Lon: lon, Units: t.units}
+	t.mu.Unlock()
 }
 
 func (t *Tracker) Search(filters SearchFilters) []models.Aircraft {
@@ -691,6 +902,27 @@ func (t *Tracker) Search(filters SearchFilters) []models.Aircraft {
 	return result
 }
 
+// CountMatching returns how many tracked aircraft match filters without
+// copying them.
+func (t *Tracker) CountMatching(filters SearchFilters) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	count := 0
+	for _, ac := range t.aircraft {
+		if matchesFilters(ac, filters) {
+			count++
+		}
+	}
+	return count
+}
+
+// Matches reports whether ac passes the filters, for aircraft that are not
+// tracked, such as ones read from the database.
+func (f SearchFilters) Matches(ac *models.Aircraft) bool {
+	return matchesFilters(ac, f)
+}
+
 func matchesFilters(ac *models.Aircraft, f SearchFilters) bool {
 	if f.Callsign != "" {
 		if ac.Callsign == "" || !containsIgnoreCase(ac.Callsign, f.Callsign) {
@@ -707,6 +939,11 @@ func matchesFilters(ac *models.Aircraft, f SearchFilters) bool {
 			return false
 		}
 	}
+	if f.Category != "" {
+		if !strings.HasPrefix(ac.EmitterCategory, strings.ToUpper(f.Category)) {
+			return false
+		}
+	}
 	if f.HasBounds {
 		if ac.Lat == nil || ac.Lon == nil {
 			return false
@@ -769,13 +1006,17 @@ func (t *Tracker) Count() int {
 	return len(t.aircraft)
 }
 
+// Run expires stale aircraft and runs the persistence and lookup workers
+// until ctx is cancelled. It then stops queueing saves and waits up to the
+// drain timeout for those already queued to be written.
 func (t *Tracker) Run(ctx context.Context) error {
 	var wg sync.WaitGroup
+	abandon := make(chan struct{})
 
 	if t.persistCh != nil {
 		for i := 0; i < t.persistWorkers; i++ {
 			wg.Add(1)
-			go t.runPersistenceWorker(ctx, &wg)
+			go t.runPersistenceWorker(abandon, &wg)
 		}
 	}
 
@@ -790,8 +1031,8 @@ func (t *Tracker) Run(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
-			t.shutdown.Store(true)
-			wg.Wait()
+			t.stopPersistence()
+			t.drain(&wg, abandon)
 			return ctx.Err()
 		case <-cleanupTicker.C:
 			t.cleanupStale()
@@ -799,6 +1040,69 @@ func (t *Tracker) Run(ctx context.Context) error {
 	}
 }
 
+// drain waits for the workers, abandoning queued saves left after the drain
+// timeout.
+func (t *Tracker) drain(wg *sync.WaitGroup, abandon chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	if queued := len(t.persistCh); queued > 0 {
+		logger.Info("saving queued aircraft and positions", "queued", queued)
+	}
+
+	timer := time.NewTimer(t.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		close(abandon)
+		<-done
+		logger.Warn("persistence drain timed out", "timeout", t.drainTimeout, "abandoned", len(t.persistCh))
+	}
+}
+
+// SetStaleAfter changes how long an aircraft may go unheard before it is
+// removed.
+func (t *Tracker) SetStaleAfter(d time.Duration) {
+	t.mu.Lock()
+	t.staleAfter = d
+	t.mu.Unlock()
+}
+
+// SetMaxAircraft changes the cap on tracked aircraft; past it the least
+// recently seen aircraft is dropped early. 0 means no cap. A lower cap is
+// applied as new aircraft arrive.
+func (t *Tracker) SetMaxAircraft(n int) {
+	t.mu.Lock()
+	t.maxAircraft = n
+	t.mu.Unlock()
+}
+
+// evictLocked drops the least recently seen aircraft, other than keep, until
+// the map is back under the cap. Their flights are left open until they
+// would have gone stale, so one that returns carries on the same flight.
+func (t *Tracker) evictLocked(keep string) {
+	for t.maxAircraft > 0 && len(t.aircraft) > t.maxAircraft {
+		var oldestICAO string
+		var oldest *models.Aircraft
+		for icao, ac := range t.aircraft {
+			if icao != keep && (oldest == nil || ac.LastSeen.Before(oldest.LastSeen)) {
+				oldestICAO, oldest = icao, ac
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		logger.Debug("aircraft removed", "icao", oldestICAO, "reason", "evicted")
+		t.dropLocked(oldestICAO, oldest)
+		t.evictedAt[oldestICAO] = oldest.LastSeen
+		t.evicted++
+	}
+}
+
 func (t *Tracker) cleanupStale() {
 	now := time.Now().UTC()
 	var toRemove []string
@@ -809,9 +1113,10 @@ func (t *Tracker) cleanupStale() {
 			toRemove = append(toRemove, icao)
 		}
 	}
+	evicted := len(t.evictedAt)
 	t.mu.RUnlock()
 
-	if len(toRemove) == 0 {
+	if len(toRemove) == 0 && evicted == 0 {
 		return
 	}
 
@@ -819,16 +1124,48 @@ func (t *Tracker) cleanupStale() {
 	for _, icao := range toRemove {
 		if ac, ok := t.aircraft[icao]; ok {
 			if now.Sub(ac.LastSeen) > t.staleAfter {
-				log.Printf("[TRACKER] Aircraft removed (stale): %s", icao)
-				acCopy := ac.Copy()
-				delete(t.aircraft, icao)
-				t.broadcast(AircraftEvent{Type: EventRemove, Aircraft: acCopy})
-
-				if t.flightTracker != nil {
-					go t.flightTracker.CompleteStaleFlight(icao)
-				}
+				logger.Debug("aircraft removed", "icao", icao, "reason", "stale")
+				t.removeLocked(icao, ac)
+			}
+		}
+	}
+	for icao, lastSeen := range t.evictedAt {
+		if now.Sub(lastSeen) > t.staleAfter {
+			delete(t.evictedAt, icao)
+			if t.flightTracker != nil {
+				go t.flightTracker.CompleteStaleFlight(icao)
 			}
 		}
 	}
 	t.mu.Unlock()
 }
+
+// Expire removes an aircraft the feed reports as gone, unless it has been
+// heard since at. It reports whether the aircraft was removed.
+func (t *Tracker) Expire(icao string, at time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ac, ok := t.aircraft[icao]
+	if !ok || ac.LastSeen.After(at) {
+		return false
+	}
+	logger.Debug("aircraft removed", "icao", icao, "reason", "feed")
+	t.removeLocked(icao, ac)
+	return true
+}
+
+func (t *Tracker) removeLocked(icao string, ac *models.Aircraft) {
+	t.dropLocked(icao, ac)
+
+	if t.flightTracker != nil {
+		go t.flightTracker.CompleteStaleFlight(icao)
+	}
+}
+
+// dropLocked stops tracking an aircraft without ending its flight.
+func (t *Tracker) dropLocked(icao string, ac *models.Aircraft) {
+	acCopy := ac.Copy()
+	delete(t.aircraft, icao)
+	t.broadcast(AircraftEvent{Type: EventRemove, Aircraft: acCopy})
+}
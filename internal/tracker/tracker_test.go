@@ -0,0 +1,234 @@
+package tracker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"adsb-tracker/pkg/models"
+)
+
+// blockingRepo counts saved positions. Saves wait until release is closed,
+// and then take delay each.
+type blockingRepo struct {
+	release chan struct{}
+	delay   time.Duration
+
+	mu    sync.Mutex
+	saved int
+}
+
+func (r *blockingRepo) SaveAircraft(*models.Aircraft) error { return nil }
+
+func (r *blockingRepo) SavePosition(*models.Aircraft) error {
+	<-r.release
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	r.saved++
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *blockingRepo) GetPositionHistory(string, int) ([]models.Position, error) {
+	return nil, nil
+}
+
+func (r *blockingRepo) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saved
+}
+
+func runTracker(t *testing.T, trk *Tracker) (context.CancelFunc, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- trk.Run(ctx) }()
+	return cancel, done
+}
+
+func TestShutdownFlushesQueuedPositions(t *testing.T) {
+	repo := &blockingRepo{release: make(chan struct{})}
+	trk := New(Options{Repo: repo, PersistenceWorkers: 2, PersistenceQueueSize: 64})
+	cancel, done := runTracker(t, trk)
+
+	const queued = 20
+	for i := 0; i < queued; i++ {
+		trk.queueSavePosition(models.Aircraft{ICAO: "ABC123"})
+	}
+	cancel()
+	// Saves are still blocked, so shutdown starts with work in the queue.
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if got := repo.count(); got != queued {
+		t.Fatalf("expected %d positions saved on shutdown, got %d", queued, got)
+	}
+
+	// Saves queued after shutdown are ignored rather than sent on the
+	// closed queue.
+	trk.queueSavePosition(models.Aircraft{ICAO: "ABC123"})
+}
+
+func TestShutdownDrainIsBounded(t *testing.T) {
+	repo := &blockingRepo{release: make(chan struct{}), delay: 50 * time.Millisecond}
+	close(repo.release)
+	trk := New(Options{Repo: repo, PersistenceWorkers: 1, PersistenceQueueSize: 64, DrainTimeout: 100 * time.Millisecond})
+
+	for i := 0; i < 40; i++ {
+		trk.queueSavePosition(models.Aircraft{ICAO: "ABC123"})
+	}
+	cancel, done := runTracker(t, trk)
+	cancel()
+
+	start := time.Now()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected drain to stop near its timeout, took %v", elapsed)
+	}
+	if got := repo.count(); got == 0 || got == 40 {
+		t.Fatalf("expected some but not all positions saved, got %d", got)
+	}
+}
+
+func TestSetReceiverLocationWhileUpdating(t *testing.T) {
+	trk := New(Options{RxLat: 51.47, RxLon: -0.45, Units: models.UnitNM})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			trk.SetReceiverLocation(51+float64(i%10)/10, -0.45)
+			trk.GetReceiverInfo()
+		}
+	}()
+
+	for i := 0; i < 500; i++ {
+		lat, lon := 52.0+float64(i)/1000, -0.45
+		trk.Update(&models.Aircraft{ICAO: "ABC123", Lat: &lat, Lon: &lon, LastSeen: time.Now()})
+	}
+	close(done)
+	wg.Wait()
+
+	trk.SetReceiverLocation(52.0, -0.45)
+	lat, lon := 53.0, -0.45
+	trk.Update(&models.Aircraft{ICAO: "DEF456", Lat: &lat, Lon: &lon, LastSeen: time.Now()})
+	ac, ok := trk.Get("DEF456")
+	if !ok || ac.DistanceNM == nil || *ac.DistanceNM != 60 {
+		t.Fatalf("expected 60 NM from the moved receiver, got %+v", ac.DistanceNM)
+	}
+	if rx := trk.GetReceiverInfo(); rx.Lat != 52.0 || rx.Units != models.UnitNM {
+		t.Fatalf("unexpected receiver location %+v", rx)
+	}
+}
+
+func TestReceiverAtNullIsland(t *testing.T) {
+	lat, lon := 1.0, 0.0
+
+	trk := New(Options{})
+	trk.Update(&models.Aircraft{ICAO: "ABC123", Lat: &lat, Lon: &lon, LastSeen: time.Now()})
+	if ac, _ := trk.Get("ABC123"); ac.DistanceNM != nil {
+		t.Fatalf("expected no distance without a receiver location, got %v", *ac.DistanceNM)
+	}
+
+	trk = New(Options{HasRxLocation: true})
+	trk.Update(&models.Aircraft{ICAO: "ABC123", Lat: &lat, Lon: &lon, LastSeen: time.Now()})
+	if ac, _ := trk.Get("ABC123"); ac.DistanceNM == nil || *ac.DistanceNM != 60 {
+		t.Fatalf("expected 60 NM from a receiver at 0,0, got %v", ac.DistanceNM)
+	}
+}
+
+func TestMaxAircraftEvictsLeastRecentlySeen(t *testing.T) {
+	trk := New(Options{MaxAircraft: 2})
+	events := trk.Subscribe()
+	now := time.Now()
+
+	trk.Update(&models.Aircraft{ICAO: "AAA111", LastSeen: now.Add(-2 * time.Second)})
+	trk.Update(&models.Aircraft{ICAO: "BBB222", LastSeen: now.Add(-time.Second)})
+	trk.Update(&models.Aircraft{ICAO: "CCC333", LastSeen: now})
+
+	if _, ok := trk.Get("AAA111"); ok {
+		t.Fatal("expected the least recently seen aircraft to be evicted")
+	}
+	for _, icao := range []string{"BBB222", "CCC333"} {
+		if _, ok := trk.Get(icao); !ok {
+			t.Errorf("expected %s to be tracked", icao)
+		}
+	}
+	if stats := trk.GetStats(); stats.AircraftCount != 2 || stats.Evicted != 1 || stats.TotalSeen != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	var removed bool
+	for len(events) > 0 {
+		if evt := <-events; evt.Type == EventRemove && evt.Aircraft.ICAO == "AAA111" {
+			removed = true
+		}
+	}
+	if !removed {
+		t.Error("expected a remove event for the evicted aircraft")
+	}
+
+	trk.SetMaxAircraft(0)
+	trk.Update(&models.Aircraft{ICAO: "DDD444", LastSeen: now})
+	if stats := trk.GetStats(); stats.AircraftCount != 3 {
+		t.Fatalf("expected no cap after SetMaxAircraft(0), got %d aircraft", stats.AircraftCount)
+	}
+
+	trk.Update(&models.Aircraft{ICAO: "AAA111", LastSeen: now})
+	if stats := trk.GetStats(); stats.AircraftCount != 4 || stats.TotalSeen != 4 {
+		t.Fatalf("expected the returning aircraft not to count as new, got %+v", stats)
+	}
+}
+
+func TestEvictedMemoryExpires(t *testing.T) {
+	trk := New(Options{MaxAircraft: 1})
+	now := time.Now()
+	trk.Update(&models.Aircraft{ICAO: "AAA111", LastSeen: now.Add(-time.Minute)})
+	trk.Update(&models.Aircraft{ICAO: "BBB222", LastSeen: now})
+
+	trk.SetStaleAfter(30 * time.Second)
+	trk.cleanupStale()
+	trk.SetMaxAircraft(0)
+	trk.Update(&models.Aircraft{ICAO: "AAA111", LastSeen: now})
+	if stats := trk.GetStats(); stats.TotalSeen != 3 {
+		t.Fatalf("expected an aircraft evicted longer than the stale timeout ago to count as new, got %+v", stats)
+	}
+}
+
+func TestGetAllSummaryOmitsTrails(t *testing.T) {
+	trk := New(Options{TrailLength: 10})
+	for i := 0; i < 3; i++ {
+		lat, lon := 51.0+float64(i)/100, -0.45
+		trk.Update(&models.Aircraft{ICAO: "ABC123", Lat: &lat, Lon: &lon, LastSeen: time.Now()})
+	}
+
+	full := trk.GetAll()
+	if len(full) != 1 || len(full[0].Trail) == 0 {
+		t.Fatalf("expected GetAll to include the trail, got %+v", full)
+	}
+	summary := trk.GetAllSummary()
+	if len(summary) != 1 || summary[0].Trail != nil {
+		t.Fatalf("expected GetAllSummary to omit the trail, got %+v", summary)
+	}
+	if summary[0].Lat == nil || *summary[0].Lat != *full[0].Lat {
+		t.Fatal("expected GetAllSummary to keep the current position")
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+const defaultDigestInterval = time.Hour
+
+// DigestSource collects the activity recorded since the given time. The
+// dispatcher fills in the period and emergency count.
+type DigestSource func(since time.Time) (*DigestData, error)
+
+// RunDigest sends a digest of each interval's activity until ctx is
+// cancelled. Digests are only sent while the digest event is enabled.
+func (d *Dispatcher) RunDigest(ctx context.Context, interval time.Duration, source DigestSource) {
+	if interval <= 0 {
+		interval = defaultDigestInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.sendDigest(since, now, source)
+			since = now
+		}
+	}
+}
+
+func (d *Dispatcher) sendDigest(since, now time.Time, source DigestSource) {
+	d.mu.Lock()
+	emergencies := d.emergencyCount
+	d.emergencyCount = 0
+	d.mu.Unlock()
+
+	if !d.eventsConfig().Digest {
+		return
+	}
+
+	digest, err := source(since)
+	if err != nil {
+		logger.Error("failed to build digest", "error", err)
+		return
+	}
+	digest.Period = now.Sub(since)
+	digest.Emergencies = emergencies
+	d.Send(NewDigestEvent(digest, d.distanceUnit()))
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"adsb-tracker/internal/config"
+)
+
+func TestSendDigest(t *testing.T) {
+	d := NewDispatcher(config.WebhookConfig{
+		URL:    "https://example.com/hook",
+		Events: config.WebhookEventsConfig{EmergencySquawk: true, Digest: true},
+	})
+	d.UpdateEmergency(sampleAircraft("7700"))
+	<-d.events
+
+	var gotSince time.Time
+	source := func(since time.Time) (*DigestData, error) {
+		gotSince = since
+		return &DigestData{Aircraft: 142, BusiestType: "B738", BusiestTypeCount: 17, MaxRangeNM: 210}, nil
+	}
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	d.sendDigest(since, now, source)
+
+	if !gotSince.Equal(since) {
+		t.Errorf("expected source to be asked for activity since %v, got %v", since, gotSince)
+	}
+	select {
+	case event := <-d.events:
+		want := "In the last hour: 142 aircraft, busiest type B738, max range 210.0 NM, 1 emergency"
+		if event.Type != EventDigest || event.Message != want {
+			t.Fatalf("expected %q, got %s %q", want, event.Type, event.Message)
+		}
+	default:
+		t.Fatal("expected a digest event")
+	}
+
+	d.sendDigest(now, now.Add(time.Hour), source)
+	if event := <-d.events; event.Digest.Emergencies != 0 {
+		t.Errorf("expected emergency count to reset, got %d", event.Digest.Emergencies)
+	}
+}
+
+func TestFormatPeriod(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		time.Hour:                    "hour",
+		6 * time.Hour:                "6h",
+		90 * time.Minute:             "1h30m",
+		30*time.Minute + time.Second: "30m",
+		24 * time.Hour:               "24h",
+	} {
+		if got := formatPeriod(d); got != want {
+			t.Errorf("formatPeriod(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
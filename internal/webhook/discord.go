@@ -3,6 +3,8 @@ package webhook
 import (
 	"fmt"
 	"time"
+
+	"adsb-tracker/pkg/models"
 )
 
 const (
@@ -10,6 +12,11 @@ const (
 	ColorWatchlist = 0xFFAA00
 	ColorNew       = 0x00D4FF
 	ColorHealth    = 0xFF6B6B
+	ColorMaxRange  = 0x7CFC00
+	ColorFeedDown  = 0xFF8C00
+	ColorFeedUp    = 0x2ECC71
+	ColorFlight    = 0x9B59B6
+	ColorDigest    = 0x3498DB
 )
 
 type DiscordEmbed struct {
@@ -19,6 +26,7 @@ type DiscordEmbed struct {
 	Fields      []DiscordField `json:"fields,omitempty"`
 	Timestamp   string         `json:"timestamp,omitempty"`
 	Footer      *DiscordFooter `json:"footer,omitempty"`
+	Image       *DiscordImage  `json:"image,omitempty"`
 }
 
 type DiscordField struct {
@@ -31,6 +39,10 @@ type DiscordFooter struct {
 	Text string `json:"text"`
 }
 
+type DiscordImage struct {
+	URL string `json:"url"`
+}
+
 type DiscordMessage struct {
 	Username  string         `json:"username,omitempty"`
 	AvatarURL string         `json:"avatar_url,omitempty"`
@@ -50,6 +62,22 @@ func FormatDiscordMessage(event Event) DiscordMessage {
 		embed = formatNewAircraftEmbed(event)
 	case EventHealthAlert:
 		embed = formatHealthEmbed(event)
+	case EventMaxRange:
+		embed = formatMaxRangeEmbed(event)
+	case EventFeedDown, EventFeedUp:
+		embed = formatFeedStatusEmbed(event)
+	case EventFlightComplete:
+		embed = formatFlightCompleteEmbed(event)
+	case EventDigest:
+		embed = formatDigestEmbed(event)
+	case EventTest:
+		embed = DiscordEmbed{
+			Title:       "🧪 Test Webhook",
+			Description: event.Message,
+			Color:       ColorNew,
+			Timestamp:   event.Timestamp.Format(time.RFC3339),
+			Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
+		}
 	default:
 		embed = DiscordEmbed{
 			Title:       "Skywatch Event",
@@ -73,7 +101,9 @@ func formatEmergencyEmbed(event Event) DiscordEmbed {
 		fields = append(fields, DiscordField{Name: "Callsign", Value: ac.Callsign, Inline: true})
 	}
 	fields = append(fields, DiscordField{Name: "ICAO", Value: ac.ICAO, Inline: true})
-	fields = append(fields, DiscordField{Name: "Squawk", Value: ac.Squawk, Inline: true})
+	if ac.Squawk != "" {
+		fields = append(fields, DiscordField{Name: "Squawk", Value: ac.Squawk, Inline: true})
+	}
 
 	if ac.Registration != "" {
 		fields = append(fields, DiscordField{Name: "Registration", Value: ac.Registration, Inline: true})
@@ -98,9 +128,12 @@ func formatEmergencyEmbed(event Event) DiscordEmbed {
 			Inline: true,
 		})
 	}
+	fields = append(fields, receiverFields(ac, event.Units)...)
 
-	title := "🚨 EMERGENCY SQUAWK"
-	switch ac.Squawk {
+	title := "🚨 EMERGENCY SQUAWK " + event.Squawk
+	switch event.Squawk {
+	case "":
+		title = "🚨 EMERGENCY FLAG"
 	case "7500":
 		title = "🚨 HIJACK SQUAWK 7500"
 	case "7600":
@@ -148,17 +181,42 @@ func formatWatchlistEmbed(event Event) DiscordEmbed {
 			Inline: true,
 		})
 	}
+	fields = append(fields, receiverFields(ac, event.Units)...)
+
+	title := "✈️ Watchlist Aircraft Detected"
+	color := ColorWatchlist
+	if m := event.Watchlist; m != nil {
+		if m.Group != "" {
+			title = "✈️ " + m.Group + " Aircraft Detected"
+		}
+		if m.Color != 0 {
+			color = m.Color
+		}
+	}
 
 	return DiscordEmbed{
-		Title:       "✈️ Watchlist Aircraft Detected",
+		Title:       title,
 		Description: event.Message,
-		Color:       ColorWatchlist,
+		Color:       color,
 		Fields:      fields,
 		Timestamp:   event.Timestamp.Format(time.RFC3339),
 		Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
 	}
 }
 
+// receiverFields returns the aircraft's distance and bearing from the
+// receiver, which are only known when a receiver location is configured.
+func receiverFields(ac *models.Aircraft, units models.DistanceUnit) []DiscordField {
+	var fields []DiscordField
+	if ac.DistanceNM != nil {
+		fields = append(fields, DiscordField{Name: "Distance", Value: formatDistance(*ac.DistanceNM, units), Inline: true})
+	}
+	if ac.Bearing != nil {
+		fields = append(fields, DiscordField{Name: "Bearing", Value: fmt.Sprintf("%.0f° %s", *ac.Bearing, ac.BearingCardinal), Inline: true})
+	}
+	return fields
+}
+
 func formatNewAircraftEmbed(event Event) DiscordEmbed {
 	ac := event.Aircraft
 	fields := []DiscordField{}
@@ -185,12 +243,48 @@ func formatNewAircraftEmbed(event Event) DiscordEmbed {
 	}
 }
 
+func formatMaxRangeEmbed(event Event) DiscordEmbed {
+	ac := event.Aircraft
+	fields := receiverFields(ac, event.Units)
+	if ac.Callsign != "" {
+		fields = append(fields, DiscordField{Name: "Callsign", Value: ac.Callsign, Inline: true})
+	}
+	fields = append(fields, DiscordField{Name: "ICAO", Value: ac.ICAO, Inline: true})
+
+	if ac.Registration != "" {
+		fields = append(fields, DiscordField{Name: "Registration", Value: ac.Registration, Inline: true})
+	}
+	if ac.AircraftType != "" {
+		fields = append(fields, DiscordField{Name: "Type", Value: ac.AircraftType, Inline: true})
+	}
+	if ac.AltitudeFt != nil {
+		fields = append(fields, DiscordField{Name: "Altitude", Value: fmt.Sprintf("%d ft", *ac.AltitudeFt), Inline: true})
+	}
+	if ac.Lat != nil && ac.Lon != nil {
+		fields = append(fields, DiscordField{
+			Name:   "Position",
+			Value:  fmt.Sprintf("[%.4f, %.4f](https://www.google.com/maps?q=%.4f,%.4f)", *ac.Lat, *ac.Lon, *ac.Lat, *ac.Lon),
+			Inline: true,
+		})
+	}
+
+	return DiscordEmbed{
+		Title:       "📡 New Max Range Record",
+		Description: event.Message,
+		Color:       ColorMaxRange,
+		Fields:      fields,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
+	}
+}
+
 func formatHealthEmbed(event Event) DiscordEmbed {
 	h := event.Health
 	fields := []DiscordField{
 		{Name: "CPU", Value: fmt.Sprintf("%.1f%%", h.CPUPercent), Inline: true},
 		{Name: "Memory", Value: fmt.Sprintf("%.1f%%", h.MemoryPercent), Inline: true},
 		{Name: "Temperature", Value: fmt.Sprintf("%.1f°C", h.TempCelsius), Inline: true},
+		{Name: "Disk", Value: fmt.Sprintf("%.1f%%", h.DiskPercent), Inline: true},
 		{Name: "Uptime", Value: h.Uptime.Round(time.Second).String(), Inline: true},
 	}
 
@@ -204,3 +298,89 @@ func formatHealthEmbed(event Event) DiscordEmbed {
 	}
 }
 
+func formatFeedStatusEmbed(event Event) DiscordEmbed {
+	f := event.Feed
+	fields := []DiscordField{
+		{Name: "Feed", Value: fmt.Sprintf("%s:%d (%s)", f.Host, f.Port, f.Format), Inline: true},
+		{Name: "Downtime", Value: f.Downtime.Round(time.Second).String(), Inline: true},
+		{Name: "Reconnects", Value: fmt.Sprintf("%d", f.Reconnects), Inline: true},
+	}
+
+	title := "🔌 Feed Disconnected"
+	color := ColorFeedDown
+	if event.Type == EventFeedUp {
+		title = "✅ Feed Reconnected"
+		color = ColorFeedUp
+	}
+
+	return DiscordEmbed{
+		Title:       title,
+		Description: event.Message,
+		Color:       color,
+		Fields:      fields,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
+	}
+}
+
+func formatFlightCompleteEmbed(event Event) DiscordEmbed {
+	f := event.Flight
+	fields := []DiscordField{}
+
+	if f.Callsign != "" {
+		fields = append(fields, DiscordField{Name: "Callsign", Value: f.Callsign, Inline: true})
+	}
+	fields = append(fields, DiscordField{Name: "ICAO", Value: f.ICAO, Inline: true})
+
+	if f.Registration != "" {
+		fields = append(fields, DiscordField{Name: "Registration", Value: f.Registration, Inline: true})
+	}
+	if f.AircraftType != "" {
+		fields = append(fields, DiscordField{Name: "Type", Value: f.AircraftType, Inline: true})
+	}
+
+	fields = append(fields, DiscordField{Name: "Duration", Value: f.Duration().Round(time.Second).String(), Inline: true})
+	fields = append(fields, DiscordField{Name: "Distance", Value: formatDistance(f.TotalDistNM, event.Units), Inline: true})
+	if f.MaxAltFt > 0 {
+		fields = append(fields, DiscordField{Name: "Max Altitude", Value: fmt.Sprintf("%d ft", f.MaxAltFt), Inline: true})
+	}
+
+	return DiscordEmbed{
+		Title:       "🛬 Flight Completed",
+		Description: event.Message,
+		Color:       ColorFlight,
+		Fields:      fields,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
+	}
+}
+
+func formatDigestEmbed(event Event) DiscordEmbed {
+	dg := event.Digest
+	fields := []DiscordField{
+		{Name: "Aircraft", Value: fmt.Sprintf("%d", dg.Aircraft), Inline: true},
+	}
+	if dg.Positions > 0 {
+		fields = append(fields, DiscordField{Name: "Positions", Value: fmt.Sprintf("%d", dg.Positions), Inline: true})
+	}
+	if dg.BusiestType != "" {
+		fields = append(fields, DiscordField{Name: "Busiest Type", Value: fmt.Sprintf("%s (%d)", dg.BusiestType, dg.BusiestTypeCount), Inline: true})
+	}
+	if dg.MaxRangeNM > 0 {
+		value := formatDistance(dg.MaxRangeNM, event.Units)
+		if dg.MaxRangeICAO != "" {
+			value += " (" + dg.MaxRangeICAO + ")"
+		}
+		fields = append(fields, DiscordField{Name: "Max Range", Value: value, Inline: true})
+	}
+	fields = append(fields, DiscordField{Name: "Emergencies", Value: fmt.Sprintf("%d", dg.Emergencies), Inline: true})
+
+	return DiscordEmbed{
+		Title:       "📊 Activity Digest",
+		Description: event.Message,
+		Color:       ColorDigest,
+		Fields:      fields,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
+	}
+}
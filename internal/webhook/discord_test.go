@@ -0,0 +1,35 @@
+package webhook
+
+import (
+	"testing"
+
+	"adsb-tracker/pkg/models"
+)
+
+func fieldValue(embed DiscordEmbed, name string) (string, bool) {
+	for _, f := range embed.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+func TestEmergencyEmbedShowsDistanceFromReceiver(t *testing.T) {
+	lat, lon := 51.5, -0.1
+	ac := &models.Aircraft{ICAO: "ABC123", Squawk: "7700", Lat: &lat, Lon: &lon}
+
+	embed := formatEmergencyEmbed(NewEmergencyEvent(ac, "7700", models.UnitKM))
+	if _, ok := fieldValue(embed, "Distance"); ok {
+		t.Fatal("expected no distance without a receiver location")
+	}
+
+	ac.CalculateDistance(&models.ReceiverLocation{Lat: 51.0, Lon: -0.1})
+	embed = formatEmergencyEmbed(NewEmergencyEvent(ac, "7700", models.UnitKM))
+	if got, _ := fieldValue(embed, "Distance"); got != "55.6 km" {
+		t.Errorf("expected distance 55.6 km, got %q", got)
+	}
+	if got, _ := fieldValue(embed, "Bearing"); got != "0° N" {
+		t.Errorf("expected bearing 0° N, got %q", got)
+	}
+}
@@ -4,166 +4,614 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"adsb-tracker/internal/config"
+	"adsb-tracker/internal/logging"
 	"adsb-tracker/pkg/models"
 )
 
+var logger = logging.Component("webhook")
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+	// maxRateLimitWaits bounds how many 429s one event waits out before it
+	// is dropped.
+	maxRateLimitWaits = 10
+
+	// emergencyFlag is the emergency state of an aircraft with its
+	// transponder's emergency flag set but no emergency squawk.
+	emergencyFlag = config.EmergencyFlag
+	// emergencyStateTTL is how long an aircraft's emergency is remembered
+	// without a change, so one that leaves and later returns still
+	// squawking is alerted again.
+	emergencyStateTTL = time.Hour
+)
+
+type emergencyState struct {
+	code    string
+	changed time.Time
+	// escalation is set while the alert is being repeated.
+	escalation *escalation
+}
+
+type destination struct {
+	url      string
+	provider Provider
+}
+
 type Dispatcher struct {
-	config     config.WebhookConfig
-	events     chan Event
-	client     *http.Client
-	mu         sync.RWMutex
-	recentSent map[string]time.Time
+	cfgMu            sync.RWMutex
+	config           config.WebhookConfig
+	defaultDest      destination
+	routes           map[EventType]destination
+	emergencySquawks map[string]struct{}
+	units            models.DistanceUnit
+	mapRenderer      *mapRenderer
+	aircraftSource   func(icao string) (models.Aircraft, bool)
+
+	events chan Event
+	client *http.Client
+	mu     sync.RWMutex
+	// suppressed holds when each recently alerted subject may alert again.
+	suppressed  map[string]time.Time
+	emergencies map[string]emergencyState
+	// newAircraftWindow is the start of the minute newAircraftCount counts
+	// alerts in.
+	newAircraftWindow time.Time
+	newAircraftCount  int
+	// emergencyCount counts aircraft entering an emergency since the last
+	// digest.
+	emergencyCount int
+	// rateLimitedUntil holds when each webhook URL's rate limit resets.
+	rateLimitedUntil map[string]time.Time
 }
 
 func NewDispatcher(cfg config.WebhookConfig) *Dispatcher {
-	return &Dispatcher{
-		config: cfg,
+	d := &Dispatcher{
 		events: make(chan Event, 100),
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		recentSent: make(map[string]time.Time),
+		suppressed:       make(map[string]time.Time),
+		rateLimitedUntil: make(map[string]time.Time),
+		emergencies:      make(map[string]emergencyState),
 	}
+	d.SetConfig(cfg)
+	return d
+}
+
+// SetConfig replaces the webhook URLs, routes and event settings. It is safe
+// to call while the dispatcher is running; queued events are delivered to
+// the destination configured when they are processed.
+func (d *Dispatcher) SetConfig(cfg config.WebhookConfig) {
+	url := cfg.Endpoint()
+	routes := make(map[EventType]destination, len(cfg.Routes))
+	for eventType, routeURL := range cfg.Routes {
+		if routeURL == "" {
+			continue
+		}
+		routes[EventType(eventType)] = destination{url: routeURL, provider: NewProvider(cfg.Provider, routeURL)}
+	}
+	squawks := buildSquawkSet(cfg.Events.EmergencySquawks)
+	renderer := newMapRenderer(cfg.Map, d.client)
+
+	d.cfgMu.Lock()
+	defer d.cfgMu.Unlock()
+	d.config = cfg
+	d.defaultDest = destination{url: url, provider: NewProvider(cfg.Provider, url)}
+	d.routes = routes
+	d.emergencySquawks = squawks
+	d.mapRenderer = renderer
+}
+
+// SetUnits selects the unit distances are shown in.
+func (d *Dispatcher) SetUnits(u models.DistanceUnit) {
+	d.cfgMu.Lock()
+	d.units = u
+	d.cfgMu.Unlock()
+}
+
+func (d *Dispatcher) distanceUnit() models.DistanceUnit {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	return d.units
+}
+
+func (d *Dispatcher) eventsConfig() config.WebhookEventsConfig {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	return d.config.Events
+}
+
+func buildSquawkSet(codes []string) map[string]struct{} {
+	if len(codes) == 0 {
+		codes = config.DefaultEmergencySquawks()
+	}
+	set := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code != "" {
+			set[code] = struct{}{}
+		}
+	}
+	return set
 }
 
 func (d *Dispatcher) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
+	escalationTicker := time.NewTicker(escalationCheckInterval)
+	defer escalationTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case event := <-d.events:
-			d.processEvent(event)
+			d.processEvent(ctx, event)
 		case <-ticker.C:
 			d.cleanupRecent()
+		case <-escalationTicker.C:
+			d.escalate()
 		}
 	}
 }
 
+func (d *Dispatcher) ProviderName() string {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	return d.defaultDest.provider.Name()
+}
+
+// destinationFor picks the route configured for an event type, falling back
+// to the default webhook URL.
+func (d *Dispatcher) destinationFor(eventType EventType) destination {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	if dest, ok := d.routes[eventType]; ok {
+		return dest
+	}
+	return d.defaultDest
+}
+
+// destinationForEvent honours a watchlist group's own URL before falling
+// back to the per-type route.
+func (d *Dispatcher) destinationForEvent(event Event) destination {
+	if event.Watchlist != nil && event.Watchlist.URL != "" {
+		d.cfgMu.RLock()
+		provider := d.config.Provider
+		d.cfgMu.RUnlock()
+		return destination{url: event.Watchlist.URL, provider: NewProvider(provider, event.Watchlist.URL)}
+	}
+	return d.destinationFor(event.Type)
+}
+
 func (d *Dispatcher) Send(event Event) {
-	if d.config.DiscordURL == "" {
+	if d.destinationForEvent(event).url == "" {
 		return
 	}
 
 	select {
 	case d.events <- event:
 	default:
-		log.Printf("[WEBHOOK] Event queue full, dropping event")
+		logger.Warn("event queue full, dropping event", "event", event.Type)
 	}
 }
 
-func (d *Dispatcher) SendEmergency(ac *models.Aircraft) {
-	if !d.config.Events.EmergencySquawk {
+// UpdateEmergency records whether ac is in an emergency, by squawk or by
+// the transponder's emergency flag, and alerts on the transition into one.
+// Changing to a different emergency squawk alerts again; an aircraft that
+// stays in the same emergency does not. Call it whenever the squawk or
+// emergency flag changes.
+func (d *Dispatcher) UpdateEmergency(ac *models.Aircraft) {
+	state := ""
+	if d.IsEmergencySquawk(ac.Squawk) {
+		state = ac.Squawk
+	} else if ac.EmergencyFlagged() {
+		state = emergencyFlag
+	}
+
+	d.mu.Lock()
+	prevState := d.emergencies[ac.ICAO]
+	prev := prevState.code
+	if state == "" {
+		delete(d.emergencies, ac.ICAO)
+	} else if state != prev {
+		d.emergencies[ac.ICAO] = emergencyState{code: state, changed: time.Now()}
+	}
+	d.mu.Unlock()
+
+	if state != prev && prevState.escalation != nil {
+		reason := "cleared"
+		if state != "" {
+			reason = "squawk changed"
+		}
+		logEscalationStop(ac.ICAO, prev, reason)
+	}
+
+	if state == "" || state == prev {
 		return
 	}
-	if !d.shouldSend("emergency:" + ac.ICAO) {
+	// Losing the code while the flag stays set is the same emergency.
+	if prev != "" && state == emergencyFlag {
 		return
 	}
-	d.Send(NewEmergencyEvent(ac, ac.Squawk))
-}
 
-func (d *Dispatcher) SendWatchlistMatch(ac *models.Aircraft, pattern string) {
-	if len(d.config.Events.AircraftWatchlist) == 0 {
+	d.mu.Lock()
+	d.emergencyCount++
+	d.mu.Unlock()
+
+	if !d.eventsConfig().EmergencyAlertEnabled(state) {
 		return
 	}
-	if !d.shouldSend("watchlist:" + ac.ICAO) {
+	if !d.shouldSend(EventEmergencySquawk, "emergency:"+ac.ICAO+":"+state) {
+		return
+	}
+
+	squawk := state
+	if state == emergencyFlag {
+		squawk = ""
+	}
+	d.Send(NewEmergencyEvent(ac, squawk, d.distanceUnit()))
+	d.startEscalation(ac.ICAO, state)
+}
+
+func (d *Dispatcher) SendWatchlistMatch(ac *models.Aircraft, match WatchlistMatch) {
+	if !d.shouldSend(EventWatchlistMatch, "watchlist:"+match.Group+":"+ac.ICAO) {
 		return
 	}
-	d.Send(NewWatchlistEvent(ac, pattern))
+	d.Send(NewWatchlistEvent(ac, match, d.distanceUnit()))
 }
 
 func (d *Dispatcher) SendNewAircraft(ac *models.Aircraft) {
-	if !d.config.Events.NewAircraft {
+	if !d.eventsConfig().NewAircraft {
+		return
+	}
+	if !d.allowNewAircraft(ac.ICAO) {
 		return
 	}
 	d.Send(NewAircraftEvent(ac))
 }
 
+func (d *Dispatcher) SendMaxRange(ac *models.Aircraft) {
+	if !d.eventsConfig().MaxRange {
+		return
+	}
+	if !d.shouldSend(EventMaxRange, "max_range") {
+		return
+	}
+	d.Send(NewMaxRangeEvent(ac, d.distanceUnit()))
+}
+
+func (d *Dispatcher) SendFeedDown(feed *FeedStatusData) bool {
+	if !d.eventsConfig().FeedStatus {
+		return false
+	}
+	if !d.shouldSend(EventFeedDown, "feed:down") {
+		return false
+	}
+	d.Send(NewFeedDownEvent(feed))
+	return true
+}
+
+func (d *Dispatcher) SendFeedUp(feed *FeedStatusData) {
+	if !d.eventsConfig().FeedStatus {
+		return
+	}
+	if !d.shouldSend(EventFeedUp, "feed:up") {
+		return
+	}
+	d.Send(NewFeedUpEvent(feed))
+}
+
+func (d *Dispatcher) SendFlightComplete(flight *FlightData) {
+	if !d.eventsConfig().FlightComplete {
+		return
+	}
+	d.Send(NewFlightCompleteEvent(flight, d.distanceUnit()))
+}
+
 func (d *Dispatcher) SendHealthAlert(health *HealthData, alertType string) {
-	if !d.config.Events.HealthAlerts {
+	if !d.eventsConfig().HealthAlerts {
 		return
 	}
-	if !d.shouldSend("health:" + alertType) {
+	if !d.shouldSend(EventHealthAlert, "health:"+alertType) {
 		return
 	}
 	d.Send(NewHealthAlertEvent(health, alertType))
 }
 
-func (d *Dispatcher) CheckWatchlist(ac *models.Aircraft) (bool, string) {
-	if len(d.config.Events.AircraftWatchlist) == 0 {
-		return false, ""
+func (d *Dispatcher) IsEmergencySquawk(squawk string) bool {
+	if squawk == "" {
+		return false
 	}
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	_, ok := d.emergencySquawks[squawk]
+	return ok
+}
 
-	for _, pattern := range d.config.Events.AircraftWatchlist {
-		pattern = strings.ToUpper(pattern)
+func (d *Dispatcher) processEvent(ctx context.Context, event Event) {
+	dest := d.destinationForEvent(event)
+	body, contentType, err := d.encode(ctx, dest, event)
+	if err != nil {
+		logger.Error("failed to build message", "event", event.Type, "error", err)
+		return
+	}
 
-		if strings.HasSuffix(pattern, "*") {
-			prefix := strings.TrimSuffix(pattern, "*")
-			if strings.HasPrefix(strings.ToUpper(ac.ICAO), prefix) ||
-				strings.HasPrefix(strings.ToUpper(ac.Registration), prefix) ||
-				strings.HasPrefix(strings.ToUpper(ac.Callsign), prefix) {
-				return true, pattern
-			}
-		} else {
-			if strings.EqualFold(ac.ICAO, pattern) ||
-				strings.EqualFold(ac.Registration, pattern) ||
-				strings.EqualFold(ac.Callsign, pattern) {
-				return true, pattern
+	retries := retryBudget(event.Type)
+	backoff := retryBaseDelay
+	rateLimited := 0
+
+	for attempt := 0; ; {
+		if !d.waitRateLimit(ctx, dest.url) {
+			return
+		}
+		err := d.post(dest.url, contentType, body)
+		if err == nil {
+			logger.Info("sent event", "event", event.Type)
+			return
+		}
+
+		var delivery *deliveryError
+		retryable := true
+		if errors.As(err, &delivery) {
+			retryable = delivery.retryable()
+			// A rate limit is not a failure of the event, so it waits out
+			// the limit without using up its retries.
+			if delivery.status == http.StatusTooManyRequests && rateLimited < maxRateLimitWaits {
+				rateLimited++
+				logger.Warn("rate limited, pausing", "event", event.Type, "retry_in", delivery.retryAfter)
+				continue
 			}
 		}
+
+		if !retryable || attempt >= retries {
+			logger.Error("giving up on event", "event", event.Type, "attempts", attempt+rateLimited+1, "error", err)
+			return
+		}
+		attempt++
+
+		wait := backoff
+		backoff = min(backoff*2, retryMaxDelay)
+		logger.Warn("delivery failed, retrying", "event", event.Type, "error", err, "retry_in", wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
 	}
+}
+
+// waitRateLimit blocks until url's rate limit, if any, has reset. It
+// returns false if ctx is cancelled first.
+func (d *Dispatcher) waitRateLimit(ctx context.Context, url string) bool {
+	d.mu.RLock()
+	until := d.rateLimitedUntil[url]
+	d.mu.RUnlock()
 
-	return false, ""
+	wait := time.Until(until)
+	if wait <= 0 {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(wait):
+		return true
+	}
 }
 
-func (d *Dispatcher) IsEmergencySquawk(squawk string) bool {
-	return squawk == "7500" || squawk == "7600" || squawk == "7700"
+func (d *Dispatcher) setRateLimited(url string, wait time.Duration) {
+	d.mu.Lock()
+	d.rateLimitedUntil[url] = time.Now().Add(wait)
+	d.mu.Unlock()
+}
+
+type deliveryError struct {
+	status     int
+	retryAfter time.Duration
 }
 
-func (d *Dispatcher) processEvent(event Event) {
-	msg := FormatDiscordMessage(event)
+func (e *deliveryError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.status)
+}
+
+func (e *deliveryError) retryable() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= 500
+}
 
-	body, err := json.Marshal(msg)
+// encode formats event for dest's provider, attaching a map image when one
+// is configured and the provider supports it.
+func (d *Dispatcher) encode(ctx context.Context, dest destination, event Event) ([]byte, string, error) {
+	payload := dest.provider.Payload(event)
+
+	var mapImage []byte
+	if msg, ok := payload.(DiscordMessage); ok && len(msg.Embeds) > 0 {
+		if mapImage = d.renderMap(ctx, event); mapImage != nil {
+			msg.Embeds[0].Image = &DiscordImage{URL: "attachment://" + mapImageName}
+			payload = msg
+		}
+	}
+
+	body, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("[WEBHOOK] Failed to marshal message: %v", err)
-		return
+		return nil, "", err
+	}
+	if mapImage != nil {
+		return multipartBody(body, mapImage)
+	}
+	return body, "application/json", nil
+}
+
+// renderMap returns a map image for events about an aircraft with a known
+// position, or nil when maps are disabled or rendering fails.
+func (d *Dispatcher) renderMap(ctx context.Context, event Event) []byte {
+	d.cfgMu.RLock()
+	renderer := d.mapRenderer
+	d.cfgMu.RUnlock()
+
+	ac := event.Aircraft
+	if renderer == nil || ac == nil || ac.Lat == nil || ac.Lon == nil {
+		return nil
 	}
 
-	resp, err := d.client.Post(d.config.DiscordURL, "application/json", bytes.NewReader(body))
+	ctx, cancel := context.WithTimeout(ctx, mapTimeout)
+	defer cancel()
+	img, err := renderer.Render(ctx, *ac.Lat, *ac.Lon)
 	if err != nil {
-		log.Printf("[WEBHOOK] Failed to send: %v", err)
-		return
+		logger.Warn("map image failed, sending without it", "event", event.Type, "icao", ac.ICAO, "error", err)
+		return nil
+	}
+	return img
+}
+
+func (d *Dispatcher) post(url, contentType string, body []byte) error {
+	resp, err := d.client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if wait <= 0 {
+			wait = parseRetryAfterBody(resp.Body)
+		}
+		if wait <= 0 {
+			wait = retryBaseDelay
+		}
+		d.setRateLimited(url, wait)
+		return &deliveryError{status: resp.StatusCode, retryAfter: wait}
+	}
 	if resp.StatusCode >= 400 {
-		log.Printf("[WEBHOOK] Discord returned status %d", resp.StatusCode)
-		return
+		return &deliveryError{status: resp.StatusCode}
+	}
+
+	// Discord reports an exhausted bucket on the last request it accepts,
+	// so the next send can wait instead of being rejected.
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if wait := parseRetryAfter(resp.Header.Get("X-RateLimit-Reset-After")); wait > 0 {
+			d.setRateLimited(url, wait)
+		}
+	}
+	return nil
+}
+
+// parseRetryAfterBody reads the retry_after field, in seconds, that Discord
+// includes in the JSON body of a 429.
+func parseRetryAfterBody(body io.Reader) time.Duration {
+	var payload struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 4096)).Decode(&payload); err != nil || payload.RetryAfter <= 0 {
+		return 0
+	}
+	return min(time.Duration(payload.RetryAfter*float64(time.Second)), retryMaxDelay)
+}
+
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
 	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+		return min(time.Duration(secs*float64(time.Second)), retryMaxDelay)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return min(wait, retryMaxDelay)
+		}
+	}
+	return 0
+}
+
+// retryBudget returns how many times a failed delivery is retried. Alerts
+// that matter most get the largest budget so the single worker spends its
+// time on them rather than on routine notifications.
+func retryBudget(eventType EventType) int {
+	switch eventType {
+	case EventEmergencySquawk:
+		return 5
+	case EventWatchlistMatch, EventHealthAlert, EventFeedDown, EventFeedUp, EventFlightComplete:
+		return 3
+	case EventNewAircraft:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (d *Dispatcher) cooldown(eventType EventType) time.Duration {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	return d.config.Cooldown(string(eventType))
+}
 
-	log.Printf("[WEBHOOK] Sent %s event", event.Type)
+func (d *Dispatcher) suppressionKey(eventType EventType, key string) string {
+	return d.destinationFor(eventType).url + "|" + key
 }
 
-func (d *Dispatcher) shouldSend(key string) bool {
+// shouldSend reports whether an alert about key may be sent, suppressing
+// further ones for the event type's cooldown if so.
+func (d *Dispatcher) shouldSend(eventType EventType, key string) bool {
+	cooldown := d.cooldown(eventType)
+	key = d.suppressionKey(eventType, key)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if lastSent, ok := d.recentSent[key]; ok {
-		if time.Since(lastSent) < 5*time.Minute {
+	now := time.Now()
+	if now.Before(d.suppressed[key]) {
+		return false
+	}
+	if cooldown > 0 {
+		d.suppressed[key] = now.Add(cooldown)
+	}
+	return true
+}
+
+// allowNewAircraft applies the new aircraft cooldown and the cap on new
+// aircraft alerts per minute. An aircraft dropped by the cap is not put on
+// cooldown, so it can still alert once the minute is over.
+func (d *Dispatcher) allowNewAircraft(icao string) bool {
+	cooldown := d.cooldown(EventNewAircraft)
+	key := d.suppressionKey(EventNewAircraft, "new:"+icao)
+	d.cfgMu.RLock()
+	limit := d.config.NewAircraftPerMinute
+	d.cfgMu.RUnlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(d.suppressed[key]) {
+		return false
+	}
+	if limit > 0 {
+		if now.Sub(d.newAircraftWindow) >= time.Minute {
+			d.newAircraftWindow = now
+			d.newAircraftCount = 0
+		}
+		if d.newAircraftCount >= limit {
+			logger.Debug("new aircraft alert dropped by rate cap", "icao", icao, "limit", limit)
 			return false
 		}
+		d.newAircraftCount++
+	}
+	if cooldown > 0 {
+		d.suppressed[key] = now.Add(cooldown)
 	}
-
-	d.recentSent[key] = time.Now()
 	return true
 }
 
@@ -172,42 +620,61 @@ func (d *Dispatcher) cleanupRecent() {
 	defer d.mu.Unlock()
 
 	now := time.Now()
-	for key, t := range d.recentSent {
-		if now.Sub(t) > 10*time.Minute {
-			delete(d.recentSent, key)
+	for key, until := range d.suppressed {
+		if !now.Before(until) {
+			delete(d.suppressed, key)
+		}
+	}
+	for icao, state := range d.emergencies {
+		// Escalations end when the aircraft is no longer tracked instead.
+		if state.escalation == nil && now.Sub(state.changed) > emergencyStateTTL {
+			delete(d.emergencies, icao)
 		}
 	}
 }
 
-func (d *Dispatcher) SendTestWebhook() error {
-	if d.config.DiscordURL == "" {
-		return nil
+// SendTestEvent formats a sample event of the given kind (see SampleEvent)
+// and delivers it immediately to the destination a real one would use,
+// returning the delivery error instead of retrying.
+func (d *Dispatcher) SendTestEvent(ctx context.Context, kind string) error {
+	event, ok := SampleEvent(kind, d.distanceUnit())
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownTestEvent, kind)
 	}
-
-	msg := DiscordMessage{
-		Username: "Skywatch",
-		Embeds: []DiscordEmbed{
-			{
-				Title:       "🧪 Test Webhook",
-				Description: "Webhook is configured correctly!",
-				Color:       ColorNew,
-				Timestamp:   time.Now().Format(time.RFC3339),
-				Footer:      &DiscordFooter{Text: "Skywatch ADS-B Tracker"},
-			},
-		},
+	dest := d.destinationForEvent(event)
+	if dest.url == "" {
+		return fmt.Errorf("no webhook URL configured for %s", event.Type)
 	}
-
-	body, err := json.Marshal(msg)
+	body, contentType, err := d.encode(ctx, dest, event)
 	if err != nil {
 		return err
 	}
+	return d.post(dest.url, contentType, body)
+}
+
+func (d *Dispatcher) SendTestWebhook() error {
+	sent := make(map[string]bool)
+	d.cfgMu.RLock()
+	dests := []destination{d.defaultDest}
+	for _, dest := range d.routes {
+		dests = append(dests, dest)
+	}
+	d.cfgMu.RUnlock()
 
-	resp, err := d.client.Post(d.config.DiscordURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
+	for _, dest := range dests {
+		if dest.url == "" || sent[dest.url] {
+			continue
+		}
+		sent[dest.url] = true
+
+		body, err := json.Marshal(dest.provider.Payload(NewTestEvent()))
+		if err != nil {
+			return err
+		}
+		if err := d.post(dest.url, "application/json", body); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
 
 	return nil
 }
-
@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"adsb-tracker/internal/config"
+	"adsb-tracker/pkg/models"
+)
+
+func newTestDispatcher() *Dispatcher {
+	return NewDispatcher(config.WebhookConfig{
+		URL:    "https://example.com/hook",
+		Events: config.WebhookEventsConfig{EmergencySquawk: true},
+	})
+}
+
+// drain returns the squawks of the queued emergency events.
+func drain(d *Dispatcher) []string {
+	var squawks []string
+	for {
+		select {
+		case event := <-d.events:
+			if event.Type == EventEmergencySquawk {
+				squawks = append(squawks, event.Squawk)
+			}
+		default:
+			return squawks
+		}
+	}
+}
+
+func TestUpdateEmergencyAlertsOnTransitions(t *testing.T) {
+	d := newTestDispatcher()
+	flag := true
+	steps := []struct {
+		squawk    string
+		emergency *bool
+		want      []string
+	}{
+		{"1200", nil, nil},
+		{"7700", nil, []string{"7700"}},
+		{"7700", nil, nil},
+		{"1200", nil, nil},
+		{"7700", nil, []string{"7700"}},
+		{"7500", nil, []string{"7500"}},
+		{"1200", &flag, nil},
+		{"1200", nil, nil},
+		{"1200", &flag, []string{""}},
+		{"7600", &flag, []string{"7600"}},
+	}
+	for i, step := range steps {
+		d.UpdateEmergency(&models.Aircraft{ICAO: "ABC123", Squawk: step.squawk, Emergency: step.emergency})
+		got := drain(d)
+		if len(got) != len(step.want) || (len(got) > 0 && got[0] != step.want[0]) {
+			t.Fatalf("step %d (squawk %s): expected alerts %q, got %q", i, step.squawk, step.want, got)
+		}
+	}
+}
+
+func TestUpdateEmergencyTracksAircraftSeparately(t *testing.T) {
+	d := newTestDispatcher()
+	d.UpdateEmergency(&models.Aircraft{ICAO: "ABC123", Squawk: "7700"})
+	d.UpdateEmergency(&models.Aircraft{ICAO: "DEF456", Squawk: "7700"})
+	if got := drain(d); len(got) != 2 {
+		t.Fatalf("expected an alert per aircraft, got %q", got)
+	}
+}
+
+func TestSendTestEventDeliversEachKind(t *testing.T) {
+	var titles []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg DiscordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || len(msg.Embeds) != 1 {
+			t.Errorf("expected one embed, got %+v (%v)", msg, err)
+			return
+		}
+		titles = append(titles, msg.Embeds[0].Title)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(config.WebhookConfig{URL: srv.URL})
+	for _, kind := range TestEventKinds {
+		if err := d.SendTestEvent(context.Background(), kind); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+	}
+	if len(titles) != len(TestEventKinds) {
+		t.Fatalf("expected %d deliveries, got %q", len(TestEventKinds), titles)
+	}
+
+	if err := d.SendTestEvent(context.Background(), "bogus"); !errors.Is(err, ErrUnknownTestEvent) {
+		t.Errorf("expected ErrUnknownTestEvent, got %v", err)
+	}
+}
+
+func queued(d *Dispatcher) int {
+	n := 0
+	for {
+		select {
+		case <-d.events:
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+func TestNewAircraftRateCapAndCooldown(t *testing.T) {
+	d := NewDispatcher(config.WebhookConfig{
+		URL:                  "https://example.com/hook",
+		Events:               config.WebhookEventsConfig{NewAircraft: true},
+		NewAircraftPerMinute: 2,
+	})
+
+	for _, icao := range []string{"AAA001", "AAA002", "AAA003"} {
+		d.SendNewAircraft(&models.Aircraft{ICAO: icao})
+	}
+	if n := queued(d); n != 2 {
+		t.Fatalf("expected the cap to allow 2 alerts, got %d", n)
+	}
+
+	d.mu.Lock()
+	d.newAircraftWindow = d.newAircraftWindow.Add(-time.Minute)
+	d.mu.Unlock()
+
+	d.SendNewAircraft(&models.Aircraft{ICAO: "AAA001"})
+	d.SendNewAircraft(&models.Aircraft{ICAO: "AAA003"})
+	if n := queued(d); n != 1 {
+		t.Fatalf("expected only the capped aircraft to alert in the next minute, got %d", n)
+	}
+}
+
+func TestCooldownIsConfigurablePerEvent(t *testing.T) {
+	match := WatchlistMatch{Pattern: "ABC*"}
+	ac := &models.Aircraft{ICAO: "ABC123"}
+
+	d := newTestDispatcher()
+	d.SendWatchlistMatch(ac, match)
+	d.SendWatchlistMatch(ac, match)
+	if n := queued(d); n != 1 {
+		t.Fatalf("expected the default cooldown to suppress the repeat, got %d alerts", n)
+	}
+
+	d = NewDispatcher(config.WebhookConfig{
+		URL:       "https://example.com/hook",
+		Cooldowns: map[string]time.Duration{"watchlist_match": 0},
+	})
+	d.SendWatchlistMatch(ac, match)
+	d.SendWatchlistMatch(ac, match)
+	if n := queued(d); n != 2 {
+		t.Fatalf("expected no cooldown, got %d alerts", n)
+	}
+}
+
+func TestUpdateEmergencyHonoursPerCodeToggles(t *testing.T) {
+	d := NewDispatcher(config.WebhookConfig{
+		URL: "https://example.com/hook",
+		Events: config.WebhookEventsConfig{
+			EmergencySquawk: true,
+			EmergencyAlerts: map[string]bool{"7700": false, config.EmergencyFlag: false},
+		},
+	})
+	flag := true
+
+	d.UpdateEmergency(&models.Aircraft{ICAO: "AAA001", Squawk: "7700"})
+	d.UpdateEmergency(&models.Aircraft{ICAO: "AAA002", Squawk: "1200", Emergency: &flag})
+	if got := drain(d); len(got) != 0 {
+		t.Fatalf("expected muted codes not to alert, got %q", got)
+	}
+
+	d.UpdateEmergency(&models.Aircraft{ICAO: "AAA001", Squawk: "7500"})
+	if got := drain(d); len(got) != 1 || got[0] != "7500" {
+		t.Fatalf("expected 7500 to alert after a muted 7700, got %q", got)
+	}
+}
+
+func TestProcessEventWaitsOutRateLimits(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.05, "global": false}`))
+		default:
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset-After", "0.05")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(config.WebhookConfig{URL: srv.URL})
+	start := time.Now()
+	// New aircraft events get a single retry, so surviving two 429s shows
+	// rate limits do not use up the budget.
+	d.processEvent(context.Background(), NewAircraftEvent(&models.Aircraft{ICAO: "ABC123"}))
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("expected delivery on the third request, got %d requests", n)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected both rate limits to be waited out, took %v", elapsed)
+	}
+
+	start = time.Now()
+	d.processEvent(context.Background(), NewAircraftEvent(&models.Aircraft{ICAO: "ABC124"}))
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected the next send to wait for the exhausted bucket, took %v", elapsed)
+	}
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"fmt"
+	"time"
+
+	"adsb-tracker/pkg/models"
+)
+
+// escalationCheckInterval is how often active emergencies are checked for
+// a repeat alert.
+const escalationCheckInterval = 15 * time.Second
+
+type escalation struct {
+	lastSent time.Time
+	alerts   int
+}
+
+// SetAircraftSource supplies the current state of a tracked aircraft, which
+// escalation needs to repeat alerts with fresh data and to stop once the
+// aircraft is no longer tracked. Without it emergencies are not escalated.
+func (d *Dispatcher) SetAircraftSource(source func(icao string) (models.Aircraft, bool)) {
+	d.cfgMu.Lock()
+	d.aircraftSource = source
+	d.cfgMu.Unlock()
+}
+
+// startEscalation marks the emergency just alerted for icao to be repeated
+// if escalation covers its code.
+func (d *Dispatcher) startEscalation(icao, code string) {
+	d.cfgMu.RLock()
+	cfg := d.config.Escalation
+	enabled := d.aircraftSource != nil && cfg.Escalates(code)
+	d.cfgMu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	d.mu.Lock()
+	state, ok := d.emergencies[icao]
+	if ok && state.code == code {
+		state.escalation = &escalation{lastSent: time.Now(), alerts: 1}
+		d.emergencies[icao] = state
+	}
+	d.mu.Unlock()
+
+	if ok && state.code == code {
+		logger.Warn("emergency escalation started", "icao", icao, "squawk", code, "interval", cfg.Interval)
+	}
+}
+
+// escalate ends escalations for aircraft that are no longer tracked or
+// whose code is no longer escalated, and repeats the alert for the rest
+// once their interval has passed.
+func (d *Dispatcher) escalate() {
+	d.cfgMu.RLock()
+	cfg := d.config.Escalation
+	events := d.config.Events
+	source := d.aircraftSource
+	d.cfgMu.RUnlock()
+
+	type active struct {
+		icao, code string
+		since      time.Time
+		alerts     int
+		due        bool
+	}
+	var escalating []active
+
+	now := time.Now()
+	d.mu.Lock()
+	for icao, state := range d.emergencies {
+		esc := state.escalation
+		if esc == nil {
+			continue
+		}
+		if source == nil || !cfg.Escalates(state.code) || !events.EmergencyAlertEnabled(state.code) {
+			state.escalation = nil
+			d.emergencies[icao] = state
+			logEscalationStop(icao, state.code, "disabled")
+			continue
+		}
+		a := active{icao: icao, code: state.code, since: state.changed}
+		if now.Sub(esc.lastSent) >= cfg.Interval {
+			esc.lastSent = now
+			esc.alerts++
+			a.alerts, a.due = esc.alerts, true
+		}
+		escalating = append(escalating, a)
+	}
+	d.mu.Unlock()
+
+	for _, a := range escalating {
+		ac, ok := source(a.icao)
+		if !ok {
+			d.mu.Lock()
+			if state, ok := d.emergencies[a.icao]; ok && state.code == a.code {
+				delete(d.emergencies, a.icao)
+			}
+			d.mu.Unlock()
+			logEscalationStop(a.icao, a.code, "no longer tracked")
+			continue
+		}
+		if !a.due {
+			continue
+		}
+
+		squawk := a.code
+		if a.code == emergencyFlag {
+			squawk = ""
+		}
+		event := NewEmergencyEvent(&ac, squawk, d.distanceUnit())
+		event.Message = fmt.Sprintf("%s (still active after %s, alert %d)", event.Message, now.Sub(a.since).Round(time.Minute), a.alerts)
+		logger.Warn("emergency escalated", "icao", a.icao, "squawk", a.code, "alert", a.alerts)
+		d.Send(event)
+	}
+}
+
+func logEscalationStop(icao, code, reason string) {
+	logger.Warn("emergency escalation stopped", "icao", icao, "squawk", code, "reason", reason)
+}
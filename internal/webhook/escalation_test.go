@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"adsb-tracker/internal/config"
+	"adsb-tracker/pkg/models"
+)
+
+type fakeAircraft struct {
+	mu       sync.Mutex
+	aircraft map[string]models.Aircraft
+}
+
+func (f *fakeAircraft) get(icao string) (models.Aircraft, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ac, ok := f.aircraft[icao]
+	return ac, ok
+}
+
+func (f *fakeAircraft) set(ac *models.Aircraft) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ac.Squawk == "" {
+		delete(f.aircraft, ac.ICAO)
+		return
+	}
+	f.aircraft[ac.ICAO] = *ac
+}
+
+// backdate makes every escalation due.
+func backdate(d *Dispatcher) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, state := range d.emergencies {
+		if state.escalation != nil {
+			state.escalation.lastSent = state.escalation.lastSent.Add(-time.Hour)
+		}
+	}
+}
+
+func TestEscalationRepeatsUntilCleared(t *testing.T) {
+	d := NewDispatcher(config.WebhookConfig{
+		URL:        "https://example.com/hook",
+		Events:     config.WebhookEventsConfig{EmergencySquawk: true},
+		Escalation: config.EscalationConfig{Interval: 5 * time.Minute, Codes: []string{"7500"}},
+	})
+	tracked := &fakeAircraft{aircraft: make(map[string]models.Aircraft)}
+	d.SetAircraftSource(tracked.get)
+
+	hijack := &models.Aircraft{ICAO: "AAA001", Squawk: "7500"}
+	general := &models.Aircraft{ICAO: "AAA002", Squawk: "7700"}
+	for _, ac := range []*models.Aircraft{hijack, general} {
+		tracked.set(ac)
+		d.UpdateEmergency(ac)
+	}
+	if got := drain(d); len(got) != 2 {
+		t.Fatalf("expected the initial alerts, got %q", got)
+	}
+
+	d.escalate()
+	if got := drain(d); len(got) != 0 {
+		t.Fatalf("expected no repeat before the interval, got %q", got)
+	}
+
+	backdate(d)
+	d.escalate()
+	select {
+	case event := <-d.events:
+		if event.Squawk != "7500" || !strings.Contains(event.Message, "alert 2") {
+			t.Fatalf("expected a repeated 7500 alert, got %s %q", event.Squawk, event.Message)
+		}
+	default:
+		t.Fatal("expected the 7500 alert to be repeated")
+	}
+	if got := drain(d); len(got) != 0 {
+		t.Fatalf("expected only 7500 to escalate, got %q", got)
+	}
+
+	hijack.Squawk = "1200"
+	d.UpdateEmergency(hijack)
+	backdate(d)
+	d.escalate()
+	if got := drain(d); len(got) != 0 {
+		t.Fatalf("expected escalation to stop once cleared, got %q", got)
+	}
+}
+
+func TestEscalationStopsWhenAircraftIsNoLongerTracked(t *testing.T) {
+	d := NewDispatcher(config.WebhookConfig{
+		URL:        "https://example.com/hook",
+		Events:     config.WebhookEventsConfig{EmergencySquawk: true},
+		Escalation: config.EscalationConfig{Interval: 5 * time.Minute},
+	})
+	tracked := &fakeAircraft{aircraft: make(map[string]models.Aircraft)}
+	d.SetAircraftSource(tracked.get)
+
+	ac := &models.Aircraft{ICAO: "AAA001", Squawk: "7700"}
+	tracked.set(ac)
+	d.UpdateEmergency(ac)
+	drain(d)
+
+	tracked.set(&models.Aircraft{ICAO: "AAA001"})
+	d.escalate()
+	d.mu.RLock()
+	_, ok := d.emergencies["AAA001"]
+	d.mu.RUnlock()
+	if ok {
+		t.Fatal("expected the emergency to be forgotten once the aircraft is gone")
+	}
+
+	backdate(d)
+	d.escalate()
+	if got := drain(d); len(got) != 0 {
+		t.Fatalf("expected no repeat for an untracked aircraft, got %q", got)
+	}
+}
@@ -1,6 +1,9 @@
 package webhook
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"adsb-tracker/pkg/models"
@@ -13,6 +16,12 @@ const (
 	EventWatchlistMatch  EventType = "watchlist_match"
 	EventNewAircraft     EventType = "new_aircraft"
 	EventHealthAlert     EventType = "health_alert"
+	EventMaxRange        EventType = "max_range"
+	EventFeedDown        EventType = "feed_down"
+	EventFeedUp          EventType = "feed_up"
+	EventFlightComplete  EventType = "flight_complete"
+	EventDigest          EventType = "digest"
+	EventTest            EventType = "test"
 )
 
 type Event struct {
@@ -20,19 +29,51 @@ type Event struct {
 	Timestamp time.Time
 	Aircraft  *models.Aircraft
 	Health    *HealthData
-	Message   string
+	Feed      *FeedStatusData
+	Flight    *FlightData
+	Digest    *DigestData
+	Watchlist *WatchlistMatch
+	// Squawk is the emergency code behind an emergency event, empty when
+	// only the transponder's emergency flag is set.
+	Squawk  string
+	Units   models.DistanceUnit
+	Message string
 }
 
 type HealthData struct {
 	CPUPercent    float64
 	MemoryPercent float64
 	TempCelsius   float64
+	DiskPercent   float64
 	Uptime        time.Duration
 	AlertType     string
 }
 
-func NewEmergencyEvent(ac *models.Aircraft, squawk string) Event {
-	msg := "Unknown emergency"
+type FeedStatusData struct {
+	Host       string
+	Port       int
+	Format     string
+	Reconnects int
+	Downtime   time.Duration
+}
+
+type FlightData struct {
+	ICAO         string
+	Callsign     string
+	Registration string
+	AircraftType string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	MaxAltFt     int
+	TotalDistNM  float64
+}
+
+func (f *FlightData) Duration() time.Duration {
+	return f.LastSeen.Sub(f.FirstSeen)
+}
+
+func NewEmergencyEvent(ac *models.Aircraft, squawk string, units models.DistanceUnit) Event {
+	msg := "Emergency squawk " + squawk
 	switch squawk {
 	case "7500":
 		msg = "HIJACK - Aircraft is being hijacked"
@@ -40,22 +81,32 @@ func NewEmergencyEvent(ac *models.Aircraft, squawk string) Event {
 		msg = "RADIO FAILURE - Lost communications"
 	case "7700":
 		msg = "EMERGENCY - General emergency declared"
+	case "":
+		msg = "EMERGENCY - Transponder emergency flag set"
 	}
 
 	return Event{
 		Type:      EventEmergencySquawk,
 		Timestamp: time.Now(),
 		Aircraft:  ac,
+		Squawk:    squawk,
+		Units:     units,
 		Message:   msg,
 	}
 }
 
-func NewWatchlistEvent(ac *models.Aircraft, matchedPattern string) Event {
+func NewWatchlistEvent(ac *models.Aircraft, match WatchlistMatch, units models.DistanceUnit) Event {
+	msg := "Matched watchlist pattern: " + match.Pattern
+	if match.Group != "" {
+		msg = "Matched " + match.Group + " watchlist pattern: " + match.Pattern
+	}
 	return Event{
 		Type:      EventWatchlistMatch,
 		Timestamp: time.Now(),
 		Aircraft:  ac,
-		Message:   "Matched watchlist pattern: " + matchedPattern,
+		Watchlist: &match,
+		Units:     units,
+		Message:   msg,
 	}
 }
 
@@ -68,6 +119,20 @@ func NewAircraftEvent(ac *models.Aircraft) Event {
 	}
 }
 
+func NewMaxRangeEvent(ac *models.Aircraft, units models.DistanceUnit) Event {
+	msg := "New all-time max range"
+	if ac.DistanceNM != nil {
+		msg = "New all-time max range: " + formatDistance(*ac.DistanceNM, units)
+	}
+	return Event{
+		Type:      EventMaxRange,
+		Timestamp: time.Now(),
+		Aircraft:  ac,
+		Units:     units,
+		Message:   msg,
+	}
+}
+
 func NewHealthAlertEvent(health *HealthData, alertType string) Event {
 	return Event{
 		Type:      EventHealthAlert,
@@ -77,3 +142,167 @@ func NewHealthAlertEvent(health *HealthData, alertType string) Event {
 	}
 }
 
+func NewFeedDownEvent(feed *FeedStatusData) Event {
+	return Event{
+		Type:      EventFeedDown,
+		Timestamp: time.Now(),
+		Feed:      feed,
+		Message:   fmt.Sprintf("Feed %s:%d has been disconnected for %s", feed.Host, feed.Port, feed.Downtime.Round(time.Second)),
+	}
+}
+
+func NewFeedUpEvent(feed *FeedStatusData) Event {
+	return Event{
+		Type:      EventFeedUp,
+		Timestamp: time.Now(),
+		Feed:      feed,
+		Message:   fmt.Sprintf("Feed %s:%d reconnected after %s", feed.Host, feed.Port, feed.Downtime.Round(time.Second)),
+	}
+}
+
+func NewFlightCompleteEvent(flight *FlightData, units models.DistanceUnit) Event {
+	name := flight.ICAO
+	if flight.Callsign != "" {
+		name = flight.Callsign
+	}
+	return Event{
+		Type:      EventFlightComplete,
+		Timestamp: time.Now(),
+		Flight:    flight,
+		Units:     units,
+		Message:   fmt.Sprintf("%s finished a %s flight covering %s", name, flight.Duration().Round(time.Minute), formatDistance(flight.TotalDistNM, units)),
+	}
+}
+
+// DigestData summarises activity over a digest period. Fields a source
+// cannot supply are left zero and omitted from the alert.
+type DigestData struct {
+	Period           time.Duration
+	Aircraft         int
+	Positions        int
+	BusiestType      string
+	BusiestTypeCount int
+	MaxRangeNM       float64
+	MaxRangeICAO     string
+	Emergencies      int
+}
+
+func NewDigestEvent(digest *DigestData, units models.DistanceUnit) Event {
+	parts := []string{fmt.Sprintf("%d aircraft", digest.Aircraft)}
+	if digest.BusiestType != "" {
+		parts = append(parts, "busiest type "+digest.BusiestType)
+	}
+	if digest.MaxRangeNM > 0 {
+		parts = append(parts, "max range "+formatDistance(digest.MaxRangeNM, units))
+	}
+	switch digest.Emergencies {
+	case 0:
+	case 1:
+		parts = append(parts, "1 emergency")
+	default:
+		parts = append(parts, fmt.Sprintf("%d emergencies", digest.Emergencies))
+	}
+
+	return Event{
+		Type:      EventDigest,
+		Timestamp: time.Now(),
+		Digest:    digest,
+		Units:     units,
+		Message:   "In the last " + formatPeriod(digest.Period) + ": " + strings.Join(parts, ", "),
+	}
+}
+
+// formatPeriod renders a digest period as "hour" or a short duration such
+// as "6h" or "1h30m".
+func formatPeriod(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d == time.Hour {
+		return "hour"
+	}
+	s := d.String()
+	s = strings.TrimSuffix(s, "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatDistance renders a distance held in nautical miles in the
+// configured display unit.
+func formatDistance(nm float64, units models.DistanceUnit) string {
+	return fmt.Sprintf("%.1f %s", units.FromNM(nm), units.Label())
+}
+
+func NewTestEvent() Event {
+	return Event{
+		Type:      EventTest,
+		Timestamp: time.Now(),
+		Message:   "Webhook is configured correctly!",
+	}
+}
+
+// ErrUnknownTestEvent is returned for a test event kind SampleEvent does
+// not know.
+var ErrUnknownTestEvent = errors.New("unknown test event type")
+
+// TestEventKinds lists the kinds accepted by SampleEvent.
+var TestEventKinds = []string{"emergency", "watchlist", "new", "health", "digest"}
+
+// SampleEvent builds an example event of the given kind, filled with
+// made-up aircraft or health data, for checking how alerts are formatted.
+func SampleEvent(kind string, units models.DistanceUnit) (Event, bool) {
+	var event Event
+	switch kind {
+	case "emergency":
+		event = NewEmergencyEvent(sampleAircraft("7700"), "7700", units)
+	case "watchlist":
+		event = NewWatchlistEvent(sampleAircraft("1200"), WatchlistMatch{Pattern: "TEST*"}, units)
+	case "new":
+		event = NewAircraftEvent(sampleAircraft("1200"))
+	case "health":
+		event = NewHealthAlertEvent(&HealthData{
+			CPUPercent:    93.5,
+			MemoryPercent: 61.2,
+			TempCelsius:   71.0,
+			DiskPercent:   48.0,
+			Uptime:        72 * time.Hour,
+		}, "High CPU usage: 93.5%")
+	case "digest":
+		event = NewDigestEvent(&DigestData{
+			Period:           time.Hour,
+			Aircraft:         142,
+			Positions:        51230,
+			BusiestType:      "B738",
+			BusiestTypeCount: 17,
+			MaxRangeNM:       210,
+			MaxRangeICAO:     "ABC123",
+			Emergencies:      1,
+		}, units)
+	default:
+		return Event{}, false
+	}
+	event.Message = "[Test] " + event.Message
+	return event, true
+}
+
+func sampleAircraft(squawk string) *models.Aircraft {
+	alt := 35000
+	speed := 450.0
+	lat, lon := 51.4700, -0.4543
+	dist, bearing := 42.3, 270.0
+	return &models.Aircraft{
+		ICAO:            "ABC123",
+		Callsign:        "TEST123",
+		Registration:    "N12345",
+		AircraftType:    "B738",
+		Operator:        "Skywatch Test",
+		Squawk:          squawk,
+		AltitudeFt:      &alt,
+		SpeedKt:         &speed,
+		Lat:             &lat,
+		Lon:             &lon,
+		DistanceNM:      &dist,
+		Bearing:         &bearing,
+		BearingCardinal: "W",
+	}
+}
@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"adsb-tracker/internal/config"
+)
+
+const (
+	mapImageName     = "map.png"
+	mapWidth         = 400
+	mapHeight        = 240
+	mapTileSize      = 256
+	mapMaxLat        = 85.05112878
+	mapUserAgent     = "Skywatch ADS-B Tracker"
+	mapTimeout       = 10 * time.Second
+	mapTileCacheSize = 64
+)
+
+var (
+	markerFill    = color.RGBA{R: 0xE0, G: 0x20, B: 0x20, A: 0xFF}
+	markerOutline = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+)
+
+// mapRenderer draws a small PNG map from slippy map tiles with a marker at
+// the requested position.
+type mapRenderer struct {
+	tileURL string
+	zoom    int
+	client  *http.Client
+
+	mu    sync.Mutex
+	tiles map[string]image.Image
+}
+
+// newMapRenderer returns nil when no tile source is configured.
+func newMapRenderer(cfg config.WebhookMapConfig, client *http.Client) *mapRenderer {
+	if cfg.TileURL == "" {
+		return nil
+	}
+	return &mapRenderer{
+		tileURL: cfg.TileURL,
+		zoom:    cfg.Zoom,
+		client:  client,
+		tiles:   make(map[string]image.Image),
+	}
+}
+
+// Render returns a PNG centred on lat/lon. Tiles outside the map's
+// latitude range are left blank.
+func (r *mapRenderer) Render(ctx context.Context, lat, lon float64) ([]byte, error) {
+	lat = math.Max(-mapMaxLat, math.Min(mapMaxLat, lat))
+	n := 1 << r.zoom
+	worldSize := float64(n * mapTileSize)
+
+	latRad := lat * math.Pi / 180
+	cx := (lon + 180) / 360 * worldSize
+	cy := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * worldSize
+
+	left := int(math.Floor(cx)) - mapWidth/2
+	top := int(math.Floor(cy)) - mapHeight/2
+
+	img := image.NewRGBA(image.Rect(0, 0, mapWidth, mapHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 0xE5, G: 0xE3, B: 0xDF, A: 0xFF}), image.Point{}, draw.Src)
+
+	for ty := floorDiv(top, mapTileSize); ty <= floorDiv(top+mapHeight-1, mapTileSize); ty++ {
+		if ty < 0 || ty >= n {
+			continue
+		}
+		for tx := floorDiv(left, mapTileSize); tx <= floorDiv(left+mapWidth-1, mapTileSize); tx++ {
+			tile, err := r.tile(ctx, ((tx%n)+n)%n, ty)
+			if err != nil {
+				return nil, err
+			}
+			dst := image.Rect(tx*mapTileSize-left, ty*mapTileSize-top, (tx+1)*mapTileSize-left, (ty+1)*mapTileSize-top)
+			draw.Draw(img, dst, tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+
+	drawMarker(img, mapWidth/2, mapHeight/2)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *mapRenderer) tile(ctx context.Context, x, y int) (image.Image, error) {
+	url := strings.NewReplacer(
+		"{z}", strconv.Itoa(r.zoom),
+		"{x}", strconv.Itoa(x),
+		"{y}", strconv.Itoa(y),
+	).Replace(r.tileURL)
+
+	r.mu.Lock()
+	cached, ok := r.tiles[url]
+	r.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", mapUserAgent)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tile %d/%d/%d returned status %d", r.zoom, x, y, resp.StatusCode)
+	}
+	tile, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("tile %d/%d/%d: %w", r.zoom, x, y, err)
+	}
+
+	r.mu.Lock()
+	if len(r.tiles) >= mapTileCacheSize {
+		clear(r.tiles)
+	}
+	r.tiles[url] = tile
+	r.mu.Unlock()
+	return tile, nil
+}
+
+func drawMarker(img *image.RGBA, cx, cy int) {
+	const outer, inner = 8, 6
+	for y := -outer; y <= outer; y++ {
+		for x := -outer; x <= outer; x++ {
+			d := x*x + y*y
+			switch {
+			case d <= inner*inner:
+				img.SetRGBA(cx+x, cy+y, markerFill)
+			case d <= outer*outer:
+				img.SetRGBA(cx+x, cy+y, markerOutline)
+			}
+		}
+	}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// multipartBody wraps a JSON payload and a PNG attachment in the form
+// Discord expects for file uploads.
+func multipartBody(payload, image []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	if err := w.WriteField("payload_json", string(payload)); err != nil {
+		return nil, "", err
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[0]"; filename=%q`, mapImageName))
+	h.Set("Content-Type", "image/png")
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(image); err != nil {
+		return nil, "", err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
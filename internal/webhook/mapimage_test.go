@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"adsb-tracker/internal/config"
+	"adsb-tracker/pkg/models"
+)
+
+func tileServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	tile := image.NewUniform(color.RGBA{B: 0xFF, A: 0xFF})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, &tileImage{tile}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type tileImage struct{ *image.Uniform }
+
+func (tileImage) Bounds() image.Rectangle { return image.Rect(0, 0, mapTileSize, mapTileSize) }
+
+func TestMapRendererDrawsMarkerOnTiles(t *testing.T) {
+	var requests atomic.Int32
+	srv := tileServer(t, &requests)
+	r := newMapRenderer(config.WebhookMapConfig{TileURL: srv.URL + "/{z}/{x}/{y}.png", Zoom: 9}, srv.Client())
+
+	data, err := r.Render(context.Background(), 51.47, -0.45)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != mapWidth || b.Dy() != mapHeight {
+		t.Fatalf("expected %dx%d image, got %v", mapWidth, mapHeight, b)
+	}
+	if got := color.RGBAModel.Convert(img.At(mapWidth/2, mapHeight/2)); got != markerFill {
+		t.Errorf("expected marker at centre, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != (color.RGBA{B: 0xFF, A: 0xFF}) {
+		t.Errorf("expected tile at corner, got %v", got)
+	}
+
+	fetched := requests.Load()
+	if _, err := r.Render(context.Background(), 51.47, -0.45); err != nil {
+		t.Fatalf("second render: %v", err)
+	}
+	if requests.Load() != fetched {
+		t.Errorf("expected cached tiles to be reused, got %d more requests", requests.Load()-fetched)
+	}
+}
+
+func TestRenderMapSkipsAircraftWithoutPosition(t *testing.T) {
+	var requests atomic.Int32
+	srv := tileServer(t, &requests)
+	d := NewDispatcher(config.WebhookConfig{
+		URL: "https://example.com/hook",
+		Map: config.WebhookMapConfig{TileURL: srv.URL + "/{z}/{x}/{y}.png", Zoom: 9},
+	})
+
+	event := Event{Type: EventNewAircraft, Timestamp: time.Now(), Aircraft: &models.Aircraft{ICAO: "ABC123"}}
+	if img := d.renderMap(context.Background(), event); img != nil {
+		t.Fatal("expected no image without a position")
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("expected no tile requests, got %d", requests.Load())
+	}
+
+	lat, lon := 51.47, -0.45
+	event.Aircraft.Lat, event.Aircraft.Lon = &lat, &lon
+	if img := d.renderMap(context.Background(), event); img == nil {
+		t.Fatal("expected an image with a position")
+	}
+}
+
+func TestProcessEventUploadsMapToDiscord(t *testing.T) {
+	var requests atomic.Int32
+	tiles := tileServer(t, &requests)
+
+	received := make(chan DiscordMessage, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("expected multipart body: %v", err)
+			received <- DiscordMessage{}
+			return
+		}
+		if files := r.MultipartForm.File["files[0]"]; len(files) != 1 || files[0].Filename != mapImageName {
+			t.Errorf("expected %s attachment, got %v", mapImageName, files)
+		}
+		var msg DiscordMessage
+		if err := json.Unmarshal([]byte(r.FormValue("payload_json")), &msg); err != nil {
+			t.Errorf("payload_json: %v", err)
+		}
+		received <- msg
+	}))
+	defer hook.Close()
+
+	d := NewDispatcher(config.WebhookConfig{
+		Provider: ProviderDiscord,
+		URL:      hook.URL,
+		Map:      config.WebhookMapConfig{TileURL: tiles.URL + "/{z}/{x}/{y}.png", Zoom: 9},
+	})
+	lat, lon := 51.47, -0.45
+	d.processEvent(context.Background(), Event{
+		Type:      EventNewAircraft,
+		Timestamp: time.Now(),
+		Aircraft:  &models.Aircraft{ICAO: "ABC123", Lat: &lat, Lon: &lon},
+	})
+
+	msg := <-received
+	if len(msg.Embeds) != 1 || msg.Embeds[0].Image == nil || msg.Embeds[0].Image.URL != "attachment://"+mapImageName {
+		t.Fatalf("expected embed to reference the attachment, got %+v", msg.Embeds)
+	}
+}
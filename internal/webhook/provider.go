@@ -0,0 +1,40 @@
+package webhook
+
+import "strings"
+
+const (
+	ProviderDiscord = "discord"
+	ProviderSlack   = "slack"
+)
+
+// Provider converts an Event into the JSON payload expected by a webhook service.
+type Provider interface {
+	Name() string
+	Payload(event Event) interface{}
+}
+
+// NewProvider returns the provider named in config, falling back to
+// detecting Slack from the webhook URL and otherwise defaulting to Discord.
+func NewProvider(name, url string) Provider {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case ProviderSlack:
+		return SlackProvider{}
+	case ProviderDiscord:
+		return DiscordProvider{}
+	}
+
+	if strings.Contains(url, "hooks.slack.com") {
+		return SlackProvider{}
+	}
+	return DiscordProvider{}
+}
+
+type DiscordProvider struct{}
+
+func (DiscordProvider) Name() string {
+	return ProviderDiscord
+}
+
+func (DiscordProvider) Payload(event Event) interface{} {
+	return FormatDiscordMessage(event)
+}
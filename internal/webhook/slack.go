@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type SlackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type SlackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text,omitempty"`
+	Fields   []SlackField `json:"fields,omitempty"`
+	Footer   string       `json:"footer,omitempty"`
+	Ts       int64        `json:"ts,omitempty"`
+}
+
+type SlackMessage struct {
+	Username    string            `json:"username,omitempty"`
+	Text        string            `json:"text,omitempty"`
+	Attachments []SlackAttachment `json:"attachments"`
+}
+
+type SlackProvider struct{}
+
+func (SlackProvider) Name() string {
+	return ProviderSlack
+}
+
+func (SlackProvider) Payload(event Event) interface{} {
+	return FormatSlackMessage(event)
+}
+
+var markdownLink = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+
+// FormatSlackMessage builds a Slack attachment from the same embed used for
+// Discord so both providers carry identical fields for every event type.
+func FormatSlackMessage(event Event) SlackMessage {
+	embed := FormatDiscordMessage(event).Embeds[0]
+
+	attachment := SlackAttachment{
+		Fallback: embed.Title + ": " + embed.Description,
+		Color:    fmt.Sprintf("#%06X", embed.Color),
+		Title:    embed.Title,
+		Text:     toSlackMarkup(embed.Description),
+		Ts:       event.Timestamp.Unix(),
+	}
+	if embed.Footer != nil {
+		attachment.Footer = embed.Footer.Text
+	}
+	for _, f := range embed.Fields {
+		attachment.Fields = append(attachment.Fields, SlackField{
+			Title: f.Name,
+			Value: toSlackMarkup(f.Value),
+			Short: f.Inline,
+		})
+	}
+
+	return SlackMessage{
+		Username:    "Skywatch",
+		Attachments: []SlackAttachment{attachment},
+	}
+}
+
+func toSlackMarkup(s string) string {
+	return markdownLink.ReplaceAllString(s, "<$2|$1>")
+}
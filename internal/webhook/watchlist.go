@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"strings"
+
+	"adsb-tracker/pkg/models"
+)
+
+// WatchlistMatch describes the watchlist group and entry an aircraft matched.
+// Color and URL are zero when the group doesn't override them.
+type WatchlistMatch struct {
+	Group   string
+	Pattern string
+	Color   int
+	URL     string
+}
+
+// CheckWatchlist reports the first watchlist entry, in config order, that
+// matches the aircraft. Entries are either bare patterns, matched against the
+// ICAO, registration and callsign, or typed as field:pattern where field is
+// one of icao, reg/registration, callsign, type, squawk or operator. All
+// comparisons are case-insensitive and a trailing * matches as a prefix.
+// Operator patterns without a wildcard match anywhere in the operator name.
+func (d *Dispatcher) CheckWatchlist(ac *models.Aircraft) (WatchlistMatch, bool) {
+	for _, group := range d.eventsConfig().WatchlistGroups() {
+		for _, entry := range group.Patterns {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if matchesWatchlistEntry(ac, entry) {
+				color, _ := group.ColorValue()
+				return WatchlistMatch{
+					Group:   group.Name,
+					Pattern: entry,
+					Color:   color,
+					URL:     group.URL,
+				}, true
+			}
+		}
+	}
+
+	return WatchlistMatch{}, false
+}
+
+func matchesWatchlistEntry(ac *models.Aircraft, entry string) bool {
+	field, pattern, typed := strings.Cut(entry, ":")
+	if !typed {
+		return matchesPattern(ac.ICAO, entry) ||
+			matchesPattern(ac.Registration, entry) ||
+			matchesPattern(ac.Callsign, entry)
+	}
+
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(field)) {
+	case "icao", "hex":
+		return matchesPattern(ac.ICAO, pattern)
+	case "reg", "registration":
+		return matchesPattern(ac.Registration, pattern)
+	case "callsign", "flight":
+		return matchesPattern(ac.Callsign, pattern)
+	case "type":
+		return matchesPattern(ac.AircraftType, pattern)
+	case "squawk":
+		return matchesPattern(ac.Squawk, pattern)
+	case "operator":
+		if strings.HasSuffix(pattern, "*") {
+			return matchesPattern(ac.Operator, pattern)
+		}
+		return ac.Operator != "" && strings.Contains(strings.ToUpper(ac.Operator), strings.ToUpper(pattern))
+	}
+	return false
+}
+
+func matchesPattern(value, pattern string) bool {
+	if value == "" {
+		return false
+	}
+	if strings.HasSuffix(pattern, "*") {
+		prefix := strings.ToUpper(strings.TrimSuffix(pattern, "*"))
+		return strings.HasPrefix(strings.ToUpper(value), prefix)
+	}
+	return strings.EqualFold(value, pattern)
+}
@@ -9,22 +9,34 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
 	"time"
 
 	"adsb-tracker/internal/api"
+	"adsb-tracker/internal/autogain"
 	"adsb-tracker/internal/config"
 	"adsb-tracker/internal/database"
+	"adsb-tracker/internal/dump1090"
 	"adsb-tracker/internal/feed"
 	"adsb-tracker/internal/flight"
+	"adsb-tracker/internal/gpsd"
 	"adsb-tracker/internal/health"
 	"adsb-tracker/internal/lookup"
 	rangetracker "adsb-tracker/internal/range"
 	"adsb-tracker/internal/tracker"
 	"adsb-tracker/internal/webhook"
+	"adsb-tracker/pkg/models"
+)
+
+// Build details, set with -ldflags "-X main.version=... -X main.commit=...
+// -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    string
+	buildDate string
 )
 
 func main() {
@@ -33,61 +45,110 @@ func main() {
 	sbsPort := flag.Int("sbs-port", 0, "SBS feed port")
 	feedFormat := flag.String("feed-format", "", "Feed format: sbs or beast")
 	httpAddr := flag.String("http-addr", "", "HTTP listen address")
+	pprofAddr := flag.String("pprof", "", "Serve net/http/pprof on this address, e.g. localhost:6060")
 	staleTimeout := flag.Duration("stale-timeout", 0, "Aircraft stale timeout")
 	startDump1090 := flag.Bool("start-dump1090", false, "Automatically start dump1090 with network enabled")
 	deviceIndex := flag.Int("device-index", -1, "RTL-SDR device index for dump1090")
 	rxLat := flag.Float64("rx-lat", 0, "Receiver latitude for distance calculation")
 	rxLon := flag.Float64("rx-lon", 0, "Receiver longitude for distance calculation")
 	noDatabase := flag.Bool("no-db", false, "Run without database connection")
+	importFAA := flag.String("import-faa", "", "Import the FAA releasable aircraft database from this directory (MASTER.txt, ACFTREF.txt) and exit")
+	logLevelFlag := flag.String("log-level", "", "Log level: debug, info, warn or error")
+	logFormatFlag := flag.String("log-format", "", "Log format: text or json")
 	flag.Parse()
 
-	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
-	logger := slog.New(logHandler)
-	slog.SetDefault(logger)
-	stdLogger := slog.NewLogLogger(logHandler, slog.LevelInfo)
-	log.SetOutput(stdLogger.Writer())
-	log.SetFlags(0)
+	// Visit only covers flags given on the command line, so 0 can be passed
+	// explicitly for the receiver location.
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
 
-	cfg, err := config.Load(*configFile)
-	if err != nil {
-		log.Fatalf("[MAIN] Failed to load config: %v", err)
-	}
+	loadConfig := func() (*config.Config, error) {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
 
-	if *sbsHost != "" {
-		cfg.SBSHost = *sbsHost
-	}
-	if *sbsPort != 0 {
-		cfg.SBSPort = *sbsPort
-	}
-	if *httpAddr != "" {
-		cfg.HTTPAddr = *httpAddr
-	}
-	if *staleTimeout != 0 {
-		cfg.StaleTimeout = *staleTimeout
-	}
-	if *deviceIndex >= 0 {
-		cfg.DeviceIndex = *deviceIndex
-	}
-	if *rxLat != 0 {
-		cfg.RxLat = *rxLat
-	}
-	if *rxLon != 0 {
-		cfg.RxLon = *rxLon
+		if *sbsHost != "" {
+			cfg.SBSHost = *sbsHost
+		}
+		if *sbsPort != 0 {
+			cfg.SBSPort = *sbsPort
+		}
+		if *httpAddr != "" {
+			cfg.HTTPAddr = *httpAddr
+		}
+		if *pprofAddr != "" {
+			cfg.PprofAddr = *pprofAddr
+		}
+		if *staleTimeout != 0 {
+			cfg.StaleTimeout = *staleTimeout
+		}
+		if *deviceIndex >= 0 {
+			cfg.DeviceIndex = *deviceIndex
+		}
+		if setFlags["rx-lat"] {
+			cfg.RxLat = *rxLat
+			cfg.HasRxLat = true
+		}
+		if setFlags["rx-lon"] {
+			cfg.RxLon = *rxLon
+			cfg.HasRxLon = true
+		}
+		if *feedFormat != "" {
+			cfg.FeedFormat = *feedFormat
+		}
+		if *logLevelFlag != "" {
+			cfg.LogLevel = *logLevelFlag
+		}
+		if *logFormatFlag != "" {
+			cfg.LogFormat = *logFormatFlag
+		}
+
+		if cfg.FeedFormat == "beast" && *sbsPort == 0 && cfg.SBSPort == 30003 {
+			cfg.SBSPort = 30005
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config:\n%w", err)
+		}
+		return cfg, nil
 	}
-	if *feedFormat != "" {
-		cfg.FeedFormat = *feedFormat
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("[MAIN] %v", err)
 	}
 
-	if cfg.FeedFormat == "beast" && *sbsPort == 0 && cfg.SBSPort == 30003 {
-		cfg.SBSPort = 30005
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(parseLogLevel(cfg.LogLevel))
+	logger := setupLogging(cfg.LogFormat, logLevel)
+
+	if *importFAA != "" {
+		runFAAImport(cfg, *importFAA)
+		return
 	}
 
-	logger.Info("starting Skywatch")
+	logger.Info("starting Skywatch", "version", version)
 
-	var dump1090Cmd *exec.Cmd
+	var dump1090Proc *dump1090.Process
 	if *startDump1090 {
-		dump1090Cmd = startDump1090Process(cfg.DeviceIndex, cfg.SBSPort, cfg.FeedFormat)
-		if dump1090Cmd != nil {
+		// Without a configured gain dump1090 picks its own, unless auto gain
+		// needs a starting point, in which case it starts from the top step.
+		gain := cfg.Dump1090Gain
+		if gain == nil && cfg.AutoGain.Enabled {
+			top := dump1090.Gains[len(dump1090.Gains)-1]
+			gain = &top
+		}
+		dump1090Proc = dump1090.New(dump1090.Options{
+			DeviceIndex: cfg.DeviceIndex,
+			Port:        cfg.SBSPort,
+			FeedFormat:  cfg.FeedFormat,
+			Gain:        gain,
+			Verbose:     cfg.Dump1090Verbose,
+		})
+		if err := dump1090Proc.Start(); err != nil {
+			logger.Warn("dump1090 failed to start; make sure it is installed and in PATH, will keep retrying", "error", err)
+		} else {
 			time.Sleep(2 * time.Second)
 		}
 	}
@@ -95,33 +156,29 @@ func main() {
 	var db *database.DB
 	var repo *database.Repository
 	var faaLookup *lookup.FAALookup
+	lookupProviders := lookup.NewProviders(cfg.Lookup, lookup.NewHTTPClient())
 
 	if !*noDatabase && cfg.Database.Host != "" {
-		dbCfg := database.Config{
-			Host:     cfg.Database.Host,
-			Port:     cfg.Database.Port,
-			User:     cfg.Database.User,
-			Password: cfg.Database.Password,
-			DBName:   cfg.Database.DBName,
-			SSLMode:  cfg.Database.SSLMode,
-		}
-
-		db, err = database.Connect(dbCfg)
+		db, err = database.ConnectWithRetry(databaseConfig(cfg), cfg.Database.ConnectTimeout)
 		if err != nil {
-			log.Printf("[MAIN] Database connection failed: %v (running without persistence)", err)
-			faaLookup = lookup.NewFAALookup(nil)
+			logger.Error("database connection failed, running without persistence", "error", err)
+			faaLookup = lookup.NewFAALookup(nil, lookupProviders)
 		} else {
 			if err := db.Migrate(); err != nil {
-				log.Printf("[MAIN] Database migration failed: %v", err)
+				logger.Error("database migration failed", "error", err)
 			}
 			repo = database.NewRepository(db)
-			faaLookup = lookup.NewFAALookup(repo)
+			faaLookup = lookup.NewFAALookup(repo, lookupProviders)
 		}
 	} else {
-		log.Printf("[MAIN] Running without database")
-		faaLookup = lookup.NewFAALookup(nil)
+		logger.Info("running without database")
+		faaLookup = lookup.NewFAALookup(nil, lookupProviders)
 	}
 
+	faaLookup.SetNotFoundTTL(cfg.Lookup.NotFoundTTL)
+	faaLookup.SetRateLimit(cfg.Lookup.RateLimit)
+	faaLookup.Warm()
+
 	logger.Info("configuration loaded",
 		"feed_host", cfg.SBSHost,
 		"feed_port", cfg.SBSPort,
@@ -147,46 +204,100 @@ func main() {
 	}
 
 	var webhookDispatcher *webhook.Dispatcher
-	if cfg.Webhooks.DiscordURL != "" {
+	if cfg.Webhooks.Enabled() {
 		webhookDispatcher = webhook.NewDispatcher(cfg.Webhooks)
-		logger.Info("webhooks enabled", "provider", "discord")
+		webhookDispatcher.SetUnits(models.DistanceUnit(cfg.Units))
+		logger.Info("webhooks enabled", "provider", webhookDispatcher.ProviderName())
 	}
 
-	healthMonitor := health.NewMonitor(cfg.Webhooks.HealthThresholds, webhookDispatcher)
+	healthMonitor := health.NewMonitor(cfg.Webhooks.HealthThresholds, webhookDispatcher, cfg.HealthInterval)
+	if dump1090Proc != nil {
+		healthMonitor.SetDump1090PID(dump1090Proc.PID)
+	}
 
 	var rangeRepo rangetracker.Repository
 	if repo != nil {
 		rangeRepo = &rangeRepoAdapter{repo: repo}
 	}
-	rangeTrk := rangetracker.New(rangeRepo)
+	rangeTrk := rangetracker.New(rangeRepo, cfg.RangeBuckets)
 
 	flightTrk := flight.New(repo, cfg.StaleTimeout)
+	flightTrk.SetSplitGap(cfg.FlightSplitGap)
+	if webhookDispatcher != nil {
+		flightTrk.SetCompletionHandler(func(f flight.ActiveFlight) {
+			webhookDispatcher.SendFlightComplete(&webhook.FlightData{
+				ICAO:         f.ICAO,
+				Callsign:     f.Callsign,
+				Registration: f.Registration,
+				AircraftType: f.AircraftType,
+				FirstSeen:    f.FirstSeen,
+				LastSeen:     f.LastSeen,
+				MaxAltFt:     f.MaxAltFt,
+				TotalDistNM:  f.TotalDistNM,
+			})
+		})
+	}
+
+	var trackerWebhooks tracker.WebhookDispatcher
+	if webhookDispatcher != nil {
+		trackerWebhooks = webhookDispatcher
+	}
 
 	trk := tracker.New(tracker.Options{
 		StaleAfter:           cfg.StaleTimeout,
+		HasRxLocation:        cfg.HasRxLocation(),
 		RxLat:                cfg.RxLat,
 		RxLon:                cfg.RxLon,
+		Units:                models.DistanceUnit(cfg.Units),
 		TrailLength:          cfg.TrailLength,
+		MaxAircraft:          cfg.MaxAircraft,
 		Repo:                 repo,
 		FAALookup:            faaLookup,
-		Webhooks:             webhookDispatcher,
+		Webhooks:             trackerWebhooks,
 		RangeTracker:         rangeTrk,
 		FlightTracker:        flightTrk,
 		PersistenceWorkers:   4,
 		PersistenceQueueSize: 512,
 	})
 
-	feedClient := feed.NewClient(cfg.SBSHost, cfg.SBSPort, cfg.FeedFormat, cfg.RxLat, cfg.RxLon, trk)
+	feedClient := feed.NewClient(cfg.SBSHost, cfg.SBSPort, cfg.FeedFormat, trk)
+	feedClient.SetMaxTimestampAge(cfg.MaxTimestampAge)
+	if cfg.HasRxLocation() {
+		feedClient.SetReceiverLocation(cfg.RxLat, cfg.RxLon)
+	}
+
+	if webhookDispatcher != nil {
+		feedClient.SetWebhooks(webhookDispatcher)
+		webhookDispatcher.SetAircraftSource(trk.Get)
+	}
 
 	server := api.NewServer(trk, repo)
 	server.SetHealthMonitor(healthMonitor)
 	server.SetFeedClient(feedClient)
+	healthMonitor.SetRateSource(feedClient)
+
+	// moveReceiver keeps the tracker's distance reference and the feed's CPR
+	// reference on the same location.
+	moveReceiver := func(lat, lon float64) {
+		trk.SetReceiverLocation(lat, lon)
+		feedClient.SetReceiverLocation(lat, lon)
+	}
 	server.SetWebhooks(webhookDispatcher)
 	server.SetNodeName(cfg.NodeName)
+	server.SetBuildInfo(api.BuildInfo{Version: version, Commit: commit, BuildDate: buildDate})
+	server.SetAPIKey(cfg.APIKey)
+	server.SetFAALookup(faaLookup)
+	server.SetUnits(models.DistanceUnit(cfg.Units))
 	server.SetRangeTracker(rangeTrk)
 	server.SetFlightTracker(flightTrk)
 	readiness := health.NewReadiness()
 	server.SetReadiness(readiness)
+	var dbCheck *health.DatabaseCheck
+	if db != nil {
+		dbCheck = health.NewDatabaseCheck(db.Conn(), readiness, healthMonitor, cfg.HealthInterval)
+		readiness.MarkNotReady(health.DatabaseComponent, "starting")
+		server.SetDatabaseCheck(dbCheck)
+	}
 	server.StartHub()
 
 	httpServer := &http.Server{
@@ -220,6 +331,11 @@ func main() {
 			webhookDispatcher.Run(ctx)
 			return ctx.Err()
 		})
+		source := digestSource(trk, repo, rangeTrk.BucketCount())
+		runComponent("webhook_digest", func(ctx context.Context) error {
+			webhookDispatcher.RunDigest(ctx, cfg.Webhooks.DigestInterval, source)
+			return ctx.Err()
+		})
 	}
 
 	runComponent("health_monitor", func(ctx context.Context) error {
@@ -227,107 +343,345 @@ func main() {
 		return ctx.Err()
 	})
 
+	if dbCheck != nil {
+		runComponent("database_check", func(ctx context.Context) error {
+			dbCheck.Run(ctx)
+			return ctx.Err()
+		})
+	}
+
+	if dump1090Proc != nil {
+		dump1090Proc.SetStatusHandler(func(running bool, reason string) {
+			readiness.Set("dump1090", running, reason)
+		})
+		runComponent("dump1090", func(ctx context.Context) error {
+			dump1090Proc.Run(ctx)
+			return ctx.Err()
+		})
+	}
+
+	if cfg.AutoGain.Enabled {
+		if dump1090Proc == nil {
+			logger.Warn("auto gain requires -start-dump1090, ignoring")
+		} else {
+			gainCtl := autogain.New(cfg.AutoGain, feedClient, dump1090Proc)
+			runComponent("auto_gain", func(ctx context.Context) error {
+				gainCtl.Run(ctx)
+				return ctx.Err()
+			})
+		}
+	}
+
 	runComponent("feed_client", func(ctx context.Context) error {
 		feedClient.Run(ctx)
 		return ctx.Err()
 	})
 
+	if cfg.GPSD.Enabled {
+		gps := gpsd.New(cfg.GPSD.Addr, cfg.GPSD.Interval, moveReceiver)
+		runComponent("gpsd", func(ctx context.Context) error {
+			gps.Run(ctx)
+			return ctx.Err()
+		})
+	}
+
 	runComponent("tracker", func(ctx context.Context) error {
 		return trk.Run(ctx)
 	})
 
-	runComponent("http_server", func(ctx context.Context) error {
-		errCh := make(chan error, 1)
-		go func() {
-			errCh <- httpServer.ListenAndServe()
-		}()
+	if repo != nil {
+		runComponent("flight_tracker", func(ctx context.Context) error {
+			flightTrk.Run(ctx)
+			return ctx.Err()
+		})
+	}
 
-		select {
-		case <-ctx.Done():
-			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer shutdownCancel()
-			if err := httpServer.Shutdown(shutdownCtx); err != nil && err != http.ErrServerClosed {
-				return err
-			}
-			if err := <-errCh; err != nil && err != http.ErrServerClosed {
-				return err
-			}
-			return nil
-		case err := <-errCh:
-			if err == http.ErrServerClosed {
-				return nil
+	runComponent("config_reload", func(ctx context.Context) error {
+		current := cfg
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-hup:
+				newCfg, err := loadConfig()
+				if err != nil {
+					logger.Error("config reload failed, keeping current config", "error", err)
+					continue
+				}
+				logLevel.Set(parseLogLevel(newCfg.LogLevel))
+				reloadConfig(current, newCfg, trk, flightTrk, healthMonitor, webhookDispatcher, moveReceiver)
+				current = newCfg
 			}
-			return err
 		}
 	})
 
+	runComponent("http_server", func(ctx context.Context) error {
+		return serveHTTP(ctx, httpServer)
+	})
+
+	if cfg.PprofAddr != "" {
+		pprofServer := &http.Server{
+			Addr:    cfg.PprofAddr,
+			Handler: api.DebugHandler(),
+		}
+		logger.Warn("pprof enabled", "addr", cfg.PprofAddr)
+		runComponent("pprof_server", func(ctx context.Context) error {
+			return serveHTTP(ctx, pprofServer)
+		})
+	}
+
 	wg.Wait()
 	if err := groupErr; err != nil && !errors.Is(err, context.Canceled) {
 		logger.Error("service error", "error", err)
 	}
 
+	if n := flightTrk.CompleteAll(); n > 0 {
+		logger.Info("completed active flights", "count", n)
+	}
+
 	if db != nil {
 		db.Close()
 	}
 
-	if dump1090Cmd != nil && dump1090Cmd.Process != nil {
+	if dump1090Proc != nil {
 		logger.Info("stopping dump1090")
-		dump1090Cmd.Process.Signal(syscall.SIGTERM)
-		dump1090Cmd.Wait()
+		dump1090Proc.Stop()
 	}
 
 	logger.Info("shutdown complete")
 }
 
-func startDump1090Process(deviceIndex, port int, feedFormat string) *exec.Cmd {
-	args := []string{
-		"--device-index", fmt.Sprintf("%d", deviceIndex),
-		"--net",
-		"--quiet",
-	}
-
-	if feedFormat == "beast" {
-		args = append(args, "--net-bo-port", fmt.Sprintf("%d", port))
+// setupLogging installs a slog handler in the given format as the default
+// logger. Output from the standard log package, used by libraries and
+// net/http, goes through it at info level.
+func setupLogging(format string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
 	} else {
-		args = append(args, "--net-sbs-port", fmt.Sprintf("%d", port))
+		handler = slog.NewTextHandler(os.Stdout, opts)
 	}
 
-	cmd := exec.Command("dump1090", args...)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	log.SetOutput(slog.NewLogLogger(handler, slog.LevelInfo).Writer())
+	log.SetFlags(0)
+	return logger
+}
+
+// fatal logs msg at error level, which every log_level shows, and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// parseLogLevel maps a validated log_level to its slog level.
+func parseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
 
-	if err := cmd.Start(); err != nil {
-		log.Printf("[MAIN] Failed to start dump1090: %v", err)
-		log.Printf("[MAIN] Make sure dump1090 is installed and in PATH")
+// serveHTTP runs srv until ctx is cancelled, then shuts it down gracefully.
+func serveHTTP(ctx context.Context, srv *http.Server) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		if err := <-errCh; err != nil && err != http.ErrServerClosed {
+			return err
+		}
 		return nil
+	case err := <-errCh:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
 	}
+}
 
-	log.Printf("[MAIN] Started dump1090 (PID %d) with --net on port %d (%s)", cmd.Process.Pid, port, feedFormat)
-	return cmd
+// reloadConfig applies the settings that can change at runtime and logs the
+// ones that only take effect after a restart.
+func reloadConfig(old, cfg *config.Config, trk *tracker.Tracker, flightTrk *flight.Tracker, monitor *health.Monitor, dispatcher *webhook.Dispatcher, moveReceiver func(lat, lon float64)) {
+	trk.SetStaleAfter(cfg.StaleTimeout)
+	trk.SetMaxAircraft(cfg.MaxAircraft)
+	flightTrk.SetSplitGap(cfg.FlightSplitGap)
+	monitor.SetThresholds(cfg.Webhooks.HealthThresholds)
+
+	if old.RxLat != cfg.RxLat || old.RxLon != cfg.RxLon || old.HasRxLocation() != cfg.HasRxLocation() {
+		switch {
+		case old.GPSD.Enabled:
+			slog.Info("rx_lat/rx_lon changed but gpsd supplies the receiver location")
+		case !cfg.HasRxLocation():
+			slog.Warn("config field changed; restart required to apply it", "field", "rx_lat/rx_lon")
+		default:
+			moveReceiver(cfg.RxLat, cfg.RxLon)
+			slog.Info("receiver location updated", "lat", cfg.RxLat, "lon", cfg.RxLon)
+		}
+	}
+
+	switch {
+	case dispatcher != nil:
+		dispatcher.SetConfig(cfg.Webhooks)
+	case cfg.Webhooks.Enabled():
+		slog.Warn("webhooks were disabled at startup; restart required to enable them")
+	}
+
+	restart := []struct {
+		name    string
+		changed bool
+	}{
+		{"sbs_host", old.SBSHost != cfg.SBSHost},
+		{"sbs_port", old.SBSPort != cfg.SBSPort},
+		{"feed_format", old.FeedFormat != cfg.FeedFormat},
+		{"max_timestamp_age", old.MaxTimestampAge != cfg.MaxTimestampAge},
+		{"http_addr", old.HTTPAddr != cfg.HTTPAddr},
+		{"pprof_addr", old.PprofAddr != cfg.PprofAddr},
+		{"node_name", old.NodeName != cfg.NodeName},
+		{"api_key", old.APIKey != cfg.APIKey},
+		{"units", old.Units != cfg.Units},
+		{"log_format", old.LogFormat != cfg.LogFormat},
+		{"health_interval", old.HealthInterval != cfg.HealthInterval},
+		{"device_index", old.DeviceIndex != cfg.DeviceIndex},
+		{"trail_length", old.TrailLength != cfg.TrailLength},
+		{"range_buckets", old.RangeBuckets != cfg.RangeBuckets},
+		{"webhooks.digest_interval", old.Webhooks.DigestInterval != cfg.Webhooks.DigestInterval},
+		{"database", old.Database != cfg.Database},
+		{"dump1090_gain", !reflect.DeepEqual(old.Dump1090Gain, cfg.Dump1090Gain)},
+		{"auto_gain", old.AutoGain != cfg.AutoGain},
+		{"gpsd", old.GPSD != cfg.GPSD},
+		{"lookup", !reflect.DeepEqual(old.Lookup, cfg.Lookup)},
+	}
+	for _, r := range restart {
+		if r.changed {
+			slog.Warn("config field changed; restart required to apply it", "field", r.name)
+		}
+	}
+
+	slog.Info("config reloaded", "watchlists", len(cfg.Webhooks.Events.WatchlistGroups()),
+		"stale_timeout", cfg.StaleTimeout, "log_level", cfg.LogLevel)
 }
 
 type rangeRepoAdapter struct {
 	repo *database.Repository
 }
 
-func (a *rangeRepoAdapter) SaveRangeStats(bucket int, maxNM float64, icao string, count int64) error {
-	return a.repo.SaveRangeStats(bucket, maxNM, icao, count)
+func (a *rangeRepoAdapter) SaveRangeStats(band string, bucketCount, bucket int, maxNM float64, icao string, count int64) error {
+	return a.repo.SaveRangeStats(band, bucketCount, bucket, maxNM, icao, count)
+}
+
+func (a *rangeRepoAdapter) ResetRangeStats() error {
+	return a.repo.ResetRangeStats()
 }
 
-func (a *rangeRepoAdapter) LoadRangeStats() ([]rangetracker.BucketStats, error) {
-	dbStats, err := a.repo.LoadRangeStats()
+func (a *rangeRepoAdapter) LoadRangeStats(bucketCount int) ([]rangetracker.StoredBucket, error) {
+	dbStats, err := a.repo.LoadRangeStats(bucketCount)
 	if err != nil {
 		return nil, err
 	}
 
-	stats := make([]rangetracker.BucketStats, len(dbStats))
+	stats := make([]rangetracker.StoredBucket, len(dbStats))
 	for i, s := range dbStats {
-		stats[i] = rangetracker.BucketStats{
-			Bearing:      s.Bearing,
-			MaxRangeNM:   s.MaxRangeNM,
-			MaxRangeICAO: s.MaxRangeICAO,
-			ContactCount: s.ContactCount,
+		stats[i] = rangetracker.StoredBucket{
+			Band: s.Band,
+			BucketStats: rangetracker.BucketStats{
+				Bearing:      s.Bearing,
+				MaxRangeNM:   s.MaxRangeNM,
+				MaxRangeICAO: s.MaxRangeICAO,
+				ContactCount: s.ContactCount,
+			},
 		}
 	}
 	return stats, nil
 }
+
+// digestSource gathers the activity digest from the database. Without one
+// it can only count the aircraft the tracker first saw during the period.
+func digestSource(trk *tracker.Tracker, repo *database.Repository, bucketCount int) webhook.DigestSource {
+	lastSeen := trk.GetStats().TotalSeen
+	return func(since time.Time) (*webhook.DigestData, error) {
+		totalSeen := trk.GetStats().TotalSeen
+		digest := &webhook.DigestData{Aircraft: totalSeen - lastSeen}
+		lastSeen = totalSeen
+		if repo == nil {
+			return digest, nil
+		}
+
+		var err error
+		digest.Aircraft, digest.Positions, err = repo.GetActivitySince(since)
+		if err != nil {
+			return nil, err
+		}
+		types, err := repo.GetTopAircraftTypesSince(since, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(types) > 0 {
+			digest.BusiestType, digest.BusiestTypeCount = types[0].AircraftType, types[0].Count
+		}
+		if rx := trk.GetReceiverInfo(); rx != nil {
+			buckets, err := repo.RangeStatsSince(since, rx.Lat, rx.Lon, bucketCount)
+			if err != nil {
+				return nil, err
+			}
+			for _, b := range buckets {
+				if b.MaxRangeNM > digest.MaxRangeNM {
+					digest.MaxRangeNM, digest.MaxRangeICAO = b.MaxRangeNM, b.MaxRangeICAO
+				}
+			}
+		}
+		return digest, nil
+	}
+}
+
+func databaseConfig(cfg *config.Config) database.Config {
+	return database.Config{
+		Host:            cfg.Database.Host,
+		Port:            cfg.Database.Port,
+		User:            cfg.Database.User,
+		Password:        cfg.Database.Password,
+		DBName:          cfg.Database.DBName,
+		SSLMode:         cfg.Database.SSLMode,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	}
+}
+
+func runFAAImport(cfg *config.Config, dir string) {
+	db, err := database.Connect(databaseConfig(cfg))
+	if err != nil {
+		fatal("database connection failed", "error", err)
+	}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		fatal("database migration failed", "error", err)
+	}
+
+	start := time.Now()
+	count, err := lookup.ImportFAARegistry(database.NewRepository(db), dir)
+	if err != nil {
+		fatal("FAA import failed", "records", count, "error", err)
+	}
+	slog.Info("imported FAA registry records", "count", count, "took", time.Since(start).Round(time.Second))
+}
@@ -5,32 +5,67 @@ import (
 	"time"
 )
 
+// Message sources for Aircraft.Source. ADS-R and TIS-B are ground station
+// rebroadcasts rather than transmissions heard directly from the aircraft.
+const (
+	SourceADSB = "adsb"
+	SourceADSR = "adsr"
+	SourceTISB = "tisb"
+	SourceMLAT = "mlat"
+)
+
+// NonICAOPrefix starts the key of an aircraft identified by an anonymous or
+// TIS-B track file address rather than an ICAO address, as readsb does, so
+// it can't merge into the aircraft that owns the same 24-bit value.
+const NonICAOPrefix = "~"
+
 type Aircraft struct {
-	ICAO            string     `json:"icao"`
-	Callsign        string     `json:"callsign,omitempty"`
-	Registration    string     `json:"registration,omitempty"`
-	AircraftType    string     `json:"aircraft_type,omitempty"`
-	Operator        string     `json:"operator,omitempty"`
-	Lat             *float64   `json:"lat,omitempty"`
-	Lon             *float64   `json:"lon,omitempty"`
-	AltitudeFt      *int       `json:"alt_ft,omitempty"`
-	AltitudeGNSS    *int       `json:"alt_gnss_ft,omitempty"`
-	SpeedKt         *float64   `json:"speed_kt,omitempty"`
-	Heading         *float64   `json:"heading,omitempty"`
-	VerticalRate    *int       `json:"vertical_rate,omitempty"`
-	Squawk          string     `json:"squawk,omitempty"`
-	OnGround        *bool      `json:"on_ground,omitempty"`
-	RSSI            *float64   `json:"rssi,omitempty"`
-	DistanceNM      *float64   `json:"distance_nm,omitempty"`
-	Bearing         *float64   `json:"bearing,omitempty"`
-	BearingCardinal string     `json:"bearing_cardinal,omitempty"`
-	Trail           []Position `json:"trail,omitempty"`
-	LastSeen        time.Time  `json:"last_seen"`
+	ICAO            string      `json:"icao"`
+	Callsign        string      `json:"callsign,omitempty"`
+	Registration    string      `json:"registration,omitempty"`
+	AircraftType    string      `json:"aircraft_type,omitempty"`
+	Operator        string      `json:"operator,omitempty"`
+	EmitterCategory string      `json:"category,omitempty"`
+	Country         string      `json:"country,omitempty"`
+	CountryCode     string      `json:"country_code,omitempty"`
+	Source          string      `json:"source,omitempty"`
+	Lat             *float64    `json:"lat,omitempty"`
+	Lon             *float64    `json:"lon,omitempty"`
+	PositionGlobal  *bool       `json:"position_global,omitempty"`
+	AltitudeFt      *int        `json:"alt_ft,omitempty"`
+	AltitudeGNSS    *int        `json:"alt_gnss_ft,omitempty"`
+	SpeedKt         *float64    `json:"speed_kt,omitempty"`
+	Heading         *float64    `json:"heading,omitempty"`
+	VerticalRate    *int        `json:"vertical_rate,omitempty"`
+	VerticalRateSrc string      `json:"vertical_rate_source,omitempty"`
+	Squawk          string      `json:"squawk,omitempty"`
+	OnGround        *bool       `json:"on_ground,omitempty"`
+	Alert           *bool       `json:"alert,omitempty"`
+	Emergency       *bool       `json:"emergency,omitempty"`
+	SPI             *bool       `json:"spi,omitempty"`
+	RSSI            *float64    `json:"rssi,omitempty"`
+	ADSBVersion     *int        `json:"adsb_version,omitempty"`
+	NACp            *int        `json:"nac_p,omitempty"`
+	NIC             *int        `json:"nic,omitempty"`
+	SelectedAltFt   *int        `json:"selected_alt_ft,omitempty"`
+	SelectedHeading *float64    `json:"selected_heading,omitempty"`
+	Confidence      int         `json:"confidence"`
+	DistanceNM      *float64    `json:"distance_nm,omitempty"`
+	Distance        *float64    `json:"distance,omitempty"`
+	DistanceUnit    string      `json:"distance_unit,omitempty"`
+	Bearing         *float64    `json:"bearing,omitempty"`
+	BearingCardinal string      `json:"bearing_cardinal,omitempty"`
+	Trail           []Position  `json:"trail,omitempty"`
+	Provenance      *Provenance `json:"provenance,omitempty"`
+	LastSeen        time.Time   `json:"last_seen"`
+	// AgeSeconds is filled in on API responses only, from the server clock.
+	AgeSeconds *float64 `json:"age_seconds,omitempty"`
 }
 
 type ReceiverLocation struct {
-	Lat float64
-	Lon float64
+	Lat   float64
+	Lon   float64
+	Units DistanceUnit
 }
 
 type Position struct {
@@ -51,6 +86,12 @@ type FAAInfo struct {
 	Owner        string `json:"owner,omitempty"`
 }
 
+// EmergencyFlagged reports whether the transponder has set its emergency
+// flag, which is independent of the squawk code.
+func (a *Aircraft) EmergencyFlagged() bool {
+	return a.Emergency != nil && *a.Emergency
+}
+
 func (a *Aircraft) CalculateDistance(rx *ReceiverLocation) {
 	if rx == nil || a.Lat == nil || a.Lon == nil {
 		return
@@ -58,6 +99,11 @@ func (a *Aircraft) CalculateDistance(rx *ReceiverLocation) {
 	dist := haversineNM(rx.Lat, rx.Lon, *a.Lat, *a.Lon)
 	dist = math.Round(dist*10) / 10
 	a.DistanceNM = &dist
+	if rx.Units != "" {
+		d := math.Round(rx.Units.FromNM(dist)*10) / 10
+		a.Distance = &d
+		a.DistanceUnit = string(rx.Units)
+	}
 
 	bearing := calculateBearing(rx.Lat, rx.Lon, *a.Lat, *a.Lon)
 	bearing = math.Round(bearing)
@@ -104,12 +150,21 @@ func (a *Aircraft) Merge(update *Aircraft) {
 	if update.Callsign != "" {
 		a.Callsign = update.Callsign
 	}
+	if update.Source != "" {
+		a.Source = update.Source
+	}
+	if update.EmitterCategory != "" {
+		a.EmitterCategory = update.EmitterCategory
+	}
 	if update.Lat != nil {
 		a.Lat = update.Lat
 	}
 	if update.Lon != nil {
 		a.Lon = update.Lon
 	}
+	if update.Lat != nil && update.Lon != nil {
+		a.PositionGlobal = update.PositionGlobal
+	}
 	if update.AltitudeFt != nil && *update.AltitudeFt >= -1000 && *update.AltitudeFt < 60000 {
 		a.AltitudeFt = update.AltitudeFt
 	}
@@ -125,30 +180,76 @@ func (a *Aircraft) Merge(update *Aircraft) {
 	if update.VerticalRate != nil {
 		a.VerticalRate = update.VerticalRate
 	}
+	if update.VerticalRateSrc != "" {
+		a.VerticalRateSrc = update.VerticalRateSrc
+	}
 	if update.Squawk != "" {
 		a.Squawk = update.Squawk
 	}
 	if update.OnGround != nil {
 		a.OnGround = update.OnGround
 	}
+	if update.Alert != nil {
+		a.Alert = update.Alert
+	}
+	if update.Emergency != nil {
+		a.Emergency = update.Emergency
+	}
+	if update.SPI != nil {
+		a.SPI = update.SPI
+	}
 	if update.RSSI != nil {
 		a.RSSI = update.RSSI
 	}
-	a.LastSeen = update.LastSeen
+	if update.ADSBVersion != nil {
+		a.ADSBVersion = update.ADSBVersion
+	}
+	if update.NACp != nil {
+		a.NACp = update.NACp
+	}
+	if update.NIC != nil {
+		a.NIC = update.NIC
+	}
+	if update.SelectedAltFt != nil {
+		a.SelectedAltFt = update.SelectedAltFt
+	}
+	if update.SelectedHeading != nil {
+		a.SelectedHeading = update.SelectedHeading
+	}
+	if update.LastSeen.After(a.LastSeen) {
+		a.LastSeen = update.LastSeen
+	}
 }
 
 func (a *Aircraft) Copy() Aircraft {
+	return a.copy(true)
+}
+
+// CopyWithoutTrail is Copy without the trail, for listings that only need
+// current state.
+func (a *Aircraft) CopyWithoutTrail() Aircraft {
+	return a.copy(false)
+}
+
+func (a *Aircraft) copy(withTrail bool) Aircraft {
 	cpy := Aircraft{
 		ICAO:            a.ICAO,
 		Callsign:        a.Callsign,
 		Registration:    a.Registration,
 		AircraftType:    a.AircraftType,
 		Operator:        a.Operator,
+		EmitterCategory: a.EmitterCategory,
+		Country:         a.Country,
+		CountryCode:     a.CountryCode,
+		Source:          a.Source,
 		Squawk:          a.Squawk,
+		VerticalRateSrc: a.VerticalRateSrc,
 		BearingCardinal: a.BearingCardinal,
+		DistanceUnit:    a.DistanceUnit,
 		LastSeen:        a.LastSeen,
 	}
-	if len(a.Trail) > 0 {
+	cpy.Provenance = a.Provenance.copy()
+	if withTrail && len(a.Trail) > 0 {
 		cpy.Trail = make([]Position, len(a.Trail))
 		copy(cpy.Trail, a.Trail)
 	}
@@ -160,6 +261,10 @@ func (a *Aircraft) Copy() Aircraft {
 		v := *a.Lon
 		cpy.Lon = &v
 	}
+	if a.PositionGlobal != nil {
+		v := *a.PositionGlobal
+		cpy.PositionGlobal = &v
+	}
 	if a.AltitudeFt != nil {
 		v := *a.AltitudeFt
 		cpy.AltitudeFt = &v
@@ -184,10 +289,26 @@ func (a *Aircraft) Copy() Aircraft {
 		v := *a.OnGround
 		cpy.OnGround = &v
 	}
+	if a.Alert != nil {
+		v := *a.Alert
+		cpy.Alert = &v
+	}
+	if a.Emergency != nil {
+		v := *a.Emergency
+		cpy.Emergency = &v
+	}
+	if a.SPI != nil {
+		v := *a.SPI
+		cpy.SPI = &v
+	}
 	if a.DistanceNM != nil {
 		v := *a.DistanceNM
 		cpy.DistanceNM = &v
 	}
+	if a.Distance != nil {
+		v := *a.Distance
+		cpy.Distance = &v
+	}
 	if a.Bearing != nil {
 		v := *a.Bearing
 		cpy.Bearing = &v
@@ -196,6 +317,26 @@ func (a *Aircraft) Copy() Aircraft {
 		v := *a.RSSI
 		cpy.RSSI = &v
 	}
+	if a.ADSBVersion != nil {
+		v := *a.ADSBVersion
+		cpy.ADSBVersion = &v
+	}
+	if a.NACp != nil {
+		v := *a.NACp
+		cpy.NACp = &v
+	}
+	if a.NIC != nil {
+		v := *a.NIC
+		cpy.NIC = &v
+	}
+	if a.SelectedAltFt != nil {
+		v := *a.SelectedAltFt
+		cpy.SelectedAltFt = &v
+	}
+	if a.SelectedHeading != nil {
+		v := *a.SelectedHeading
+		cpy.SelectedHeading = &v
+	}
+	cpy.Confidence = cpy.ConfidenceAt(time.Now())
 	return cpy
 }
-
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// ConfidenceAt scores how much the aircraft's reported state can be trusted,
+// from 0 to 100, as the sum of:
+//
+//   - recency (40): full marks when heard within 5s, falling linearly to 0
+//     at 60s.
+//   - position (30): 0 without a position, 10 when it has only been decoded
+//     locally against the receiver, otherwise 30.
+//   - accuracy (20): NACp scaled from 0-11, or NIC when NACp is unknown;
+//     10 when neither has been reported.
+//   - signal (10): RSSI scaled from -45 dBFS (0) to -15 dBFS (10); 5 when
+//     the feed carries no RSSI.
+func (a *Aircraft) ConfidenceAt(now time.Time) int {
+	var score float64
+
+	age := now.Sub(a.LastSeen).Seconds()
+	switch {
+	case age <= 5:
+		score += 40
+	case age < 60:
+		score += 40 * (60 - age) / 55
+	}
+
+	if a.Lat != nil && a.Lon != nil {
+		if a.PositionGlobal != nil && !*a.PositionGlobal {
+			score += 10
+		} else {
+			score += 30
+		}
+	}
+
+	switch {
+	case a.NACp != nil:
+		score += 20 * clamp01(float64(*a.NACp)/11)
+	case a.NIC != nil:
+		score += 20 * clamp01(float64(*a.NIC)/11)
+	default:
+		score += 10
+	}
+
+	if a.RSSI != nil {
+		score += 10 * clamp01((*a.RSSI+45)/30)
+	} else {
+		score += 5
+	}
+
+	return int(math.Round(score))
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfidenceAt(t *testing.T) {
+	now := time.Now()
+	lat, lon := 52.0, 4.0
+	nacp, rssi := 11, -15.0
+	global, local := true, false
+
+	best := Aircraft{Lat: &lat, Lon: &lon, PositionGlobal: &global, NACp: &nacp, RSSI: &rssi, LastSeen: now}
+	if got := best.ConfidenceAt(now); got != 100 {
+		t.Fatalf("expected 100 for a fresh, accurate, global fix, got %d", got)
+	}
+
+	weak := Aircraft{Lat: &lat, Lon: &lon, PositionGlobal: &local, LastSeen: now.Add(-60 * time.Second)}
+	if got := weak.ConfidenceAt(now); got != 25 {
+		t.Fatalf("expected 25 for a stale local-only fix, got %d", got)
+	}
+
+	sbs := Aircraft{LastSeen: now.Add(-2 * time.Second)}
+	if got := sbs.ConfidenceAt(now); got != 55 {
+		t.Fatalf("expected 55 for a fresh aircraft without position, got %d", got)
+	}
+}
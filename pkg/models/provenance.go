@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+// Feed formats for Origin.Format.
+const (
+	FormatBeast = "beast"
+	FormatSBS   = "sbs"
+)
+
+// Origin identifies the feed connection and wire format an update arrived on.
+type Origin struct {
+	Feed   string
+	Format string
+}
+
+// FieldSource records where the current value of a group of fields came
+// from. Source is the message source (adsb, mlat, ...) when the format
+// carries one.
+type FieldSource struct {
+	Feed   string    `json:"feed,omitempty"`
+	Format string    `json:"format,omitempty"`
+	Source string    `json:"source,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Provenance tracks which feed supplied an aircraft's last position and
+// last velocity.
+type Provenance struct {
+	Position *FieldSource `json:"position,omitempty"`
+	Velocity *FieldSource `json:"velocity,omitempty"`
+}
+
+// precedenceWindow is how long a position or velocity from a higher-trust
+// source is protected from being overwritten by a lower-trust one.
+const precedenceWindow = 10 * time.Second
+
+// sourceRank orders message sources by trust. Direct ADS-B beats
+// rebroadcasts and multilateration; updates without a source, such as SBS
+// lines, rank lowest.
+var sourceRank = map[string]int{
+	SourceADSB: 4,
+	SourceADSR: 3,
+	SourceMLAT: 2,
+	SourceTISB: 1,
+}
+
+// outranks reports whether a field last set by f should be kept
+// rather than replaced by data of the given source arriving at t.
+func (f *FieldSource) outranks(source string, t time.Time) bool {
+	if f == nil || t.Sub(f.Time) >= precedenceWindow {
+		return false
+	}
+	return sourceRank[f.Source] > sourceRank[source]
+}
+
+// MergeFrom merges update like Merge, but leaves the position or velocity
+// alone when it was supplied by a higher-trust source within
+// precedenceWindow, then records origin as the provenance of what was taken.
+func (a *Aircraft) MergeFrom(update *Aircraft, origin Origin) {
+	u := *update
+	if a.Provenance != nil {
+		if a.Provenance.Position.outranks(u.Source, u.LastSeen) {
+			u.Lat, u.Lon, u.PositionGlobal = nil, nil, nil
+		}
+		if a.Provenance.Velocity.outranks(u.Source, u.LastSeen) {
+			u.SpeedKt, u.Heading, u.VerticalRate, u.VerticalRateSrc = nil, nil, nil, ""
+		}
+	}
+	a.Merge(&u)
+	a.RecordProvenance(&u, origin)
+}
+
+// RecordProvenance notes origin as the supplier of whatever position and
+// velocity fields update carries. Call it after Merge so only fields that
+// were accepted are attributed.
+func (a *Aircraft) RecordProvenance(update *Aircraft, origin Origin) {
+	hasPosition := update.Lat != nil && update.Lon != nil
+	hasVelocity := update.SpeedKt != nil || update.Heading != nil || update.VerticalRate != nil
+	if !hasPosition && !hasVelocity {
+		return
+	}
+
+	src := &FieldSource{
+		Feed:   origin.Feed,
+		Format: origin.Format,
+		Source: update.Source,
+		Time:   update.LastSeen,
+	}
+	if a.Provenance == nil {
+		a.Provenance = &Provenance{}
+	}
+	if hasPosition {
+		a.Provenance.Position = src
+	}
+	if hasVelocity {
+		a.Provenance.Velocity = src
+	}
+}
+
+func (p *Provenance) copy() *Provenance {
+	if p == nil {
+		return nil
+	}
+	cpy := &Provenance{}
+	if p.Position != nil {
+		v := *p.Position
+		cpy.Position = &v
+	}
+	if p.Velocity != nil {
+		v := *p.Velocity
+		cpy.Velocity = &v
+	}
+	return cpy
+}
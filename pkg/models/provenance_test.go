@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func positionUpdate(source string, lat float64, at time.Time) *Aircraft {
+	lon := 4.0
+	return &Aircraft{ICAO: "484175", Source: source, Lat: &lat, Lon: &lon, LastSeen: at}
+}
+
+func TestMergeFromPrecedence(t *testing.T) {
+	beast := Origin{Feed: "127.0.0.1:30005", Format: FormatBeast}
+	sbs := Origin{Feed: "127.0.0.1:30003", Format: FormatSBS}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		gap    time.Duration
+		want   float64
+	}{
+		{"sbs does not clobber fresh adsb", SourceADSB, "", time.Second, 52.0},
+		{"mlat does not clobber fresh adsb", SourceADSB, SourceMLAT, time.Second, 52.0},
+		{"tisb does not clobber fresh adsb", SourceADSB, SourceTISB, time.Second, 52.0},
+		{"adsb replaces mlat", SourceMLAT, SourceADSB, time.Second, 53.0},
+		{"mlat replaces sbs", "", SourceMLAT, time.Second, 53.0},
+		{"same source replaces", SourceMLAT, SourceMLAT, time.Second, 53.0},
+		{"sbs replaces stale adsb", SourceADSB, "", precedenceWindow, 53.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := func(source string) Origin {
+				if source == "" {
+					return sbs
+				}
+				return beast
+			}
+
+			var ac Aircraft
+			ac.MergeFrom(positionUpdate(tt.first, 52.0, now), origin(tt.first))
+			ac.MergeFrom(positionUpdate(tt.second, 53.0, now.Add(tt.gap)), origin(tt.second))
+
+			if ac.Lat == nil || *ac.Lat != tt.want {
+				t.Fatalf("expected lat %.1f, got %v", tt.want, ac.Lat)
+			}
+			wantSource := tt.first
+			if tt.want == 53.0 {
+				wantSource = tt.second
+			}
+			if ac.Provenance.Position.Source != wantSource {
+				t.Fatalf("expected position provenance %q, got %q", wantSource, ac.Provenance.Position.Source)
+			}
+		})
+	}
+}
+
+func TestMergeFromKeepsOtherFields(t *testing.T) {
+	now := time.Now()
+	speed, squawk := 250.0, "7000"
+
+	var ac Aircraft
+	ac.MergeFrom(positionUpdate(SourceADSB, 52.0, now), Origin{Format: FormatBeast})
+
+	update := positionUpdate("", 53.0, now.Add(time.Second))
+	update.SpeedKt = &speed
+	update.Squawk = squawk
+	ac.MergeFrom(update, Origin{Format: FormatSBS})
+
+	if *ac.Lat != 52.0 {
+		t.Fatalf("expected the ADS-B position to be kept, got %v", *ac.Lat)
+	}
+	if ac.SpeedKt == nil || *ac.SpeedKt != speed || ac.Squawk != squawk {
+		t.Fatal("expected fields without a better source to be merged")
+	}
+	if ac.Provenance.Velocity == nil || ac.Provenance.Velocity.Format != FormatSBS {
+		t.Fatal("expected velocity to be attributed to the SBS feed")
+	}
+	if update.Lat == nil {
+		t.Fatal("MergeFrom must not modify the update")
+	}
+}
+
+func TestMergeFromNeverMovesLastSeenBack(t *testing.T) {
+	now := time.Now()
+	var ac Aircraft
+	ac.MergeFrom(positionUpdate(SourceADSB, 52.0, now), Origin{})
+	ac.MergeFrom(positionUpdate(SourceADSB, 52.1, now.Add(-time.Minute)), Origin{})
+
+	if !ac.LastSeen.Equal(now) {
+		t.Fatalf("expected LastSeen to stay at %v, got %v", now, ac.LastSeen)
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+// DistanceUnit selects how distances are presented. Distances are always
+// computed and stored in nautical miles.
+type DistanceUnit string
+
+const (
+	UnitNM DistanceUnit = "nm"
+	UnitKM DistanceUnit = "km"
+	UnitMI DistanceUnit = "mi"
+)
+
+// FromNM converts a distance in nautical miles to u.
+func (u DistanceUnit) FromNM(nm float64) float64 {
+	switch u {
+	case UnitKM:
+		return nm * 1.852
+	case UnitMI:
+		return nm * 1.150779
+	}
+	return nm
+}
+
+// Label is the display suffix for u.
+func (u DistanceUnit) Label() string {
+	switch u {
+	case UnitKM:
+		return "km"
+	case UnitMI:
+		return "mi"
+	}
+	return "NM"
+}
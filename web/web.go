@@ -0,0 +1,9 @@
+// Package web exposes the built UI in web/dist to the server when it is
+// compiled in with -tags embedweb.
+package web
+
+import "io/fs"
+
+// Assets holds the contents of web/dist when built with -tags embedweb, and
+// is nil otherwise.
+var Assets fs.FS